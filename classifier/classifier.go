@@ -0,0 +1,44 @@
+package classifier
+
+// Категории новостей — те же, что у вопросов эко-теста (eco_questions.category)
+const (
+	Water     = "water"
+	Energy    = "energy"
+	Transport = "transport"
+	Food      = "food"
+	Waste     = "waste"
+)
+
+// Categories — порядок важен: при равных баллах выигрывает более ранняя
+var Categories = []string{Water, Energy, Transport, Food, Waste}
+
+// IsCategory — значение из списка Categories
+func IsCategory(c string) bool {
+	for _, v := range Categories {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Document — то, что классифицируется
+type Document struct {
+	Title    string
+	Text     string   // текст без разметки
+	Language string   // "ru", "en"...; пусто — все известные языки
+	Labels   []string // рубрики из самой ленты (<category>), идут в теги
+}
+
+// Result — категория ("" — ни одна не набрала порога), свободные теги и баллы по категориям
+type Result struct {
+	Category string
+	Tags     []string
+	Scores   map[string]float64
+}
+
+// Classifier — точка расширения: сейчас правила по ключевым словам (RuleClassifier),
+// позже можно подключить модель с тем же интерфейсом
+type Classifier interface {
+	Classify(doc Document) Result
+}
@@ -0,0 +1,205 @@
+package classifier
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	titleWeight      = 2.0 // совпадение в заголовке весит больше, чем в тексте
+	maxHitsPerTerm   = 3   // одно слово, повторённое 20 раз, не должно решать всё
+	defaultMinScore  = 2.0
+	defaultMaxTags   = 8
+	maxLabelTagRunes = 30
+)
+
+// LanguageRules — правила одного языка. Ключ — термин, значение — вес.
+// Термин "вод*" совпадает с любым словом на "вод", без звёздочки — только целое слово.
+// Термин из нескольких слов ("общественный транспорт") ищется как фраза.
+type LanguageRules struct {
+	Categories map[string]map[string]float64 `json:"categories"`
+	Tags       map[string]map[string]float64 `json:"tags"`
+}
+
+// RuleClassifier — классификатор на взвешенных ключевых словах
+type RuleClassifier struct {
+	Rules    map[string]LanguageRules // по коду языка
+	MinScore float64                  // минимальный балл категории
+	MaxTags  int
+}
+
+//go:embed rules.json
+var defaultRulesJSON []byte
+
+// Default — классификатор со встроенными правилами (rules.json)
+func Default() *RuleClassifier {
+	c, err := Load(strings.NewReader(string(defaultRulesJSON)))
+	if err != nil {
+		panic("classifier: broken embedded rules: " + err.Error())
+	}
+	return c
+}
+
+// FromFile — правила из JSON-файла того же формата, что rules.json
+func FromFile(path string) (*RuleClassifier, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+func Load(r io.Reader) (*RuleClassifier, error) {
+	var rules map[string]LanguageRules
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, err
+	}
+	for lang, lr := range rules {
+		for cat := range lr.Categories {
+			if !IsCategory(cat) {
+				return nil, fmt.Errorf("%s: unknown category %q", lang, cat)
+			}
+		}
+	}
+	return &RuleClassifier{Rules: rules, MinScore: defaultMinScore, MaxTags: defaultMaxTags}, nil
+}
+
+func (c *RuleClassifier) Classify(doc Document) Result {
+	title := tokenize(doc.Title)
+	text := tokenize(doc.Text)
+
+	res := Result{Scores: make(map[string]float64)}
+	tagScores := make(map[string]float64)
+
+	for _, lr := range c.rulesFor(doc.Language) {
+		for cat, terms := range lr.Categories {
+			res.Scores[cat] += score(terms, title, text)
+		}
+		for tag, terms := range lr.Tags {
+			tagScores[tag] += score(terms, title, text)
+		}
+	}
+
+	best := 0.0
+	for _, cat := range Categories {
+		if s := res.Scores[cat]; s >= c.MinScore && s > best {
+			res.Category, best = cat, s
+		}
+	}
+
+	res.Tags = c.tags(tagScores, doc.Labels)
+	return res
+}
+
+// rulesFor — правила языка документа; для неизвестного языка — все сразу
+func (c *RuleClassifier) rulesFor(lang string) []LanguageRules {
+	if lr, ok := c.Rules[strings.ToLower(lang)]; ok {
+		return []LanguageRules{lr}
+	}
+	langs := make([]string, 0, len(c.Rules))
+	for l := range c.Rules {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs)
+
+	all := make([]LanguageRules, 0, len(langs))
+	for _, l := range langs {
+		all = append(all, c.Rules[l])
+	}
+	return all
+}
+
+// tags: сначала сработавшие правила (по убыванию балла), потом рубрики ленты
+func (c *RuleClassifier) tags(scores map[string]float64, labels []string) []string {
+	var ruled []string
+	for tag, s := range scores {
+		if s > 0 {
+			ruled = append(ruled, tag)
+		}
+	}
+	sort.Slice(ruled, func(i, j int) bool {
+		if scores[ruled[i]] != scores[ruled[j]] {
+			return scores[ruled[i]] > scores[ruled[j]]
+		}
+		return ruled[i] < ruled[j]
+	})
+
+	seen := make(map[string]bool)
+	tags := []string{}
+	add := func(t string) {
+		if t != "" && !seen[t] && len(tags) < c.MaxTags {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	for _, t := range ruled {
+		add(t)
+	}
+	for _, l := range labels {
+		add(NormalizeTag(l))
+	}
+	return tags
+}
+
+// NormalizeTag: "Climate Change" → "climate-change"; пусто, если тег слишком длинный или без букв
+func NormalizeTag(tag string) string {
+	words := tokenize(tag)
+	t := strings.Join(words, "-")
+	if t == "" || utf8.RuneCountInString(t) > maxLabelTagRunes {
+		return ""
+	}
+	return t
+}
+
+func score(terms map[string]float64, title, text []string) float64 {
+	total := 0.0
+	for term, weight := range terms {
+		pattern := strings.Fields(strings.ReplaceAll(strings.ToLower(term), "ё", "е"))
+		total += weight * (titleWeight*float64(hits(pattern, title)) + float64(hits(pattern, text)))
+	}
+	return total
+}
+
+// hits — сколько раз фраза встречается в словах (не больше maxHitsPerTerm)
+func hits(pattern, words []string) int {
+	if len(pattern) == 0 {
+		return 0
+	}
+	n := 0
+	for i := 0; i+len(pattern) <= len(words) && n < maxHitsPerTerm; i++ {
+		matched := true
+		for j, p := range pattern {
+			if !wordMatches(p, words[i+j]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			n++
+		}
+	}
+	return n
+}
+
+func wordMatches(pattern, word string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(word, prefix)
+	}
+	return pattern == word
+}
+
+// tokenize — слова в нижнем регистре; ё приравнивается к е
+func tokenize(s string) []string {
+	s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
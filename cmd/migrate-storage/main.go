@@ -0,0 +1,109 @@
+// migrate-storage переносит файлы из локального каталога uploads в хранилище,
+// настроенное через env (STORAGE_DRIVER=s3, S3_*). Ключи сохраняются
+// (users/<file> -> users/<file>), поэтому пути в БД менять не нужно.
+//
+//	STORAGE_DRIVER=s3 S3_ENDPOINT=... go run ./cmd/migrate-storage -from ./uploads
+package main
+
+import (
+	"flag"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dl/storage"
+)
+
+func main() {
+	from := flag.String("from", "./uploads", "локальный каталог с файлами")
+	dryRun := flag.Bool("dry-run", false, "только показать, что будет перенесено")
+	deleteSrc := flag.Bool("delete", false, "удалять локальный файл после успешной загрузки")
+	flag.Parse()
+
+	dst, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("invalid storage configuration: %v", err)
+	}
+	if local, ok := dst.(*storage.LocalStorage); ok {
+		if same, _ := sameDir(local.Root, *from); same {
+			log.Fatal("target storage is the source directory, set STORAGE_DRIVER=s3")
+		}
+	}
+
+	var copied, failed int
+	err = filepath.WalkDir(*from, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// временные файлы незавершённых загрузок не переносим
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(*from, path)
+		if err != nil {
+			return err
+		}
+		key, err := storage.CleanKey(filepath.ToSlash(rel))
+		if err != nil {
+			log.Printf("skip %s: %v", path, err)
+			return nil
+		}
+
+		if *dryRun {
+			log.Printf("would copy %s -> %s", path, key)
+			copied++
+			return nil
+		}
+
+		if err := copyFile(dst, path, key); err != nil {
+			log.Printf("copy %s failed: %v", path, err)
+			failed++
+			return nil
+		}
+		copied++
+
+		if *deleteSrc {
+			if err := os.Remove(path); err != nil {
+				log.Printf("could not remove %s: %v", path, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("walk %s: %v", *from, err)
+	}
+
+	log.Printf("done: %d copied, %d failed", copied, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func copyFile(dst storage.Storage, path, key string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return dst.Put(key, f, info.Size(), mime.TypeByExtension(filepath.Ext(path)))
+}
+
+func sameDir(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return absA == absB, nil
+}
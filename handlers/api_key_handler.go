@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"dl/services"
+	"dl/utils"
+	"encoding/json"
+	"net/http"
+)
+
+type APIKeyHandler struct {
+	Service *services.APIKeyService
+}
+
+// ------------------------ LIST API KEYS ------------------------
+
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	keys, err := h.Service.List(userID)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, keys)
+}
+
+// ------------------------ CREATE API KEY ------------------------
+
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var data struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	key, err := h.Service.Create(userID, data.Name, data.Scopes, data.ExpiresInDays, utils.RequestMetaFromContext(r.Context()))
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated, key)
+}
+
+// ------------------------ REVOKE API KEY ------------------------
+
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var data struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if data.ID == 0 {
+		jsonError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if err := h.Service.Revoke(userID, data.ID, utils.RequestMetaFromContext(r.Context())); err != nil {
+		jsonError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{"message": "api key revoked"})
+}
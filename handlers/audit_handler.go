@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"dl/models"
+	"dl/services"
+	"dl/utils"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AuditHandler struct {
+	Service *services.AuditService
+}
+
+// ------------------------ MY SECURITY ACTIVITY ------------------------
+
+func (h *AuditHandler) MyActivity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	events, err := h.Service.RecentActivity(userID)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, events)
+}
+
+// ------------------------ ADMIN: SEARCH EVENTS ------------------------
+
+// Search — GET /admin/audit-events?actor_id=&target_id=&event_type=&ip=&from=&to=&before_id=&limit=
+// from/to в формате RFC3339
+func (h *AuditHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	adminID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := r.URL.Query()
+	f := models.AuditFilter{
+		EventType: q.Get("event_type"),
+		IP:        q.Get("ip"),
+	}
+
+	var bad string
+	parseID := func(name string) *int64 {
+		v := q.Get(name)
+		if v == "" {
+			return nil
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			bad = name
+			return nil
+		}
+		return &id
+	}
+	parseTime := func(name string) *time.Time {
+		v := q.Get(name)
+		if v == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			bad = name
+			return nil
+		}
+		return &t
+	}
+
+	f.ActorID = parseID("actor_id")
+	f.TargetID = parseID("target_id")
+	f.From = parseTime("from")
+	f.To = parseTime("to")
+	if before := parseID("before_id"); before != nil {
+		f.BeforeID = *before
+	}
+	if limit := parseID("limit"); limit != nil {
+		f.Limit = int(*limit)
+	}
+
+	if bad != "" {
+		jsonError(w, http.StatusBadRequest, "invalid parameter: "+bad)
+		return
+	}
+
+	events, err := h.Service.Search(adminID, f, utils.RequestMetaFromContext(r.Context()))
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := map[string]interface{}{"events": events}
+	if len(events) > 0 {
+		resp["next_before_id"] = events[len(events)-1].ID
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
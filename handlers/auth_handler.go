@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"dl/repositories"
 	"dl/services"
+	"dl/utils"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Универсальный JSON ответ
@@ -19,6 +25,32 @@ func jsonError(w http.ResponseWriter, status int, msg string) {
 	jsonResponse(w, status, map[string]string{"error": msg})
 }
 
+// Ошибка политики паролей — отдаём все нарушения с кодами для локализации
+func passwordErrorResponse(w http.ResponseWriter, err error) bool {
+	var policyErr *utils.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+		"error":      policyErr.Error(),
+		"violations": policyErr.Violations,
+	})
+	return true
+}
+
+// validationErrorResponse — ошибки полей (*utils.ValidationError) в формате 400 validation failed
+func validationErrorResponse(w http.ResponseWriter, err error) bool {
+	var verr *utils.ValidationError
+	if !errors.As(err, &verr) {
+		return false
+	}
+	jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+		"error":  "validation failed",
+		"fields": verr.Fields,
+	})
+	return true
+}
+
 type AuthHandler struct {
 	Service *services.AuthService
 }
@@ -56,7 +88,10 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	access, refresh, err := h.Service.Register(req.Username, req.Email, req.Password)
+	access, refresh, err := h.Service.Register(req.Username, req.Email, req.Password, utils.RequestMetaFromContext(r.Context()))
+	if passwordErrorResponse(w, err) || validationErrorResponse(w, err) {
+		return
+	}
 	if err != nil {
 		jsonError(w, http.StatusBadRequest, err.Error())
 		return
@@ -98,7 +133,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	access, refresh, err := h.Service.Login(req.Email, req.Password)
+	access, refresh, err := h.Service.Login(req.Email, req.Password, utils.RequestMetaFromContext(r.Context()))
 	if err != nil {
 		jsonError(w, http.StatusUnauthorized, err.Error())
 		return
@@ -119,7 +154,7 @@ func (h *AuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	access, refresh, err := h.Service.VerifyEmail(code)
+	access, refresh, err := h.Service.VerifyEmail(code, utils.RequestMetaFromContext(r.Context()))
 	if err != nil {
 		jsonError(w, http.StatusBadRequest, err.Error())
 		return
@@ -155,7 +190,7 @@ func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.RequestPasswordReset(data.Email); err != nil {
+	if err := h.Service.RequestPasswordReset(data.Email, utils.RequestMetaFromContext(r.Context())); err != nil {
 		jsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -187,7 +222,10 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.ResetPassword(data.Token, data.NewPassword); err != nil {
+	if err := h.Service.ResetPassword(data.Token, data.NewPassword, utils.RequestMetaFromContext(r.Context())); err != nil {
+		if passwordErrorResponse(w, err) {
+			return
+		}
 		jsonError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -196,3 +234,145 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		"message": "Password successfully reset",
 	})
 }
+
+// ------------------------ MAGIC LINK ------------------------
+
+const magicDeviceCookie = "magic_device"
+
+// magicDeviceID возвращает id устройства из cookie, при отсутствии — создаёт новый
+func magicDeviceID(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(magicDeviceCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+
+	id := utils.RandomURLToken(24)
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicDeviceCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+func (h *AuthHandler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var data struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	data.Email = strings.TrimSpace(data.Email)
+
+	if data.Email == "" {
+		jsonError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	deviceID := magicDeviceID(w, r)
+
+	if err := h.Service.RequestMagicLink(data.Email, deviceID, utils.RequestMetaFromContext(r.Context())); err != nil {
+		jsonError(w, http.StatusInternalServerError, "could not create login link")
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"message": "If this email exists, a login link has been sent",
+	})
+}
+
+func (h *AuthHandler) ConsumeMagicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var data struct {
+		Token string `json:"token"`
+		Code  string `json:"code"` // код из письма, если ссылку открыли на другом устройстве
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if data.Token == "" {
+		jsonError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	access, refresh, err := h.Service.ConsumeMagicLink(data.Token, magicDeviceID(w, r), strings.TrimSpace(data.Code), utils.RequestMetaFromContext(r.Context()))
+	if errors.Is(err, services.ErrMagicLinkConfirmationRequired) {
+		jsonResponse(w, http.StatusConflict, map[string]interface{}{
+			"error":                 err.Error(),
+			"confirmation_required": true,
+		})
+		return
+	}
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"access_token":  access,
+		"refresh_token": refresh,
+	})
+}
+
+// ------------------------ USERNAME CHANGE ------------------------
+
+type ChangeUsernameRequest struct {
+	Username string `json:"username"`
+}
+
+func (h *AuthHandler) ChangeUsername(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req ChangeUsernameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	change, err := h.Service.ChangeUsername(userID, req.Username, utils.RequestMetaFromContext(r.Context()))
+	if validationErrorResponse(w, err) {
+		return
+	}
+	var cooldown *services.UsernameCooldownError
+	switch {
+	case err == nil:
+		jsonResponse(w, http.StatusOK, change)
+	case errors.As(err, &cooldown):
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(cooldown.Until).Seconds())+1))
+		jsonResponse(w, http.StatusTooManyRequests, map[string]interface{}{
+			"error":          err.Error(),
+			"next_change_at": cooldown.Until,
+		})
+	case errors.Is(err, services.ErrUsernameUnchanged):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repositories.ErrUserNotFound):
+		jsonError(w, http.StatusNotFound, err.Error())
+	default:
+		log.Printf("change username for user %d: %v", userID, err)
+		jsonError(w, http.StatusInternalServerError, "could not change username")
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"dl/services"
+	"dl/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ExportHandler struct {
+	Service *services.ExportService
+	// Redirect — отдавать 302 на подписанную ссылку хранилища (S3) вместо проксирования архива
+	Redirect bool
+}
+
+// ------------------------ REQUEST EXPORT ------------------------
+
+func (h *ExportHandler) Request(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	export, err := h.Service.RequestExport(userID, utils.RequestMetaFromContext(r.Context()))
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusAccepted, export)
+}
+
+// ------------------------ EXPORT STATUS ------------------------
+
+func (h *ExportHandler) Status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	export, err := h.Service.GetStatus(userID)
+	if err != nil {
+		jsonError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, export)
+}
+
+// ------------------------ DOWNLOAD (signed link) ------------------------
+
+// Download — GET /export-data/download?id=&expires=&sig=, авторизация только подписью
+func (h *ExportHandler) Download(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	id, err1 := strconv.ParseInt(q.Get("id"), 10, 64)
+	expires, err2 := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err1 != nil || err2 != nil || q.Get("sig") == "" {
+		jsonError(w, http.StatusBadRequest, "invalid download link")
+		return
+	}
+
+	if h.Redirect {
+		url, err := h.Service.DownloadURL(id, expires, q.Get("sig"), utils.RequestMetaFromContext(r.Context()))
+		if err != nil {
+			exportDownloadError(w, err)
+			return
+		}
+		if url != "" {
+			w.Header().Set("Cache-Control", "private, no-store")
+			http.Redirect(w, r, url, http.StatusFound)
+			return
+		}
+	}
+
+	f, export, err := h.Service.OpenDownload(id, expires, q.Get("sig"), utils.RequestMetaFromContext(r.Context()))
+	if err != nil {
+		exportDownloadError(w, err)
+		return
+	}
+	defer f.Close()
+
+	name := fmt.Sprintf("ecofoot-export-%d.zip", export.ID)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	// локальный файл: Range-запросы для докачки больших архивов
+	if rs, ok := f.(io.ReadSeeker); ok {
+		var modTime time.Time
+		if export.CompletedAt != nil {
+			modTime = *export.CompletedAt
+		}
+		http.ServeContent(w, r, name, modTime, rs)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(export.SizeBytes, 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, f); err != nil {
+		// заголовки уже отправлены — остаётся только записать в лог
+		log.Printf("export %d download interrupted: %v", export.ID, err)
+	}
+}
+
+func exportDownloadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrSignatureInvalid), errors.Is(err, utils.ErrSignatureExpired):
+		jsonError(w, http.StatusForbidden, err.Error())
+	default:
+		jsonError(w, http.StatusNotFound, "export not found")
+	}
+}
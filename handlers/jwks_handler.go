@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"dl/utils"
+	"net/http"
+)
+
+type JWKSHandler struct {
+	Keys *utils.KeyManager
+}
+
+// ------------------------ JWKS ------------------------
+
+// Get отдаёт публичные ключи для проверки наших токенов внешними сервисами
+func (h *JWKSHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	jsonResponse(w, http.StatusOK, h.Keys.PublicJWKS())
+}
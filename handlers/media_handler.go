@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"dl/storage"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// MediaHandler раздаёт публичные медиа (/uploads/<key>) из Storage,
+// поэтому работает одинаково на любой реплике
+type MediaHandler struct {
+	Storage storage.Storage
+	// Redirect — отдавать 302 на подписанную ссылку хранилища (S3) вместо проксирования
+	Redirect bool
+}
+
+// публично доступны только эти префиксы; архивы выгрузок (exports/) — нет
+var publicMediaPrefixes = []string{"users/"}
+
+const mediaURLTTL = 15 * time.Minute
+
+func (h *MediaHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	key, err := storage.CleanKey(strings.TrimPrefix(r.URL.Path, "/uploads/"))
+	if err != nil || !isPublicMedia(key) {
+		jsonError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if h.Redirect {
+		url, err := h.Storage.SignedURL(key, mediaURLTTL)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	f, err := h.Storage.Get(key)
+	if err == storage.ErrNotFound {
+		jsonError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// имена аватаров — хэш содержимого, файл по ключу никогда не меняется
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		io.Copy(w, f)
+	}
+}
+
+func isPublicMedia(key string) bool {
+	for _, p := range publicMediaPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
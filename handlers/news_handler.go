@@ -1,31 +1,442 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"dl/models"
+	"dl/repositories"
 	"dl/services"
+	"dl/utils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type NewsHandler struct {
 	Service *services.NewsService
+	Ranking *services.NewsRankingService
+
+	PublicURL string // адрес сайта для ссылок в лентах; пусто — из запроса
 }
 
 func NewNewsHandler(service *services.NewsService) *NewsHandler {
 	return &NewsHandler{Service: service}
 }
 
-// ------------------------ GET ALL NEWS ------------------------
+// ------------------------ LIST / SEARCH ------------------------
+
+// List — GET /news?q=&source_id=&category=&tag=&language=&from=&to=&cursor=&limit=
+// from/to — RFC3339 или YYYY-MM-DD; с q результаты по релевантности.
+// to не включается, но дата без времени означает весь этот день: to=2026-01-31 — до конца 31-го.
+func (h *NewsHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	f, bad := newsFilterFromQuery(q)
+	if bad != "" {
+		jsonError(w, http.StatusBadRequest, "invalid parameter: "+bad)
+		return
+	}
+	f.ViewerID, _ = utils.UserIDFromContext(r.Context()) // без токена — 0
+
+	page, err := h.Service.ListNews(f, q.Get("cursor"))
+	if validationErrorResponse(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidNewsCursor):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		jsonResponse(w, http.StatusOK, page)
+	}
+}
+
+// newsFilterFromQuery — фильтры /news и лент из query; bad — имя неверного параметра
+func newsFilterFromQuery(q url.Values) (f models.NewsFilter, bad string) {
+	f = models.NewsFilter{
+		Query:    q.Get("q"),
+		Category: q.Get("category"),
+		Tag:      q.Get("tag"),
+		Language: q.Get("language"),
+	}
+
+	parseInt := func(name string) int64 {
+		v := q.Get(name)
+		if v == "" {
+			return 0
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			bad = name
+			return 0
+		}
+		return n
+	}
+	// wholeDay — для даты без времени вернуть начало следующего дня (граница to не включается)
+	parseTime := func(name string, wholeDay bool) *time.Time {
+		v := q.Get(name)
+		if v == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err == nil {
+			return &t
+		}
+		t, err = time.Parse("2006-01-02", v)
+		if err != nil {
+			bad = name
+			return nil
+		}
+		if wholeDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return &t
+	}
+
+	f.SourceID = parseInt("source_id")
+	f.Limit = int(parseInt("limit"))
+	f.From = parseTime("from", false)
+	f.To = parseTime("to", true)
+	return f, bad
+}
+
+// Get — GET /news/{id}
+func (h *NewsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid news id")
+		return
+	}
+
+	viewerID, _ := utils.UserIDFromContext(r.Context())
+	item, err := h.Service.GetNews(viewerID, id)
+	switch {
+	case errors.Is(err, repositories.ErrNewsNotFound):
+		jsonError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		jsonResponse(w, http.StatusOK, item)
+	}
+}
+
+// ------------------------ FEEDS ------------------------
+
+// Без PUBLIC_URL ссылки в ленте строятся из заголовка Host — такой ответ
+// нельзя отдавать из общего кэша другим клиентам
+const (
+	newsFeedCacheControl        = "public, max-age=300"
+	newsFeedPrivateCacheControl = "private, max-age=300"
+)
+
+// RSS — GET /news/feed.xml, Atom — GET /news/atom.xml, JSONFeed — GET /news/feed.json.
+// Параметры те же, что у /news (без cursor); поддерживаются условные запросы.
+func (h *NewsHandler) RSS(w http.ResponseWriter, r *http.Request) {
+	h.feed(w, r, services.NewsFeedRSS)
+}
+
+func (h *NewsHandler) Atom(w http.ResponseWriter, r *http.Request) {
+	h.feed(w, r, services.NewsFeedAtom)
+}
+
+func (h *NewsHandler) JSONFeed(w http.ResponseWriter, r *http.Request) {
+	h.feed(w, r, services.NewsFeedJSON)
+}
+
+func (h *NewsHandler) feed(w http.ResponseWriter, r *http.Request, format string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	f, bad := newsFilterFromQuery(r.URL.Query())
+	if bad != "" {
+		jsonError(w, http.StatusBadRequest, "invalid parameter: "+bad)
+		return
+	}
+
+	feed, err := h.Service.Feed(f)
+	if validationErrorResponse(w, err) {
+		return
+	}
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	base := h.baseURL(r)
+	feed.SiteURL = base + "/news"
+	feed.SelfURL = base + r.URL.RequestURI()
+	body, contentType, err := feed.Render(format)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// ETag — по содержимому: меняется и при правке уже опубликованной новости
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if h.PublicURL != "" {
+		w.Header().Set("Cache-Control", newsFeedCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", newsFeedPrivateCacheControl)
+	}
+	if !feed.Updated.IsZero() {
+		w.Header().Set("Last-Modified", feed.Updated.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, feed.Updated) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// notModified — условный запрос; If-None-Match важнее If-Modified-Since
+func notModified(r *http.Request, etag string, updated time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == etag || t == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !updated.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !updated.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// baseURL — PublicURL или схема и хост запроса (за прокси нужно задать PUBLIC_URL)
+func (h *NewsHandler) baseURL(r *http.Request) string {
+	if h.PublicURL != "" {
+		return strings.TrimRight(h.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// ------------------------ FOR YOU ------------------------
+
+// ForYou — GET /news/for-you?cursor=&limit=: персональная подборка за неделю,
+// у каждой новости explanation с причинами её места
+func (h *NewsHandler) ForYou(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid parameter: limit")
+			return
+		}
+	}
+
+	page, err := h.Ranking.ForYou(userID, acceptLanguage(r), r.URL.Query().Get("cursor"), limit)
+	switch {
+	case errors.Is(err, services.ErrInvalidNewsCursor):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		jsonResponse(w, http.StatusOK, page)
+	}
+}
+
+// acceptLanguage — первый язык из Accept-Language без региона: "ru-RU,ru;q=0.9" → "ru"
+func acceptLanguage(r *http.Request) string {
+	v := r.Header.Get("Accept-Language")
+	if i := strings.IndexAny(v, ",;"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "-"); i >= 0 {
+		v = v[:i]
+	}
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "*" {
+		return ""
+	}
+	return v
+}
+
+// ------------------------ BOOKMARKS / LIKES / READS ------------------------
+
+// Bookmark, Like, Read — PUT /news/{id}/<отметка> ставит, DELETE снимает.
+// Ответ — новость с обновлёнными like_count и viewer.
+func (h *NewsHandler) Bookmark(w http.ResponseWriter, r *http.Request) {
+	h.setInteraction(w, r, models.NewsBookmark)
+}
+
+func (h *NewsHandler) Like(w http.ResponseWriter, r *http.Request) {
+	h.setInteraction(w, r, models.NewsLike)
+}
+
+func (h *NewsHandler) Read(w http.ResponseWriter, r *http.Request) {
+	h.setInteraction(w, r, models.NewsRead)
+}
+
+func (h *NewsHandler) setInteraction(w http.ResponseWriter, r *http.Request, kind string) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid news id")
+		return
+	}
+
+	err = h.Service.SetInteraction(userID, id, kind, r.Method == http.MethodPut)
+	var item *models.NewsItem
+	if err == nil {
+		item, err = h.Service.GetNews(userID, id)
+	}
+	switch {
+	case errors.Is(err, repositories.ErrNewsNotFound):
+		jsonError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		jsonResponse(w, http.StatusOK, item)
+	}
+}
+
+// Bookmarks — GET /news/bookmarks?cursor=&limit=
+func (h *NewsHandler) Bookmarks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
 
-func (h *NewsHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid parameter: limit")
+			return
+		}
+	}
+
+	page, err := h.Service.ListBookmarks(userID, r.URL.Query().Get("cursor"), limit)
+	switch {
+	case errors.Is(err, services.ErrInvalidNewsCursor):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		jsonResponse(w, http.StatusOK, page)
+	}
+}
+
+// UnreadCount — GET /news/unread-count: непрочитанные новости за последнюю неделю
+func (h *NewsHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 
-	news, err := h.Service.GetAllNews()
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	count, err := h.Service.UnreadCount(userID)
 	if err != nil {
 		jsonError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	jsonResponse(w, http.StatusOK, count)
+}
 
-	jsonResponse(w, http.StatusOK, news)
+// ------------------------ MODERATION ------------------------
+
+// Classify — PATCH /admin/news/{id}: {"category": "water", "tags": [...]};
+// "category": null возвращает автоматическую классификацию
+func (h *NewsHandler) Classify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid news id")
+		return
+	}
+
+	var patch models.NewsClassificationPatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	moderatorID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	item, err := h.Service.OverrideClassification(moderatorID, id, patch, utils.RequestMetaFromContext(r.Context()))
+	if validationErrorResponse(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, repositories.ErrNewsNotFound):
+		jsonError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrNoClassificationFields):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		jsonResponse(w, http.StatusOK, item)
+	}
 }
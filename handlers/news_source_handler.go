@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// NewsSourceHandler — админские эндпоинты управления лентами новостей
+type NewsSourceHandler struct {
+	Service *services.NewsService
+}
+
+// Sources — GET список источников со статусом, POST добавление
+func (h *NewsSourceHandler) Sources(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.Service.ListSources()
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if list == nil {
+			list = []models.NewsSource{}
+		}
+		jsonResponse(w, http.StatusOK, list)
+
+	case http.MethodPost:
+		var in models.NewsSourceInput
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&in); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+
+		adminID, err := utils.UserIDFromContext(r.Context())
+		if err != nil {
+			jsonError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		src, err := h.Service.CreateSource(adminID, in, utils.RequestMetaFromContext(r.Context()))
+		if validationErrorResponse(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrNewsSourceExists):
+			jsonError(w, http.StatusConflict, err.Error())
+		case err != nil:
+			jsonError(w, http.StatusInternalServerError, err.Error())
+		default:
+			jsonResponse(w, http.StatusCreated, src)
+		}
+
+	default:
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Update — PATCH /admin/news-sources/{id}; {"enabled": false} отключает источник
+func (h *NewsSourceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid source id")
+		return
+	}
+
+	var patch models.NewsSourcePatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	adminID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	src, err := h.Service.UpdateSource(adminID, id, patch, utils.RequestMetaFromContext(r.Context()))
+	if validationErrorResponse(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrNoSourceFields):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, repositories.ErrNewsSourceNotFound):
+		jsonError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		jsonResponse(w, http.StatusOK, src)
+	}
+}
+
+// Test — POST /admin/news-sources/{id}/test: пробный опрос без сохранения
+func (h *NewsSourceHandler) Test(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid source id")
+		return
+	}
+
+	res, err := h.Service.TestSource(id)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNewsSourceNotFound) {
+			jsonError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, res)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"dl/services"
+	"dl/utils"
+	"errors"
+	"net/http"
+)
+
+type OIDCHandler struct {
+	Service *services.OIDCService
+}
+
+// oidcStateCookie привязывает state к браузеру, начавшему вход:
+// без него callback с чужим state залогинил бы жертву в аккаунт атакующего
+const oidcStateCookie = "oidc_state"
+
+// ------------------------ START LOGIN ------------------------
+
+// Login перенаправляет пользователя на страницу входа провайдера
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	redirectURL, state, err := h.Service.StartLogin(r.Context(), r.PathValue("provider"))
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/",
+		MaxAge:   int(services.OIDCStateTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// ------------------------ CALLBACK ------------------------
+
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		jsonError(w, http.StatusBadRequest, "provider error: "+e)
+		return
+	}
+
+	code, state := q.Get("code"), q.Get("state")
+	if code == "" || state == "" {
+		jsonError(w, http.StatusBadRequest, "missing code or state")
+		return
+	}
+
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil || !utils.TokenHashEqual(c.Value, state) {
+		jsonError(w, http.StatusUnauthorized, "login was not started in this browser")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: "", Path: "/auth/oidc/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+
+	access, refresh, err := h.Service.FinishLogin(r.Context(), r.PathValue("provider"), state, code, utils.RequestMetaFromContext(r.Context()))
+	if errors.Is(err, services.ErrOIDCUnverifiedAccount) {
+		jsonError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]string{
+		"access_token":  access,
+		"refresh_token": refresh,
+	})
+}
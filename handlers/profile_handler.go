@@ -3,13 +3,14 @@ package handlers
 import (
 	"database/sql"
 	"dl/models"
+	"dl/repositories"
 	"dl/services"
 	"dl/utils"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
-	"os"
+	"strings"
 )
 
 type ProfileHandler struct {
@@ -23,6 +24,25 @@ func nullStr(v sql.NullString) string {
 	return ""
 }
 
+func profileResponse(profile *models.UserProfile) models.ProfileResponse {
+	return models.ProfileResponse{
+		ID:        profile.ID,
+		Username:  profile.Username,
+		Email:     profile.Email,
+		FirstName: nullStr(profile.FirstName),
+		LastName:  nullStr(profile.LastName),
+		Gender:    nullStr(profile.Gender),
+		BirthDate: nullStr(profile.BirthDate),
+		Bio:       nullStr(profile.Bio),
+		Avatar:    nullStr(profile.ProfilePicture),
+		Thumbs:    utils.AvatarThumbnailURLs(nullStr(profile.ProfilePicture)),
+		Rating:    profile.Rating,
+		Level:     profile.Level,  // если добавишь в модель
+		League:    profile.League, // если добавишь в модель
+		UpdatedAt: profile.UpdatedAt,
+	}
+}
+
 // ------------------------ GET PROFILE ------------------------
 
 func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
@@ -43,44 +63,34 @@ func (h *ProfileHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := models.ProfileResponse{
-		ID:        profile.ID,
-		Username:  profile.Username,
-		Email:     profile.Email,
-		FirstName: nullStr(profile.FirstName),
-		LastName:  nullStr(profile.LastName),
-		Gender:    nullStr(profile.Gender),
-		BirthDate: nullStr(profile.BirthDate),
-		Bio:       nullStr(profile.Bio),
-		Avatar:    nullStr(profile.ProfilePicture),
-		Rating:    profile.Rating,
-		Level:     profile.Level,  // если добавишь в модель
-		League:    profile.League, // если добавишь в модель
-		UpdatedAt: profile.UpdatedAt,
-	}
-
-	jsonResponse(w, http.StatusOK, resp)
+	jsonResponse(w, http.StatusOK, profileResponse(profile))
 }
 
 // ------------------------ UPDATE PROFILE ------------------------
 
+// UpdateProfile — PATCH: меняются только переданные поля, null очищает поле.
+// PUT принимается для старых клиентов: они очищали поле пустой строкой,
+// поэтому в PUT "" означает null.
 func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
 		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 
-	var data struct {
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-		Gender    string `json:"gender"`
-		Bio       string `json:"bio"`
-		BirthDate string `json:"birth_date"`
+	var patch models.ProfilePatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		jsonError(w, http.StatusBadRequest, "invalid JSON body")
-		return
+	if r.Method == http.MethodPut {
+		for _, f := range []*models.OptionalString{&patch.FirstName, &patch.LastName, &patch.Gender, &patch.Bio, &patch.BirthDate} {
+			if f.Value != nil && strings.TrimSpace(*f.Value) == "" {
+				f.Value = nil
+			}
+		}
 	}
 
 	userID, err := utils.UserIDFromContext(r.Context())
@@ -89,36 +99,73 @@ func (h *ProfileHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.UpdateProfile(userID, data.FirstName, data.LastName, data.Gender, data.Bio, data.BirthDate); err != nil {
+	if err := h.Service.UpdateProfile(userID, patch, utils.RequestMetaFromContext(r.Context())); err != nil {
+		var verr *utils.ValidationError
+		switch {
+		case errors.As(err, &verr):
+			jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  "validation failed",
+				"fields": verr.Fields,
+			})
+		case errors.Is(err, services.ErrNoProfileFields):
+			jsonError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, repositories.ErrUserNotFound):
+			jsonError(w, http.StatusNotFound, err.Error())
+		default:
+			jsonError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	profile, err := h.Service.GetProfile(userID)
+	if err != nil {
 		jsonError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, map[string]string{
-		"message": "profile updated successfully",
-	})
+	jsonResponse(w, http.StatusOK, profileResponse(profile))
 }
 
 // ------------------------ DELETE PROFILE ------------------------
 
+// DeleteProfile планирует удаление аккаунта; требует повторного ввода пароля
 func (h *ProfileHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 
+	var data struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
 	userID, err := utils.UserIDFromContext(r.Context())
 	if err != nil {
 		jsonError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
-	if err := h.Service.DeleteProfile(userID); err != nil {
-		jsonError(w, http.StatusInternalServerError, err.Error())
+	dueAt, err := h.Service.DeleteProfile(userID, data.Password, utils.RequestMetaFromContext(r.Context()))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPasswordRequired), errors.Is(err, services.ErrNoPasswordSet):
+			jsonError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrWrongPassword):
+			jsonError(w, http.StatusForbidden, err.Error())
+		default:
+			jsonError(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
-	jsonResponse(w, http.StatusOK, map[string]string{"message": "profile deleted"})
+	jsonResponse(w, http.StatusAccepted, map[string]interface{}{
+		"message":     "account deletion scheduled, log in before the due date to cancel",
+		"deletion_at": dueAt,
+	})
 }
 
 // ------------------------ UPLOAD AVATAR ------------------------
@@ -129,58 +176,46 @@ func (h *ProfileHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 10MB limit
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		jsonError(w, http.StatusBadRequest, "could not parse form")
+	// лимит на всё тело запроса (файл + поля формы)
+	r.Body = http.MaxBytesReader(w, r.Body, utils.MaxAvatarBytes+1<<20)
+	if err := r.ParseMultipartForm(utils.MaxAvatarBytes); err != nil {
+		jsonError(w, http.StatusBadRequest, "could not parse form or file too large")
 		return
 	}
 
-	file, handler, err := r.FormFile("avatar")
+	file, _, err := r.FormFile("avatar")
 	if err != nil {
 		jsonError(w, http.StatusBadRequest, "could not read file")
 		return
 	}
 	defer file.Close()
 
-	// validate image type + size
-	if err := utils.ValidateImage(file, handler); err != nil {
-		jsonError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
 	userID, err := utils.UserIDFromContext(r.Context())
 	if err != nil {
 		jsonError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
-	filename := fmt.Sprintf("user_%d_%s", userID, handler.Filename)
-	filePath := fmt.Sprintf("./uploads/users/%s", filename)
-
-	dst, err := os.Create(filePath)
+	// имя и Content-Type от клиента не используются — только содержимое
+	data, err := io.ReadAll(io.LimitReader(file, utils.MaxAvatarBytes+1))
 	if err != nil {
-		jsonError(w, http.StatusInternalServerError, "could not save file")
-		return
-	}
-	defer dst.Close()
-
-	// Reset file reader before copying (ValidateImage moved pointer)
-	file.Seek(0, 0)
-
-	if _, err := io.Copy(dst, file); err != nil {
-		jsonError(w, http.StatusInternalServerError, "failed to save image")
+		jsonError(w, http.StatusBadRequest, "could not read file")
 		return
 	}
 
-	publicPath := "/uploads/users/" + filename
-
-	if err := h.Service.UpdateProfilePicture(userID, publicPath); err != nil {
-		jsonError(w, http.StatusInternalServerError, "database update failed")
+	publicPath, thumbs, err := h.Service.UpdateAvatar(userID, data, utils.RequestMetaFromContext(r.Context()))
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidImage) {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		jsonError(w, http.StatusInternalServerError, "failed to save avatar")
 		return
 	}
 
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
-		"message": "avatar uploaded successfully",
-		"file":    publicPath,
+		"message":    "avatar uploaded successfully",
+		"file":       publicPath,
+		"thumbnails": thumbs,
 	})
 }
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+type PublicProfileHandler struct {
+	Service *services.PublicProfileService
+}
+
+// ------------------------ PUBLIC PROFILE ------------------------
+
+// Get — GET /users/{username}. Авторизация необязательна: владелец и друзья видят больше.
+func (h *PublicProfileHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	viewerID, _ := utils.UserIDFromContext(r.Context())
+
+	profile, err := h.Service.GetPublicProfile(r.PathValue("username"), viewerID)
+	if err != nil {
+		// редирект временный: через UsernameRedirectTTL имя может занять другой
+		var moved *services.UsernameMovedError
+		if errors.As(err, &moved) {
+			http.Redirect(w, r, "/users/"+url.PathEscape(moved.Username), http.StatusFound)
+			return
+		}
+		// удалённый аккаунт — тоже ErrUserNotFound
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			jsonError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, profile)
+}
+
+// ------------------------ FRIENDS ------------------------
+
+// Friends — GET /friends: друзья, входящие и исходящие заявки
+func (h *PublicProfileHandler) Friends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	list, err := h.Service.ListFriends(userID)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, list)
+}
+
+// Friend — /friends/{username}. PUT — заявка (или принять встречную),
+// DELETE — удалить из друзей, отклонить или отозвать заявку.
+func (h *PublicProfileHandler) Friend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	status := models.FriendNone
+	if r.Method == http.MethodPut {
+		status, err = h.Service.RequestFriend(userID, r.PathValue("username"))
+	} else {
+		err = h.Service.RemoveFriend(userID, r.PathValue("username"))
+	}
+
+	switch {
+	case errors.Is(err, repositories.ErrUserNotFound):
+		jsonError(w, http.StatusNotFound, "user not found")
+	case errors.Is(err, services.ErrFriendSelf):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		jsonError(w, http.StatusInternalServerError, err.Error())
+	default:
+		jsonResponse(w, http.StatusOK, map[string]string{"status": status})
+	}
+}
+
+// ------------------------ PRIVACY SETTINGS ------------------------
+
+func (h *PublicProfileHandler) GetPrivacy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	settings, err := h.Service.GetPrivacy(userID)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, settings)
+}
+
+// UpdatePrivacy — PATCH: меняются только переданные разделы
+func (h *PublicProfileHandler) UpdatePrivacy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var patch models.PrivacyPatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	userID, err := utils.UserIDFromContext(r.Context())
+	if err != nil {
+		jsonError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	settings, err := h.Service.UpdatePrivacy(userID, patch, utils.RequestMetaFromContext(r.Context()))
+	if err != nil {
+		var verr *utils.ValidationError
+		if errors.As(err, &verr) {
+			jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"error":  "validation failed",
+				"fields": verr.Fields,
+			})
+			return
+		}
+		jsonError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, settings)
+}
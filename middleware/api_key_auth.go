@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"dl/utils"
+	"net/http"
+	"strings"
+)
+
+// APIKeyAuthenticator проверяет API-ключ и возвращает владельца и scopes
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (int64, []string, error)
+}
+
+// JWTOrAPIKeyAuth принимает либо обычный JWT, либо API-ключ
+// (заголовок X-API-Key или "Authorization: Bearer <ключ>").
+// JWT всегда содержит точки, API-ключ — нет.
+func JWTOrAPIKeyAuth(keys APIKeyAuthenticator, next http.Handler) http.Handler {
+	jwtNext := JWTAuth(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if bearer != "" && !strings.Contains(bearer, ".") {
+				key = bearer
+			}
+		}
+
+		if key == "" {
+			jwtNext.ServeHTTP(w, r)
+			return
+		}
+
+		userID, scopes, err := keys.AuthenticateAPIKey(key)
+		if err != nil {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := utils.ContextWithUserID(r.Context(), userID)
+		ctx = utils.ContextWithScopes(ctx, scopes)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireScope пропускает запрос, только если у API-ключа есть scope.
+// Для JWT-сессий ограничений нет.
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !utils.HasScope(r.Context(), scope) {
+			http.Error(w, "Insufficient scope: "+scope+" required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -37,3 +37,15 @@ func JWTAuth(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// OptionalJWTAuth — как JWTAuth, но запрос без Authorization пропускается анонимно
+// (для публичных страниц, где владелец или друг видит больше)
+func OptionalJWTAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		JWTAuth(next).ServeHTTP(w, r)
+	})
+}
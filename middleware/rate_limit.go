@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"dl/utils"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitKey — по какому признаку считаем запросы
+type RateLimitKey int
+
+const (
+	KeyByIP   RateLimitKey = iota // по IP клиента
+	KeyByUser                     // по userID из JWT (если нет — по IP)
+)
+
+// RateLimitPolicy описывает лимит для конкретного маршрута
+type RateLimitPolicy struct {
+	Name   string        // имя политики, входит в ключ счётчика
+	Limit  int           // сколько запросов разрешено за окно
+	Window time.Duration // размер окна
+	KeyBy  RateLimitKey
+}
+
+// RateLimitStore хранит счётчики фиксированных окон.
+// Hit увеличивает счётчик окна windowStart и возвращает значения
+// текущего и предыдущего окон (для скользящей оценки).
+type RateLimitStore interface {
+	Hit(key string, windowStart time.Time, window time.Duration) (current, previous int, err error)
+}
+
+type RateLimiter struct {
+	Store RateLimitStore
+	// ProxyHops — сколько своих reverse proxy стоит перед приложением;
+	// 0 — X-Forwarded-For / X-Real-IP не учитываются
+	ProxyHops int
+
+	now func() time.Time
+}
+
+func NewRateLimiter(store RateLimitStore, proxyHops int) *RateLimiter {
+	return &RateLimiter{Store: store, ProxyHops: proxyHops, now: time.Now}
+}
+
+// Limit оборачивает handler политикой p.
+// Для KeyByUser middleware должен стоять внутри JWTAuth, иначе userID в контексте нет.
+func (l *RateLimiter) Limit(p RateLimitPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := l.now().UTC()
+		windowStart := now.Truncate(p.Window)
+		key := p.Name + ":" + l.clientKey(r, p.KeyBy)
+
+		current, previous, err := l.Store.Hit(key, windowStart, p.Window)
+		if err != nil {
+			// лимитер не должен ронять API — пропускаем запрос
+			log.Println("rate limit store error:", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Скользящее окно: вклад предыдущего окна убывает линейно
+		elapsed := now.Sub(windowStart)
+		weight := 1 - float64(elapsed)/float64(p.Window)
+		estimated := float64(previous)*weight + float64(current)
+
+		remaining := int(math.Floor(float64(p.Limit) - estimated))
+		if remaining < 0 {
+			remaining = 0
+		}
+		reset := int(math.Ceil((p.Window - elapsed).Seconds()))
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(p.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(reset))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", p.Limit, int(p.Window.Seconds())))
+
+		if estimated > float64(p.Limit) {
+			h.Set("Retry-After", strconv.Itoa(retryAfter(p, previous, current, elapsed)))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// retryAfter — через сколько секунд оценка опустится до лимита
+func retryAfter(p RateLimitPolicy, previous, current int, elapsed time.Duration) int {
+	// Если одного текущего окна уже хватает для превышения — ждём следующего окна
+	if previous == 0 || current >= p.Limit {
+		return int(math.Ceil((p.Window - elapsed).Seconds()))
+	}
+
+	// previous*(1 - t/window) + current <= limit  =>  t >= window*(1 - (limit-current)/previous)
+	need := time.Duration(float64(p.Window) * (1 - float64(p.Limit-current)/float64(previous)))
+	wait := need - elapsed
+	if wait < time.Second {
+		return 1
+	}
+	return int(math.Ceil(wait.Seconds()))
+}
+
+func (l *RateLimiter) clientKey(r *http.Request, by RateLimitKey) string {
+	if by == KeyByUser {
+		if id, err := utils.UserIDFromContext(r.Context()); err == nil {
+			return "user:" + strconv.FormatInt(id, 10)
+		}
+	}
+	return "ip:" + ClientIP(r, l.ProxyHops)
+}
+
+// ClientIP возвращает IP клиента. Заголовки прокси учитываются только при proxyHops > 0.
+// Каждый прокси дописывает в X-Forwarded-For адрес, с которого к нему пришли, поэтому
+// клиент — proxyHops-я запись справа; всё левее клиент мог прислать сам.
+func ClientIP(r *http.Request, proxyHops int) string {
+	if proxyHops > 0 {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			parts := strings.Split(strings.Join(xff, ","), ",")
+			if len(parts) >= proxyHops {
+				if ip := strings.TrimSpace(parts[len(parts)-proxyHops]); net.ParseIP(ip) != nil {
+					return ip
+				}
+			}
+		} else if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ------------------------ IN-MEMORY STORE ------------------------
+
+type windowCounter struct {
+	start    time.Time
+	window   time.Duration
+	current  int
+	previous int
+}
+
+// MemoryRateLimitStore — хранилище в памяти процесса (одна реплика)
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	counters  map[string]*windowCounter
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{counters: make(map[string]*windowCounter)}
+}
+
+func (s *MemoryRateLimitStore) Hit(key string, windowStart time.Time, window time.Duration) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(windowStart)
+
+	c, ok := s.counters[key]
+	switch {
+	case !ok:
+		c = &windowCounter{start: windowStart, window: window}
+		s.counters[key] = c
+	case c.start.Equal(windowStart):
+	case c.start.Add(window).Equal(windowStart):
+		c.previous, c.current, c.start = c.current, 0, windowStart
+	case windowStart.After(c.start):
+		c.previous, c.current, c.start = 0, 0, windowStart
+	}
+
+	c.current++
+	return c.current, c.previous, nil
+}
+
+// sweep удаляет счётчики, которые уже не влияют на оценку (раз в минуту)
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, c := range s.counters {
+		if now.Sub(c.start) >= 2*c.window {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"dl/utils"
+	"net/http"
+)
+
+// RequestMeta кладёт IP и User-Agent клиента в контекст (для аудита)
+func RequestMeta(proxyHops int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua := r.UserAgent()
+		if len(ua) > 512 {
+			ua = ua[:512]
+		}
+
+		ctx := utils.ContextWithRequestMeta(r.Context(), utils.RequestMeta{
+			IP:        ClientIP(r, proxyHops),
+			UserAgent: ua,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"dl/utils"
+	"net/http"
+)
+
+// RoleChecker возвращает роль пользователя (user, moderator, admin)
+type RoleChecker interface {
+	GetUserRole(userID int64) (string, error)
+}
+
+// RequireRole пропускает только пользователей с одной из ролей.
+// Должен стоять внутри JWTAuth. API-ключи к таким маршрутам не допускаются.
+func RequireRole(roles RoleChecker, next http.Handler, allowed ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := utils.UserIDFromContext(r.Context())
+		if err != nil || utils.IsAPIKeyRequest(r.Context()) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		role, err := roles.GetUserRole(userID)
+		if err != nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		for _, a := range allowed {
+			if role == a {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
@@ -0,0 +1,11 @@
+-- =============================
+-- RATE LIMIT COUNTERS (для нескольких реплик)
+-- =============================
+CREATE TABLE IF NOT EXISTS rate_limit_counters (
+    key VARCHAR(255) NOT NULL,
+    window_start TIMESTAMP NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_counters_window_idx ON rate_limit_counters (window_start);
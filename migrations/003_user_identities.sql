@@ -0,0 +1,27 @@
+-- =============================
+-- USER IDENTITIES (внешние OIDC-аккаунты)
+-- =============================
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT NOW(),
+    last_login_at TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_idx ON user_identities (user_id);
+
+
+-- =============================
+-- OIDC LOGIN STATES (state + PKCE между редиректами)
+-- =============================
+CREATE TABLE IF NOT EXISTS oidc_states (
+    state VARCHAR(255) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(255) NOT NULL,
+    code_verifier VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,14 @@
+-- =============================
+-- MAGIC LINKS (вход по ссылке из email)
+-- =============================
+CREATE TABLE IF NOT EXISTS magic_links (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,   -- sha256(token), сам токен не храним
+    device_hash VARCHAR(64) NOT NULL,         -- sha256(device id) запросившего устройства
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS magic_links_user_idx ON magic_links (user_id);
@@ -0,0 +1,28 @@
+-- =============================
+-- ROLES (для админских эндпоинтов)
+-- =============================
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+
+-- =============================
+-- AUDIT EVENTS (журнал безопасности, только добавление)
+-- =============================
+-- actor_id/target_id без внешних ключей: события должны пережить удаление пользователя
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    event_type VARCHAR(64) NOT NULL,
+    actor_id BIGINT,
+    target_id BIGINT,
+    ip VARCHAR(64),
+    user_agent TEXT,
+    metadata JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_target_idx ON audit_events (target_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_type_idx ON audit_events (event_type, created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_events_created_idx ON audit_events (created_at);
+
+-- записи не редактируются; удаление — только задачей retention
+CREATE OR REPLACE RULE audit_events_no_update AS ON UPDATE TO audit_events DO INSTEAD NOTHING;
@@ -0,0 +1,17 @@
+-- =============================
+-- DATA EXPORTS (выгрузка персональных данных)
+-- =============================
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending | running | ready | failed | expired
+    file_path TEXT,
+    size_bytes BIGINT,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS data_exports_user_idx ON data_exports (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS data_exports_status_idx ON data_exports (status);
@@ -0,0 +1,11 @@
+-- =============================
+-- ACCOUNT DELETION (мягкое удаление с отсрочкой)
+-- =============================
+-- deletion_due_at — когда аккаунт будет анонимизирован (NULL — удаление не запрошено)
+-- deleted_at      — когда аккаунт анонимизирован; строка остаётся "надгробием",
+--                   чтобы не терять user_actions / рейтинг в общей статистике
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_due_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS users_deletion_due_idx ON users (deletion_due_at)
+    WHERE deletion_due_at IS NOT NULL AND deleted_at IS NULL;
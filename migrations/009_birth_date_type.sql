@@ -0,0 +1,32 @@
+-- =============================
+-- USERS.BIRTH_DATE: VARCHAR -> DATE
+-- =============================
+-- Старые значения вводились свободным текстом. Распознаём YYYY-MM-DD,
+-- DD.MM.YYYY и DD/MM/YYYY; всё остальное (и даты в будущем) становится NULL.
+CREATE OR REPLACE FUNCTION pg_temp.parse_birth_date(v TEXT) RETURNS DATE AS $$
+DECLARE
+    d DATE;
+BEGIN
+    v := btrim(v);
+    IF v ~ '^\d{4}-\d{1,2}-\d{1,2}$' THEN
+        d := to_date(v, 'YYYY-MM-DD');
+    ELSIF v ~ '^\d{1,2}\.\d{1,2}\.\d{4}$' THEN
+        d := to_date(v, 'DD.MM.YYYY');
+    ELSIF v ~ '^\d{1,2}/\d{1,2}/\d{4}$' THEN
+        d := to_date(v, 'DD/MM/YYYY');
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    IF d > CURRENT_DATE OR d < DATE '1900-01-01' THEN
+        RETURN NULL;
+    END IF;
+    RETURN d;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE users
+    ALTER COLUMN birth_date TYPE DATE
+    USING pg_temp.parse_birth_date(birth_date::text);
@@ -0,0 +1,28 @@
+-- =============================
+-- PRIVACY SETTINGS (видимость разделов публичного профиля)
+-- =============================
+-- public | friends | private. Строки нет — действуют значения по умолчанию.
+CREATE TABLE IF NOT EXISTS user_privacy (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    about_visibility VARCHAR(10) NOT NULL DEFAULT 'public',    -- имя, фамилия, bio
+    avatar_visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    stats_visibility VARCHAR(10) NOT NULL DEFAULT 'public',    -- рейтинг, уровень, лига
+    badges_visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    activity_visibility VARCHAR(10) NOT NULL DEFAULT 'friends', -- последние действия
+    hide_from_leaderboard BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+
+-- =============================
+-- FRIENDSHIPS (взаимная дружба, одна строка на пару: user_id < friend_id)
+-- =============================
+CREATE TABLE IF NOT EXISTS friendships (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    friend_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, friend_id),
+    CHECK (user_id < friend_id)
+);
+
+CREATE INDEX IF NOT EXISTS friendships_friend_idx ON friendships (friend_id);
@@ -0,0 +1,27 @@
+-- =============================
+-- USERNAME CHANGES
+-- =============================
+-- "Скелет" имени для поиска похожих (admin / Adm1n / ad_min).
+-- Должен совпадать с utils.UsernameSkeleton.
+CREATE OR REPLACE FUNCTION username_skeleton(name TEXT) RETURNS TEXT AS $$
+    SELECT REPLACE(REPLACE(TRANSLATE(REPLACE(LOWER(name), '_', ''), '01i5', 'olls'), 'rn', 'm'), 'vv', 'w')
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE INDEX IF NOT EXISTS users_username_skeleton_idx ON users (username_skeleton(username));
+
+-- username_changed_at — время последней смены (для кулдауна)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username_changed_at TIMESTAMP;
+
+-- Старые имена: /users/<old> перенаправляет на новое имя до redirect_until.
+-- Пока редирект действует, имя (и похожие на него) не может занять другой пользователь.
+CREATE TABLE IF NOT EXISTS username_history (
+    id             BIGSERIAL PRIMARY KEY,
+    user_id        BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_username   VARCHAR(50) NOT NULL,
+    changed_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    redirect_until TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS username_history_old_idx ON username_history (LOWER(old_username));
+CREATE INDEX IF NOT EXISTS username_history_skeleton_idx ON username_history (username_skeleton(old_username));
+CREATE INDEX IF NOT EXISTS username_history_user_idx ON username_history (user_id);
@@ -0,0 +1,33 @@
+-- =============================
+-- NEWS SOURCES (RSS/Atom ленты, управляются админами)
+-- =============================
+CREATE TABLE IF NOT EXISTS news_sources (
+    id                    BIGSERIAL PRIMARY KEY,
+    url                   TEXT NOT NULL UNIQUE,
+    name                  VARCHAR(255) NOT NULL,
+    language              VARCHAR(10) NOT NULL DEFAULT 'ru',
+    category              VARCHAR(50),
+    poll_interval_minutes INT NOT NULL DEFAULT 30,
+    enabled               BOOLEAN NOT NULL DEFAULT TRUE,
+
+    -- здоровье источника
+    next_fetch_at         TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_fetched_at       TIMESTAMP,
+    last_status           VARCHAR(20),          -- ok / error
+    last_error            TEXT,
+    last_item_count       INT,
+    consecutive_failures  INT NOT NULL DEFAULT 0,
+
+    created_at            TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at            TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS news_sources_due_idx ON news_sources (next_fetch_at) WHERE enabled;
+
+-- лента, которая раньше была зашита в NewsService
+INSERT INTO news_sources (url, name, language, category)
+VALUES ('https://ecogosfond.kz/feed/', 'Ecogosfond', 'ru', 'ecology')
+ON CONFLICT (url) DO NOTHING;
+
+ALTER TABLE news ADD COLUMN IF NOT EXISTS source_id BIGINT REFERENCES news_sources(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS news_source_id_idx ON news (source_id);
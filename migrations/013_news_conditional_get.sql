@@ -0,0 +1,7 @@
+-- =============================
+-- NEWS SOURCES: условные запросы
+-- =============================
+-- Валидаторы последнего ответа ленты; отправляются как If-None-Match / If-Modified-Since,
+-- чтобы не скачивать неизменившуюся ленту заново
+ALTER TABLE news_sources ADD COLUMN IF NOT EXISTS etag TEXT;
+ALTER TABLE news_sources ADD COLUMN IF NOT EXISTS last_modified TEXT;
@@ -0,0 +1,23 @@
+-- =============================
+-- NEWS ENRICHMENT
+-- =============================
+-- description теперь хранит очищенный HTML (безопасное подмножество тегов),
+-- excerpt — текст без разметки для карточек
+ALTER TABLE news ADD COLUMN IF NOT EXISTS excerpt TEXT;
+ALTER TABLE news ADD COLUMN IF NOT EXISTS image_url TEXT;
+ALTER TABLE news ADD COLUMN IF NOT EXISTS author VARCHAR(255);
+ALTER TABLE news ADD COLUMN IF NOT EXISTS language VARCHAR(10);
+ALTER TABLE news ADD COLUMN IF NOT EXISTS category VARCHAR(50);
+
+-- Старые записи сохранены с сырым HTML ленты. Перепарсить их негде,
+-- поэтому убираем разметку целиком: текст остаётся, опасных тегов нет.
+UPDATE news
+SET description = TRIM(REGEXP_REPLACE(REGEXP_REPLACE(description, '<(script|style)[^>]*>.*?</\1>', '', 'gis'), '<[^>]*>', '', 'g')),
+    excerpt     = LEFT(TRIM(REGEXP_REPLACE(REGEXP_REPLACE(description, '<[^>]*>', ' ', 'g'), '\s+', ' ', 'g')), 300)
+WHERE excerpt IS NULL AND description IS NOT NULL;
+
+-- язык и рубрика старых новостей — от источника
+UPDATE news n
+SET language = s.language, category = COALESCE(n.category, s.category)
+FROM news_sources s
+WHERE n.source_id = s.id AND n.language IS NULL;
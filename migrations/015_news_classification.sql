@@ -0,0 +1,19 @@
+-- =============================
+-- NEWS CLASSIFICATION
+-- =============================
+-- category — одна из категорий эко-теста (water, energy, transport, food, waste) или NULL,
+-- tags — свободные теги. category_source: auto (классификатор), source (рубрика источника),
+-- moderator (выставлено вручную — классификатор такие записи больше не трогает)
+ALTER TABLE news ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE news ADD COLUMN IF NOT EXISTS category_source VARCHAR(20);
+ALTER TABLE news ADD COLUMN IF NOT EXISTS classified_at TIMESTAMP;
+ALTER TABLE news ADD COLUMN IF NOT EXISTS category_overridden_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+-- до классификатора category заполнялась рубрикой источника ("ecology" и т.п.);
+-- такие значения сбрасываем, записи переклассифицирует фоновый процесс (classified_at IS NULL)
+UPDATE news SET category = NULL
+WHERE category IS NOT NULL AND category NOT IN ('water', 'energy', 'transport', 'food', 'waste');
+
+CREATE INDEX IF NOT EXISTS news_category_idx ON news (category);
+CREATE INDEX IF NOT EXISTS news_tags_idx ON news USING GIN (tags);
+CREATE INDEX IF NOT EXISTS news_unclassified_idx ON news (id) WHERE classified_at IS NULL;
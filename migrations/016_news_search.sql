@@ -0,0 +1,22 @@
+-- =============================
+-- NEWS SEARCH
+-- =============================
+-- Полнотекстовый поиск: заголовок (вес A) и выжимка (вес B). Конфигурация — по языку
+-- новости: english для en, для остальных russian (латинские слова она стеммит по-английски).
+ALTER TABLE news ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        CASE WHEN language = 'en' THEN
+            setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+            setweight(to_tsvector('english', COALESCE(excerpt, '')), 'B')
+        ELSE
+            setweight(to_tsvector('russian', COALESCE(title, '')), 'A') ||
+            setweight(to_tsvector('russian', COALESCE(excerpt, '')), 'B')
+        END
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS news_search_idx ON news USING GIN (search_vector);
+
+-- курсорная пагинация по (published_at, id)
+CREATE INDEX IF NOT EXISTS news_published_idx ON news (published_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS news_source_published_idx ON news (source_id, published_at DESC);
+CREATE INDEX IF NOT EXISTS news_language_idx ON news (language);
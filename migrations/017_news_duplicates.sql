@@ -0,0 +1,15 @@
+-- =============================
+-- NEWS DUPLICATES
+-- =============================
+-- canonical_url — ссылка без меток (utils.CanonicalURL), simhash — отпечаток
+-- заголовка и выжимки (utils.SimHash). Дубликат ссылается на основную новость
+-- кластера через duplicate_of; у основной duplicate_of = NULL.
+-- dedup_checked_at IS NULL — ещё не проверена (в т.ч. все старые записи).
+ALTER TABLE news ADD COLUMN IF NOT EXISTS canonical_url TEXT;
+ALTER TABLE news ADD COLUMN IF NOT EXISTS simhash BIGINT;
+ALTER TABLE news ADD COLUMN IF NOT EXISTS duplicate_of BIGINT REFERENCES news(id) ON DELETE SET NULL;
+ALTER TABLE news ADD COLUMN IF NOT EXISTS dedup_checked_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS news_canonical_url_idx ON news (canonical_url);
+CREATE INDEX IF NOT EXISTS news_duplicate_of_idx ON news (duplicate_of) WHERE duplicate_of IS NOT NULL;
+CREATE INDEX IF NOT EXISTS news_dedup_pending_idx ON news (id) WHERE dedup_checked_at IS NULL;
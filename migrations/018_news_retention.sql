@@ -0,0 +1,16 @@
+-- =============================
+-- NEWS RETENTION
+-- =============================
+-- Архивная новость: остаются заголовок, ссылка, источник и дата,
+-- текст и картинка удаляются (см. NewsService.ApplyRetention)
+ALTER TABLE news ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+-- Закладки пользователей. Новости из закладок политика хранения не трогает.
+CREATE TABLE IF NOT EXISTS news_bookmarks (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    news_id BIGINT NOT NULL REFERENCES news(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, news_id)
+);
+
+CREATE INDEX IF NOT EXISTS news_bookmarks_news_idx ON news_bookmarks (news_id);
@@ -0,0 +1,20 @@
+-- =============================
+-- NEWS LIKES AND READS
+-- =============================
+-- Закладки (news_bookmarks) созданы в 018_news_retention.sql
+CREATE TABLE IF NOT EXISTS news_likes (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    news_id BIGINT NOT NULL REFERENCES news(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, news_id)
+);
+
+CREATE TABLE IF NOT EXISTS news_reads (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    news_id BIGINT NOT NULL REFERENCES news(id) ON DELETE CASCADE,
+    read_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, news_id)
+);
+
+CREATE INDEX IF NOT EXISTS news_likes_news_idx ON news_likes (news_id);
+CREATE INDEX IF NOT EXISTS news_bookmarks_user_idx ON news_bookmarks (user_id, created_at DESC);
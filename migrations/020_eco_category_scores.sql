@@ -0,0 +1,7 @@
+-- =============================
+-- ECO RESULTS BY CATEGORY
+-- =============================
+-- След по категориям эко-теста: доля от максимума (0..1), {"water": 0.8, "food": 0.35, ...}.
+-- Чем больше доля, тем слабее категория. У старых результатов NULL —
+-- разбивка считается по последним ответам (EcoRepository.GetCategoryScores).
+ALTER TABLE eco_results ADD COLUMN IF NOT EXISTS category_scores JSONB;
@@ -0,0 +1,7 @@
+-- =============================
+-- DATA EXPORTS: время взятия в работу
+-- =============================
+-- Выгрузка, которая слишком долго в статусе running (реплика упала посреди
+-- сборки), помечается failed воркером (ExportRepository.FailStaleExports).
+-- У старых строк NULL — для них берётся created_at.
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS started_at TIMESTAMP;
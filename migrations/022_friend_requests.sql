@@ -0,0 +1,12 @@
+-- =============================
+-- FRIEND REQUESTS (заявки в друзья; встречная заявка сразу создаёт дружбу)
+-- =============================
+CREATE TABLE IF NOT EXISTS friend_requests (
+    from_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (from_user_id, to_user_id),
+    CHECK (from_user_id <> to_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS friend_requests_to_idx ON friend_requests (to_user_id);
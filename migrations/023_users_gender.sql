@@ -0,0 +1,18 @@
+-- =============================
+-- USERS.GENDER: только значения из utils.Genders
+-- =============================
+-- Раньше gender вводился свободным текстом. Распознаём распространённые
+-- варианты, всё остальное становится NULL (как нераспознанные даты в 009).
+UPDATE users SET gender = CASE
+    WHEN lower(btrim(gender)) IN ('male', 'm', 'man', 'м', 'муж', 'мужской', 'мужчина') THEN 'male'
+    WHEN lower(btrim(gender)) IN ('female', 'f', 'woman', 'ж', 'жен', 'женский', 'женщина') THEN 'female'
+    WHEN lower(btrim(gender)) IN ('other', 'другой', 'другое') THEN 'other'
+    WHEN lower(regexp_replace(btrim(gender), '[\s-]+', '_', 'g')) = 'prefer_not_to_say' THEN 'prefer_not_to_say'
+    ELSE NULL
+END
+WHERE gender IS NOT NULL
+  AND gender NOT IN ('male', 'female', 'other', 'prefer_not_to_say');
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_gender_check;
+ALTER TABLE users ADD CONSTRAINT users_gender_check
+    CHECK (gender IS NULL OR gender IN ('male', 'female', 'other', 'prefer_not_to_say'));
@@ -0,0 +1,5 @@
+-- =============================
+-- MAGIC LINKS: код подтверждения для входа с другого устройства
+-- =============================
+ALTER TABLE magic_links ADD COLUMN IF NOT EXISTS confirm_code_hash VARCHAR(64); -- sha256 кода из второго письма
+ALTER TABLE magic_links ADD COLUMN IF NOT EXISTS confirm_attempts INT NOT NULL DEFAULT 0;
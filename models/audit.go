@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Типы событий журнала безопасности
+const (
+	AuditAccountCreated       = "account_created"
+	AuditEmailVerified        = "email_verified"
+	AuditLoginSuccess         = "login_success"
+	AuditLoginFailed          = "login_failed"
+	AuditPasswordResetRequest = "password_reset_requested"
+	AuditPasswordReset        = "password_reset"
+	AuditMagicLinkRequested   = "magic_link_requested"
+	AuditMagicLinkLogin       = "magic_link_login"
+	AuditOIDCLogin            = "oidc_login"
+	AuditOIDCIdentityLinked   = "oidc_identity_linked"
+	AuditProfileUpdated       = "profile_updated"
+	AuditAvatarUpdated        = "avatar_updated"
+	AuditAccountDeleted       = "account_deleted"
+	AuditDeletionScheduled    = "account_deletion_scheduled"
+	AuditDeletionCancelled    = "account_deletion_cancelled"
+	AuditPrivacyUpdated       = "privacy_updated"
+	AuditUsernameChanged      = "username_changed"
+	AuditAPIKeyCreated        = "api_key_created"
+	AuditAPIKeyRevoked        = "api_key_revoked"
+	AuditAdminAuditSearch     = "admin_audit_search"
+	AuditRetentionPurge       = "audit_retention_purge"
+	AuditNewsSourceCreated    = "news_source_created"
+	AuditNewsSourceUpdated    = "news_source_updated"
+	AuditNewsClassified       = "news_classification_changed"
+	AuditDataExportRequested  = "data_export_requested"
+	AuditDataExportDownload   = "data_export_downloaded"
+)
+
+type AuditEvent struct {
+	ID        int64                  `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	EventType string                 `json:"event_type"`
+	ActorID   *int64                 `json:"actor_id,omitempty"`
+	TargetID  *int64                 `json:"target_id,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// MetadataJSON — metadata для записи в JSONB
+func (e *AuditEvent) MetadataJSON() ([]byte, error) {
+	if e.Metadata == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(e.Metadata)
+}
+
+// AuditFilter — фильтры админского поиска
+type AuditFilter struct {
+	ActorID   *int64
+	TargetID  *int64
+	EventType string
+	IP        string
+	From      *time.Time
+	To        *time.Time
+	BeforeID  int64 // курсор: события с id < BeforeID
+	Limit     int
+}
@@ -18,4 +18,7 @@ type EcoResult struct {
 	TotalScore  int    `json:"total_score"`
 	Category    string `json:"category"`
 	Description string `json:"description"`
+
+	// доля от максимального следа по категориям вопросов (0..1)
+	CategoryScores map[string]float64 `json:"category_scores,omitempty"`
 }
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+type DataExport struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"-"`
+	Status      string     `json:"status"`
+	FilePath    string     `json:"-"`
+	SizeBytes   int64      `json:"size_bytes,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+// Строки персональных данных для выгрузки
+
+type ExportEcoAnswer struct {
+	QuestionID int64     `json:"question_id"`
+	Category   string    `json:"category"`
+	Question   string    `json:"question"`
+	Value      int       `json:"value"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ExportEcoResult struct {
+	TotalScore  int       `json:"total_score"`
+	Category    string    `json:"category"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ExportNotification struct {
+	Message   string    `json:"message"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportNewsActivity — закладка, лайк или прочтение новости
+type ExportNewsActivity struct {
+	Action    string    `json:"action"`
+	NewsID    int64     `json:"news_id"`
+	Title     string    `json:"title"`
+	Link      string    `json:"link"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -8,8 +8,126 @@ type NewsItem struct {
 	Link        string    `json:"link"`
 	PublishedAt time.Time `json:"published_at"`
 	Source      string    `json:"source"`
-	Description string    `json:"description"`
+	Description string    `json:"description"` // очищенный HTML (utils.SanitizeHTML)
+	Excerpt     string    `json:"excerpt"`     // текст без разметки для карточек
+	SourceID    int64     `json:"source_id,omitempty"`
 
-	ImageURL string `json:"image_url,omitempty"` // если RSS поддерживает
-	Category string `json:"category,omitempty"`  // если будет классификация
+	ImageURL string `json:"image_url,omitempty"` // из ленты или og:image статьи
+	Author   string `json:"author,omitempty"`
+	Language string `json:"language,omitempty"`
+
+	// классификация (см. пакет classifier)
+	Category       string   `json:"category,omitempty"`
+	Tags           []string `json:"tags"`
+	CategorySource string   `json:"category_source,omitempty"`
+
+	// дубликаты (та же новость в других лентах): у основной — список
+	// упоминаний, у дубликата — id основной
+	DuplicateOf    int64         `json:"duplicate_of,omitempty"`
+	AlsoReportedBy []NewsMention `json:"also_reported_by"`
+
+	Archived bool `json:"archived,omitempty"` // текст удалён политикой хранения
+
+	LikeCount int              `json:"like_count"`
+	Viewer    *NewsViewerState `json:"viewer,omitempty"` // только для авторизованного запроса
+
+	Explanation *NewsRankExplanation `json:"explanation,omitempty"` // только в /news/for-you
+
+	Labels []string `json:"-"` // рубрики из ленты, вход классификатора
+}
+
+// NewsMention — та же новость у другого источника
+type NewsMention struct {
+	ID     int64  `json:"id"`
+	Source string `json:"source"`
+	Link   string `json:"link"`
+}
+
+// NewsViewerState — отметки текущего пользователя
+type NewsViewerState struct {
+	Liked        bool       `json:"liked"`
+	Bookmarked   bool       `json:"bookmarked"`
+	Read         bool       `json:"read"`
+	BookmarkedAt *time.Time `json:"bookmarked_at,omitempty"`
+}
+
+// NewsRankExplanation — почему новость в подборке стоит на этом месте
+type NewsRankExplanation struct {
+	Score   float64          `json:"score"`
+	Reasons []NewsRankReason `json:"reasons"`
+}
+
+// NewsRankReason — одно слагаемое или множитель ранга
+type NewsRankReason struct {
+	Kind   string  `json:"kind"` // footprint_category | liked_tag | language | freshness | already_read
+	Value  string  `json:"value,omitempty"`
+	Weight float64 `json:"weight"`
+	Detail string  `json:"detail"`
+}
+
+// Отметки пользователя на новости
+const (
+	NewsBookmark = "bookmark"
+	NewsLike     = "like"
+	NewsRead     = "read"
+)
+
+// NewsUnreadCount — непрочитанные свежие новости, всего и по категориям
+type NewsUnreadCount struct {
+	Unread     int            `json:"unread"`
+	ByCategory map[string]int `json:"by_category"`
+	Since      time.Time      `json:"since"`
+}
+
+// NewsFingerprint — данные для поиска дубликатов
+type NewsFingerprint struct {
+	ID           int64
+	SourceID     int64
+	Title        string
+	Excerpt      string
+	Link         string
+	PublishedAt  time.Time
+	CanonicalURL string
+	SimHash      uint64
+	DuplicateOf  int64
+}
+
+// Откуда взялась категория новости
+const (
+	CategoryAuto      = "auto"
+	CategorySource    = "source"
+	CategoryModerator = "moderator"
+)
+
+// NewsClassificationPatch — ручная правка модератором.
+// category: null — вернуть автоматическую классификацию.
+type NewsClassificationPatch struct {
+	Category OptionalString `json:"category"`
+	Tags     *[]string      `json:"tags"`
+}
+
+// NewsFilter — параметры ленты новостей (GET /news)
+type NewsFilter struct {
+	SourceID int64
+	Category string
+	Tag      string
+	Language string
+	From     *time.Time
+	To       *time.Time
+	Query    string // полнотекстовый поиск; результаты по релевантности
+	Limit    int
+
+	ViewerID int64 // не фильтр: для отметок viewer в ответе
+
+	// курсор: для хронологической ленты — последняя показанная новость,
+	// для поиска — смещение (ранг не сравнить надёжно между запросами)
+	AfterPublished *time.Time
+	AfterID        int64
+	Offset         int
+}
+
+// NewsPage — страница ленты; NextCursor пустой на последней странице
+type NewsPage struct {
+	Items      []NewsItem `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Статусы последнего опроса источника
+const (
+	NewsSourceOK          = "ok"
+	NewsSourceNotModified = "not_modified" // 304: лента не менялась
+	NewsSourceError       = "error"
+)
+
+// NewsSource — RSS/Atom лента, которую опрашивает фоновый сборщик
+type NewsSource struct {
+	ID                  int64     `json:"id"`
+	URL                 string    `json:"url"`
+	Name                string    `json:"name"`
+	Language            string    `json:"language"`
+	Category            string    `json:"category,omitempty"`
+	PollIntervalMinutes int       `json:"poll_interval_minutes"`
+	Enabled             bool      `json:"enabled"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+
+	NextFetchAt         time.Time  `json:"next_fetch_at"`
+	LastFetchedAt       *time.Time `json:"last_fetched_at,omitempty"`
+	LastStatus          string     `json:"last_status,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastItemCount       *int       `json:"last_item_count,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+
+	// валидаторы последнего ответа для условного запроса
+	ETag         string `json:"-"`
+	LastModified string `json:"-"`
+}
+
+// NewsSourceInput — создание источника. PollIntervalMinutes = 0 — значение по умолчанию.
+type NewsSourceInput struct {
+	URL                 string `json:"url"`
+	Name                string `json:"name"`
+	Language            string `json:"language"`
+	Category            string `json:"category"`
+	PollIntervalMinutes int    `json:"poll_interval_minutes"`
+}
+
+// NewsSourcePatch — частичное изменение; nil — поле не меняется
+type NewsSourcePatch struct {
+	Name                *string `json:"name"`
+	Language            *string `json:"language"`
+	Category            *string `json:"category"`
+	PollIntervalMinutes *int    `json:"poll_interval_minutes"`
+	Enabled             *bool   `json:"enabled"`
+}
+
+// NewsSourceTestResult — результат пробного опроса (ничего не сохраняется)
+type NewsSourceTestResult struct {
+	OK        bool       `json:"ok"`
+	Error     string     `json:"error,omitempty"`
+	FeedTitle string     `json:"feed_title,omitempty"`
+	FeedType  string     `json:"feed_type,omitempty"`
+	ItemCount int        `json:"item_count"`
+	Sample    []NewsItem `json:"sample,omitempty"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+const (
+	VisibilityPublic  = "public"
+	VisibilityFriends = "friends"
+	VisibilityPrivate = "private"
+)
+
+type PrivacySettings struct {
+	About               string `json:"about"`
+	Avatar              string `json:"avatar"`
+	Stats               string `json:"stats"`
+	Badges              string `json:"badges"`
+	Activity            string `json:"activity"`
+	HideFromLeaderboard bool   `json:"hide_from_leaderboard"`
+}
+
+// DefaultPrivacySettings — совпадают с DEFAULT в таблице user_privacy
+func DefaultPrivacySettings() PrivacySettings {
+	return PrivacySettings{
+		About:    VisibilityPublic,
+		Avatar:   VisibilityPublic,
+		Stats:    VisibilityPublic,
+		Badges:   VisibilityPublic,
+		Activity: VisibilityFriends,
+	}
+}
+
+// PrivacyPatch — частичное обновление настроек, nil — не менять
+type PrivacyPatch struct {
+	About               *string `json:"about"`
+	Avatar              *string `json:"avatar"`
+	Stats               *string `json:"stats"`
+	Badges              *string `json:"badges"`
+	Activity            *string `json:"activity"`
+	HideFromLeaderboard *bool   `json:"hide_from_leaderboard"`
+}
+
+type Badge struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// UserActivityStats — агрегаты по user_actions для бейджей
+type UserActivityStats struct {
+	TotalActions    int
+	DistinctActions int
+	ActiveDays      int
+}
+
+// PublicProfile — то, что видят другие. Скрытые разделы не попадают в ответ (omitempty).
+type PublicProfile struct {
+	Username string `json:"username"`
+
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	Bio       string `json:"bio,omitempty"`
+
+	Avatar string            `json:"avatar,omitempty"`
+	Thumbs map[string]string `json:"avatar_thumbnails,omitempty"`
+
+	Rating *int   `json:"rating,omitempty"`
+	Level  *int   `json:"level,omitempty"`
+	League string `json:"league,omitempty"`
+
+	Badges        []Badge      `json:"badges,omitempty"`
+	RecentActions []UserAction `json:"recent_actions,omitempty"`
+
+	// HiddenSections — какие разделы скрыты от этого зрителя
+	HiddenSections []string `json:"hidden_sections,omitempty"`
+}
+
+// Состояние дружбы с другим пользователем
+const (
+	FriendNone      = "none"
+	FriendRequested = "requested" // заявка отправлена, ждёт ответа
+	FriendAccepted  = "friends"
+)
+
+// Friend — друг или заявка в друзья
+type Friend struct {
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+}
+
+// FriendList — друзья и заявки текущего пользователя
+type FriendList struct {
+	Friends  []Friend `json:"friends"`
+	Incoming []Friend `json:"incoming"` // заявки мне
+	Outgoing []Friend `json:"outgoing"` // мои заявки без ответа
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
@@ -67,9 +68,40 @@ type ProfileResponse struct {
 	Gender    string `json:"gender"`
 	Bio       string `json:"bio"`
 
-	Avatar    string    `json:"avatar"`
-	Rating    int       `json:"rating"`
-	Level     int       `json:"level"`
-	League    string    `json:"league"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Avatar    string            `json:"avatar"`
+	Thumbs    map[string]string `json:"avatar_thumbnails,omitempty"`
+	Rating    int               `json:"rating"`
+	Level     int               `json:"level"`
+	League    string            `json:"league"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// OptionalString — поле PATCH-запроса: Set=false — поле не передано,
+// Set=true и Value=nil — передан явный null (очистить значение)
+type OptionalString struct {
+	Set   bool
+	Value *string
+}
+
+func (o *OptionalString) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	o.Value = &s
+	return nil
+}
+
+// ProfilePatch — частичное обновление профиля: меняются только переданные поля
+type ProfilePatch struct {
+	FirstName OptionalString `json:"first_name"`
+	LastName  OptionalString `json:"last_name"`
+	Gender    OptionalString `json:"gender"`
+	Bio       OptionalString `json:"bio"`
+	BirthDate OptionalString `json:"birth_date"`
 }
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"database/sql"
+	"dl/models"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{DB: db}
+}
+
+// ------------------------ CREATE ------------------------
+
+func (r *APIKeyRepository) Create(key *models.APIKey, keyHash string) error {
+	return r.DB.QueryRow(`
+        INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at
+    `, key.UserID, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+}
+
+// ------------------------ LIST ------------------------
+
+func (r *APIKeyRepository) ListByUser(userID int64) ([]models.APIKey, error) {
+	rows, err := r.DB.Query(`
+        SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+        FROM api_keys
+        WHERE user_id = $1
+        ORDER BY created_at DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) CountActive(userID int64) (int, error) {
+	var n int
+	err := r.DB.QueryRow(`
+        SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL
+    `, userID).Scan(&n)
+	return n, err
+}
+
+// ------------------------ LOOKUP ------------------------
+
+func (r *APIKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	row := r.DB.QueryRow(`
+        SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+        FROM api_keys WHERE key_hash = $1
+    `, keyHash)
+
+	k, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("api key not found")
+	}
+	return k, err
+}
+
+// TouchLastUsed обновляет last_used_at не чаще раза в минуту
+func (r *APIKeyRepository) TouchLastUsed(id int64) error {
+	now := time.Now()
+	_, err := r.DB.Exec(`
+        UPDATE api_keys SET last_used_at = $2
+        WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)
+    `, id, now, now.Add(-time.Minute))
+	return err
+}
+
+// ------------------------ REVOKE ------------------------
+
+func (r *APIKeyRepository) Revoke(userID, id int64) error {
+	res, err := r.DB.Exec(`
+        UPDATE api_keys SET revoked_at = $3
+        WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+    `, id, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("api key not found")
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var (
+		k                          models.APIKey
+		expires, lastUsed, revoked sql.NullTime
+	)
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes),
+		&expires, &lastUsed, &revoked, &k.CreatedAt); err != nil {
+		return nil, err
+	}
+	k.ExpiresAt = nullTimePtr(expires)
+	k.LastUsedAt = nullTimePtr(lastUsed)
+	k.RevokedAt = nullTimePtr(revoked)
+	return &k, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
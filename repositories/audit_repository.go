@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"database/sql"
+	"dl/models"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type AuditRepository struct {
+	DB *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+// ------------------------ INSERT ------------------------
+
+func (r *AuditRepository) Insert(e *models.AuditEvent) error {
+	metadata, err := e.MetadataJSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.Exec(`
+        INSERT INTO audit_events (event_type, actor_id, target_id, ip, user_agent, metadata)
+        VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+    `, e.EventType, e.ActorID, e.TargetID, e.IP, e.UserAgent, metadata)
+	return err
+}
+
+// ------------------------ USER ACTIVITY ------------------------
+
+// ListForUser — события, где пользователь является актором или целью
+func (r *AuditRepository) ListForUser(userID int64, types []string, limit int) ([]models.AuditEvent, error) {
+	rows, err := r.DB.Query(`
+        SELECT id, created_at, event_type, actor_id, target_id,
+               COALESCE(ip, ''), COALESCE(user_agent, ''), metadata
+        FROM audit_events
+        WHERE (target_id = $1 OR actor_id = $1) AND event_type = ANY($2)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $3
+    `, userID, pq.Array(types), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows)
+}
+
+// ------------------------ ADMIN SEARCH ------------------------
+
+func (r *AuditRepository) Search(f models.AuditFilter) ([]models.AuditEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.ActorID != nil {
+		add("actor_id = $%d", *f.ActorID)
+	}
+	if f.TargetID != nil {
+		add("target_id = $%d", *f.TargetID)
+	}
+	if f.EventType != "" {
+		add("event_type = $%d", f.EventType)
+	}
+	if f.IP != "" {
+		add("ip = $%d", f.IP)
+	}
+	if f.From != nil {
+		add("created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("created_at < $%d", *f.To)
+	}
+	if f.BeforeID > 0 {
+		add("id < $%d", f.BeforeID)
+	}
+
+	query := `
+        SELECT id, created_at, event_type, actor_id, target_id,
+               COALESCE(ip, ''), COALESCE(user_agent, ''), metadata
+        FROM audit_events`
+	if len(where) > 0 {
+		query += "\n        WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit)
+	query += fmt.Sprintf("\n        ORDER BY id DESC\n        LIMIT $%d", len(args))
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditEvents(rows)
+}
+
+// ------------------------ RETENTION ------------------------
+
+func (r *AuditRepository) DeleteOlderThan(before time.Time) (int64, error) {
+	res, err := r.DB.Exec(`DELETE FROM audit_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanAuditEvents(rows *sql.Rows) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var (
+			e        models.AuditEvent
+			actor    sql.NullInt64
+			target   sql.NullInt64
+			metadata []byte
+		)
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.EventType, &actor, &target,
+			&e.IP, &e.UserAgent, &metadata); err != nil {
+			return nil, err
+		}
+		if actor.Valid {
+			e.ActorID = &actor.Int64
+		}
+		if target.Valid {
+			e.TargetID = &target.Int64
+		}
+		if len(metadata) > 0 {
+			_ = json.Unmarshal(metadata, &e.Metadata)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"database/sql"
 	"dl/models"
+	"encoding/json"
 )
 
 type EcoRepository struct {
@@ -69,11 +70,16 @@ func (r *EcoRepository) SaveAnswers(userID int64, answers map[int]int) error {
 // ---------------------------------------------------------------
 //
 
-func (r *EcoRepository) SaveResult(userID int64, total int, category, description string) error {
-	_, err := r.DB.Exec(`
-        INSERT INTO eco_results (user_id, total_score, category, description)
-        VALUES ($1, $2, $3, $4)
-    `, userID, total, category, description)
+func (r *EcoRepository) SaveResult(userID int64, total int, category, description string, scores map[string]float64) error {
+	scoresJSON, err := json.Marshal(scores)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.Exec(`
+        INSERT INTO eco_results (user_id, total_score, category, description, category_scores)
+        VALUES ($1, $2, $3, $4, $5)
+    `, userID, total, category, description, scoresJSON)
 	return err
 }
 
@@ -84,10 +90,13 @@ func (r *EcoRepository) SaveResult(userID int64, total int, category, descriptio
 //
 
 func (r *EcoRepository) GetLatestResult(userID int64) (*models.EcoResult, error) {
-	var result models.EcoResult
+	var (
+		result models.EcoResult
+		scores []byte
+	)
 
 	err := r.DB.QueryRow(`
-        SELECT total_score, category, description
+        SELECT total_score, category, description, category_scores
         FROM eco_results
         WHERE user_id = $1
         ORDER BY created_at DESC
@@ -96,12 +105,72 @@ func (r *EcoRepository) GetLatestResult(userID int64) (*models.EcoResult, error)
 		&result.TotalScore,
 		&result.Category,
 		&result.Description,
+		&scores,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if len(scores) > 0 {
+		if err := json.Unmarshal(scores, &result.CategoryScores); err != nil {
+			return nil, err
+		}
+	}
+
 	result.UserID = userID
 	return &result, nil
 }
+
+//
+// ---------------------------------------------------------------
+// FOOTPRINT BY CATEGORY
+// ---------------------------------------------------------------
+//
+
+// GetCategoryScores — след пользователя по категориям из последнего результата.
+// Для результатов без разбивки (сохранённых до неё) — по последнему ответу
+// на каждый вопрос. Пустая карта — тест не пройден.
+func (r *EcoRepository) GetCategoryScores(userID int64) (map[string]float64, error) {
+	result, err := r.GetLatestResult(userID)
+	if err == sql.ErrNoRows {
+		return map[string]float64{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result.CategoryScores) > 0 {
+		return result.CategoryScores, nil
+	}
+
+	rows, err := r.DB.Query(`
+        SELECT q.category, SUM(a.value)::float / NULLIF(SUM(q.max_value), 0)
+        FROM (
+            SELECT DISTINCT ON (question_id) question_id, value
+            FROM eco_answers
+            WHERE user_id = $1
+            ORDER BY question_id, created_at DESC
+        ) a
+        JOIN eco_questions q ON q.id = a.question_id
+        GROUP BY q.category
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := make(map[string]float64)
+	for rows.Next() {
+		var (
+			category string
+			share    sql.NullFloat64
+		)
+		if err := rows.Scan(&category, &share); err != nil {
+			return nil, err
+		}
+		if share.Valid {
+			scores[category] = share.Float64
+		}
+	}
+	return scores, rows.Err()
+}
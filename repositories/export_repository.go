@@ -0,0 +1,275 @@
+package repositories
+
+import (
+	"database/sql"
+	"dl/models"
+	"errors"
+	"time"
+)
+
+type ExportRepository struct {
+	DB *sql.DB
+}
+
+func NewExportRepository(db *sql.DB) *ExportRepository {
+	return &ExportRepository{DB: db}
+}
+
+// ErrExportNotRunning — выгрузка уже не в работе (например, снята по таймауту)
+var ErrExportNotRunning = errors.New("export is not running")
+
+const exportColumns = `id, user_id, status, COALESCE(file_path, ''), COALESCE(size_bytes, 0),
+               COALESCE(error, ''), created_at, completed_at, expires_at`
+
+func scanExport(row rowScanner) (*models.DataExport, error) {
+	var (
+		e                  models.DataExport
+		completed, expires sql.NullTime
+	)
+	if err := row.Scan(&e.ID, &e.UserID, &e.Status, &e.FilePath, &e.SizeBytes,
+		&e.Error, &e.CreatedAt, &completed, &expires); err != nil {
+		return nil, err
+	}
+	e.CompletedAt = nullTimePtr(completed)
+	e.ExpiresAt = nullTimePtr(expires)
+	return &e, nil
+}
+
+// ------------------------ JOBS ------------------------
+
+func (r *ExportRepository) CreateExport(userID int64) (*models.DataExport, error) {
+	return scanExport(r.DB.QueryRow(`
+        INSERT INTO data_exports (user_id, status) VALUES ($1, 'pending')
+        RETURNING `+exportColumns, userID))
+}
+
+// GetLatestExport — последняя выгрузка пользователя, которая ещё в работе или доступна
+func (r *ExportRepository) GetLatestExport(userID int64) (*models.DataExport, error) {
+	e, err := scanExport(r.DB.QueryRow(`
+        SELECT `+exportColumns+`
+        FROM data_exports
+        WHERE user_id = $1 AND status IN ('pending', 'running', 'ready')
+        ORDER BY created_at DESC
+        LIMIT 1
+    `, userID))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("export not found")
+	}
+	return e, err
+}
+
+func (r *ExportRepository) GetExport(id int64) (*models.DataExport, error) {
+	e, err := scanExport(r.DB.QueryRow(`
+        SELECT `+exportColumns+` FROM data_exports WHERE id = $1
+    `, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("export not found")
+	}
+	return e, err
+}
+
+// ClaimPendingExport берёт одну задачу в работу (безопасно для нескольких реплик)
+func (r *ExportRepository) ClaimPendingExport() (*models.DataExport, error) {
+	e, err := scanExport(r.DB.QueryRow(`
+        UPDATE data_exports SET status = 'running', started_at = NOW()
+        WHERE id = (
+            SELECT id FROM data_exports
+            WHERE status = 'pending'
+            ORDER BY created_at
+            LIMIT 1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + exportColumns))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+// FailStaleExports помечает failed выгрузки, взятые в работу раньше before:
+// воркер, который их собирал, уже не завершит их
+func (r *ExportRepository) FailStaleExports(before time.Time, msg string) (int64, error) {
+	res, err := r.DB.Exec(`
+        UPDATE data_exports SET status = 'failed', error = $2, completed_at = $3
+        WHERE status = 'running' AND COALESCE(started_at, created_at) < $1
+    `, before, msg, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// MarkExportReady завершает выгрузку; ErrExportNotRunning — её уже сняли по таймауту
+func (r *ExportRepository) MarkExportReady(id int64, path string, size int64, expires time.Time) error {
+	res, err := r.DB.Exec(`
+        UPDATE data_exports
+        SET status = 'ready', file_path = $2, size_bytes = $3, completed_at = $4, expires_at = $5
+        WHERE id = $1 AND status = 'running'
+    `, id, path, size, time.Now(), expires)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrExportNotRunning
+	}
+	return nil
+}
+
+func (r *ExportRepository) MarkExportFailed(id int64, msg string) error {
+	_, err := r.DB.Exec(`
+        UPDATE data_exports SET status = 'failed', error = $2, completed_at = $3 WHERE id = $1
+    `, id, msg, time.Now())
+	return err
+}
+
+// ListExpiredExports — готовые выгрузки с истёкшим сроком (их файлы надо удалить)
+func (r *ExportRepository) ListExpiredExports(now time.Time) ([]models.DataExport, error) {
+	rows, err := r.DB.Query(`
+        SELECT `+exportColumns+`
+        FROM data_exports
+        WHERE status = 'ready' AND expires_at < $1
+    `, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.DataExport
+	for rows.Next() {
+		e, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
+	}
+	return list, rows.Err()
+}
+
+func (r *ExportRepository) MarkExportExpired(id int64) error {
+	_, err := r.DB.Exec(`
+        UPDATE data_exports SET status = 'expired', file_path = NULL WHERE id = $1
+    `, id)
+	return err
+}
+
+// ListUserExportFiles — файлы всех выгрузок пользователя (ключи или старые пути на диске)
+func (r *ExportRepository) ListUserExportFiles(userID int64) ([]string, error) {
+	rows, err := r.DB.Query(`
+        SELECT file_path FROM data_exports WHERE user_id = $1 AND file_path IS NOT NULL
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var f string
+		if err := rows.Scan(&f); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// ------------------------ PERSONAL DATA ------------------------
+
+func (r *ExportRepository) GetEcoAnswers(userID int64) ([]models.ExportEcoAnswer, error) {
+	rows, err := r.DB.Query(`
+        SELECT a.question_id, q.category, q.question, a.value, a.created_at
+        FROM eco_answers a
+        JOIN eco_questions q ON q.id = a.question_id
+        WHERE a.user_id = $1
+        ORDER BY a.created_at, a.question_id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.ExportEcoAnswer{}
+	for rows.Next() {
+		var a models.ExportEcoAnswer
+		if err := rows.Scan(&a.QuestionID, &a.Category, &a.Question, &a.Value, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+func (r *ExportRepository) GetEcoResults(userID int64) ([]models.ExportEcoResult, error) {
+	rows, err := r.DB.Query(`
+        SELECT total_score, category, description, created_at
+        FROM eco_results
+        WHERE user_id = $1
+        ORDER BY created_at
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.ExportEcoResult{}
+	for rows.Next() {
+		var e models.ExportEcoResult
+		if err := rows.Scan(&e.TotalScore, &e.Category, &e.Description, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (r *ExportRepository) GetNotifications(userID int64) ([]models.ExportNotification, error) {
+	rows, err := r.DB.Query(`
+        SELECT message, COALESCE(read, false), created_at
+        FROM notifications
+        WHERE user_id = $1
+        ORDER BY created_at
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.ExportNotification{}
+	for rows.Next() {
+		var n models.ExportNotification
+		if err := rows.Scan(&n.Message, &n.Read, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+func (r *ExportRepository) GetNewsActivity(userID int64) ([]models.ExportNewsActivity, error) {
+	rows, err := r.DB.Query(`
+        SELECT a.action, n.id, n.title, n.link, a.created_at
+        FROM (
+            SELECT 'bookmark' AS action, news_id, created_at FROM news_bookmarks WHERE user_id = $1
+            UNION ALL
+            SELECT 'like', news_id, created_at FROM news_likes WHERE user_id = $1
+            UNION ALL
+            SELECT 'read', news_id, read_at FROM news_reads WHERE user_id = $1
+        ) a
+        JOIN news n ON n.id = a.news_id
+        ORDER BY a.created_at
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.ExportNewsActivity{}
+	for rows.Next() {
+		var a models.ExportNewsActivity
+		if err := rows.Scan(&a.Action, &a.NewsID, &a.Title, &a.Link, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"database/sql"
+	"dl/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// таблицы отметок; имя таблицы подставляется в SQL только отсюда
+var newsInteractionTables = map[string]string{
+	models.NewsBookmark: "news_bookmarks",
+	models.NewsLike:     "news_likes",
+	models.NewsRead:     "news_reads",
+}
+
+// SetInteraction ставит (on=true) или снимает отметку. Повторная постановка
+// ничего не меняет; несуществующая новость — ErrNewsNotFound.
+func (r *NewsRepository) SetInteraction(kind string, userID, newsID int64, on bool) error {
+	table := newsInteractionTables[kind]
+
+	if !on {
+		_, err := r.DB.Exec(`DELETE FROM `+table+` WHERE user_id = $1 AND news_id = $2`, userID, newsID)
+		return err
+	}
+
+	_, err := r.DB.Exec(`INSERT INTO `+table+` (user_id, news_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, newsID)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+		return ErrNewsNotFound
+	}
+	return err
+}
+
+// ViewerState — отметки пользователя на новостях ids
+func (r *NewsRepository) ViewerState(userID int64, ids []int64) (map[int64]models.NewsViewerState, error) {
+	rows, err := r.DB.Query(`
+        SELECT n.id,
+               EXISTS (SELECT 1 FROM news_likes l WHERE l.news_id = n.id AND l.user_id = $1),
+               b.created_at,
+               EXISTS (SELECT 1 FROM news_reads rd WHERE rd.news_id = n.id AND rd.user_id = $1)
+        FROM news n
+        LEFT JOIN news_bookmarks b ON b.news_id = n.id AND b.user_id = $1
+        WHERE n.id = ANY($2)
+    `, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	state := make(map[int64]models.NewsViewerState, len(ids))
+	for rows.Next() {
+		var (
+			id         int64
+			v          models.NewsViewerState
+			bookmarked sql.NullTime
+		)
+		if err := rows.Scan(&id, &v.Liked, &bookmarked, &v.Read); err != nil {
+			return nil, err
+		}
+		v.BookmarkedAt = nullTimePtr(bookmarked)
+		v.Bookmarked = v.BookmarkedAt != nil
+		state[id] = v
+	}
+	return state, rows.Err()
+}
+
+// ListBookmarks — закладки пользователя, новые первыми; курсор — время и id
+// последней показанной закладки. Возвращает до limit+1 записей.
+func (r *NewsRepository) ListBookmarks(userID int64, before *time.Time, beforeID int64, limit int) ([]models.NewsItem, error) {
+	if before == nil {
+		return r.queryNews(`
+        SELECT `+newsColumns+`
+        FROM news_bookmarks b
+        JOIN news n ON n.id = b.news_id
+        WHERE b.user_id = $1
+        ORDER BY b.created_at DESC, b.news_id DESC
+        LIMIT $2
+    `, userID, limit+1)
+	}
+
+	return r.queryNews(`
+        SELECT `+newsColumns+`
+        FROM news_bookmarks b
+        JOIN news n ON n.id = b.news_id
+        WHERE b.user_id = $1 AND (b.created_at, b.news_id) < ($2, $3)
+        ORDER BY b.created_at DESC, b.news_id DESC
+        LIMIT $4
+    `, userID, *before, beforeID, limit+1)
+}
+
+// UnreadCounts — непрочитанные основные (не дубликаты) неархивные новости
+// с даты since, по категориям ("" — без категории)
+func (r *NewsRepository) UnreadCounts(userID int64, since time.Time) (map[string]int, error) {
+	rows, err := r.DB.Query(`
+        SELECT COALESCE(n.category, ''), COUNT(*)
+        FROM news n
+        WHERE n.duplicate_of IS NULL AND n.archived_at IS NULL AND n.published_at >= $2
+          AND NOT EXISTS (SELECT 1 FROM news_reads rd WHERE rd.news_id = n.id AND rd.user_id = $1)
+        GROUP BY 1
+    `, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			category string
+			n        int
+		)
+		if err := rows.Scan(&category, &n); err != nil {
+			return nil, err
+		}
+		counts[category] = n
+	}
+	return counts, rows.Err()
+}
+
+// LikedTags — теги новостей, которые пользователь лайкал с даты since: тег → число лайков
+func (r *NewsRepository) LikedTags(userID int64, since time.Time, limit int) (map[string]int, error) {
+	rows, err := r.DB.Query(`
+        SELECT t.tag, COUNT(*)
+        FROM news_likes l
+        JOIN news n ON n.id = l.news_id
+        CROSS JOIN LATERAL unnest(n.tags) AS t(tag)
+        WHERE l.user_id = $1 AND l.created_at >= $2
+        GROUP BY t.tag
+        ORDER BY COUNT(*) DESC, t.tag
+        LIMIT $3
+    `, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[string]int)
+	for rows.Next() {
+		var (
+			tag string
+			n   int
+		)
+		if err := rows.Scan(&tag, &n); err != nil {
+			return nil, err
+		}
+		tags[tag] = n
+	}
+	return tags, rows.Err()
+}
+
+// PreferredLanguage — язык, на котором пользователь чаще всего лайкает,
+// сохраняет и читает новости; "" — отметок нет
+func (r *NewsRepository) PreferredLanguage(userID int64) (string, error) {
+	var lang string
+	err := r.DB.QueryRow(`
+        SELECT n.language
+        FROM (
+            SELECT news_id FROM news_likes WHERE user_id = $1
+            UNION ALL
+            SELECT news_id FROM news_bookmarks WHERE user_id = $1
+            UNION ALL
+            SELECT news_id FROM news_reads WHERE user_id = $1
+        ) a
+        JOIN news n ON n.id = a.news_id
+        WHERE n.language IS NOT NULL AND n.language <> ''
+        GROUP BY n.language
+        ORDER BY COUNT(*) DESC, n.language
+        LIMIT 1
+    `, userID).Scan(&lang)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return lang, err
+}
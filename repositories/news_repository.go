@@ -3,8 +3,17 @@ package repositories
 import (
 	"database/sql"
 	"dl/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+var ErrNewsNotFound = errors.New("news item not found")
+
 type NewsRepository struct {
 	DB *sql.DB
 }
@@ -21,8 +30,12 @@ func (r *NewsRepository) SaveNews(items []models.NewsItem) error {
 	}
 
 	stmt, err := r.DB.Prepare(`
-        INSERT INTO news (title, link, published_at, source, description)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO news (title, link, published_at, source, description, source_id,
+                          excerpt, image_url, author, language, category,
+                          tags, category_source, classified_at)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0),
+                $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''),
+                $12, NULLIF($13, ''), NOW())
         ON CONFLICT (link) DO NOTHING
     `)
 	if err != nil {
@@ -37,6 +50,14 @@ func (r *NewsRepository) SaveNews(items []models.NewsItem) error {
 			item.PublishedAt,
 			item.Source,
 			item.Description,
+			item.SourceID,
+			item.Excerpt,
+			item.ImageURL,
+			item.Author,
+			item.Language,
+			item.Category,
+			pq.Array(item.Tags),
+			item.CategorySource,
 		); err != nil {
 			// ошибка не возвращаем, чтобы не останавливать весь парсинг
 			// но собираем первую и возвращаем после цикла
@@ -47,14 +68,76 @@ func (r *NewsRepository) SaveNews(items []models.NewsItem) error {
 	return nil
 }
 
-// ------------------------ GET ALL NEWS ------------------------
+// ExistingLinks — какие из ссылок уже сохранены (чтобы не обогащать их повторно)
+func (r *NewsRepository) ExistingLinks(links []string) (map[string]bool, error) {
+	rows, err := r.DB.Query(`SELECT link FROM news WHERE link = ANY($1)`, pq.Array(links))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-func (r *NewsRepository) GetAllNews() ([]models.NewsItem, error) {
-	rows, err := r.DB.Query(`
-        SELECT id, title, link, published_at, source, description
-        FROM news
-        ORDER BY published_at DESC
-    `)
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var link string
+		if err := rows.Scan(&link); err != nil {
+			return nil, err
+		}
+		existing[link] = true
+	}
+	return existing, rows.Err()
+}
+
+// ------------------------ READ NEWS ------------------------
+
+const newsColumns = `n.id, n.title, n.link, n.published_at, COALESCE(n.source, ''), COALESCE(n.description, ''),
+        COALESCE(n.source_id, 0), COALESCE(n.excerpt, ''), COALESCE(n.image_url, ''), COALESCE(n.author, ''),
+        COALESCE(n.language, ''), COALESCE(n.category, ''), n.tags, COALESCE(n.category_source, ''),
+        COALESCE(n.duplicate_of, 0), ` + newsMentionsColumn + `, n.archived_at IS NOT NULL,
+        (SELECT COUNT(*) FROM news_likes l WHERE l.news_id = n.id)`
+
+// упоминания кластера: дубликаты этой новости, по времени публикации
+const newsMentionsColumn = `COALESCE((
+            SELECT json_agg(json_build_object('id', d.id, 'source', COALESCE(d.source, ''), 'link', d.link) ORDER BY d.published_at)
+            FROM news d WHERE d.duplicate_of = n.id), '[]')`
+
+func scanNews(row rowScanner) (*models.NewsItem, error) {
+	var (
+		n        models.NewsItem
+		mentions []byte
+	)
+	if err := row.Scan(
+		&n.ID,
+		&n.Title,
+		&n.Link,
+		&n.PublishedAt,
+		&n.Source,
+		&n.Description,
+		&n.SourceID,
+		&n.Excerpt,
+		&n.ImageURL,
+		&n.Author,
+		&n.Language,
+		&n.Category,
+		pq.Array(&n.Tags),
+		&n.CategorySource,
+		&n.DuplicateOf,
+		&mentions,
+		&n.Archived,
+		&n.LikeCount,
+	); err != nil {
+		return nil, err
+	}
+	if n.Tags == nil {
+		n.Tags = []string{}
+	}
+	if err := json.Unmarshal(mentions, &n.AlsoReportedBy); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (r *NewsRepository) queryNews(query string, args ...interface{}) ([]models.NewsItem, error) {
+	rows, err := r.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,18 +146,11 @@ func (r *NewsRepository) GetAllNews() ([]models.NewsItem, error) {
 	var news []models.NewsItem
 
 	for rows.Next() {
-		var n models.NewsItem
-		if err := rows.Scan(
-			&n.ID,
-			&n.Title,
-			&n.Link,
-			&n.PublishedAt,
-			&n.Source,
-			&n.Description,
-		); err != nil {
+		n, err := scanNews(rows)
+		if err != nil {
 			return nil, err
 		}
-		news = append(news, n)
+		news = append(news, *n)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -83,3 +159,226 @@ func (r *NewsRepository) GetAllNews() ([]models.NewsItem, error) {
 
 	return news, nil
 }
+
+// ListNews — страница ленты по фильтру. Возвращает до f.Limit+1 записей:
+// лишняя говорит сервису, что есть следующая страница.
+func (r *NewsRepository) ListNews(f models.NewsFilter) ([]models.NewsItem, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.SourceID > 0 {
+		add("n.source_id = $%d", f.SourceID)
+	}
+	if f.Category != "" {
+		add("n.category = $%d", f.Category)
+	}
+	if f.Tag != "" {
+		add("n.tags @> ARRAY[$%d]::text[]", f.Tag)
+	}
+	if f.Language != "" {
+		add("n.language = $%d", f.Language)
+	}
+	if f.From != nil {
+		add("n.published_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("n.published_at < $%d", *f.To)
+	}
+
+	// дубликаты показываются внутри основной новости (also_reported_by);
+	// в ленте одного источника — все его новости
+	if f.SourceID == 0 {
+		where = append(where, "n.duplicate_of IS NULL")
+	}
+
+	order := "n.published_at DESC, n.id DESC"
+	if f.Query != "" {
+		// запрос разбирается обеими конфигурациями: язык запроса заранее неизвестен
+		args = append(args, f.Query)
+		tsq := fmt.Sprintf("(websearch_to_tsquery('russian', $%d) || websearch_to_tsquery('english', $%d))", len(args), len(args))
+		where = append(where, "n.search_vector @@ "+tsq)
+		order = "ts_rank(n.search_vector, " + tsq + ") DESC, n.published_at DESC, n.id DESC"
+	} else if f.AfterPublished != nil {
+		args = append(args, *f.AfterPublished, f.AfterID)
+		where = append(where, fmt.Sprintf("(n.published_at, n.id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `
+        SELECT ` + newsColumns + `
+        FROM news n`
+	if len(where) > 0 {
+		query += "\n        WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit+1)
+	query += fmt.Sprintf("\n        ORDER BY %s\n        LIMIT $%d", order, len(args))
+	if f.Query != "" && f.Offset > 0 {
+		args = append(args, f.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return r.queryNews(query, args...)
+}
+
+func (r *NewsRepository) GetNewsItem(id int64) (*models.NewsItem, error) {
+	n, err := scanNews(r.DB.QueryRow(`SELECT `+newsColumns+` FROM news n WHERE n.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNewsNotFound
+	}
+	return n, err
+}
+
+// ------------------------ CLASSIFICATION ------------------------
+
+// ListUnclassified — новости без классификации (сохранённые до классификатора).
+// В Category — рубрика источника как запасной вариант.
+func (r *NewsRepository) ListUnclassified(limit int) ([]models.NewsItem, error) {
+	return r.queryNews(`
+        SELECT n.id, n.title, n.link, n.published_at, COALESCE(n.source, ''), COALESCE(n.description, ''),
+               COALESCE(n.source_id, 0), COALESCE(n.excerpt, ''), COALESCE(n.image_url, ''), COALESCE(n.author, ''),
+               COALESCE(n.language, s.language, ''), COALESCE(n.category, s.category, ''), n.tags, COALESCE(n.category_source, ''),
+               COALESCE(n.duplicate_of, 0), '[]', n.archived_at IS NOT NULL, 0
+        FROM news n
+        LEFT JOIN news_sources s ON s.id = n.source_id
+        WHERE n.classified_at IS NULL
+        ORDER BY n.id
+        LIMIT $1
+    `, limit)
+}
+
+// SetClassification — результат автоматической классификации; ручную правку модератора не трогает
+func (r *NewsRepository) SetClassification(id int64, category string, tags []string, source string) error {
+	_, err := r.DB.Exec(`
+        UPDATE news SET category = NULLIF($2, ''), tags = $3, category_source = NULLIF($4, ''), classified_at = NOW()
+        WHERE id = $1 AND category_source IS DISTINCT FROM 'moderator'
+    `, id, category, pq.Array(tags), source)
+	return err
+}
+
+// UpdateClassification — правка модератора (moderatorID = 0 — сброс к автоматической)
+func (r *NewsRepository) UpdateClassification(id int64, category string, tags []string, source string, moderatorID int64) error {
+	res, err := r.DB.Exec(`
+        UPDATE news SET category = NULLIF($2, ''), tags = $3, category_source = NULLIF($4, ''),
+               category_overridden_by = NULLIF($5, 0), classified_at = NOW()
+        WHERE id = $1
+    `, id, category, pq.Array(tags), source, moderatorID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNewsNotFound
+	}
+	return nil
+}
+
+// ------------------------ DUPLICATES ------------------------
+
+// ListUndeduplicated — новости, ещё не проверенные на дубликаты, по порядку сохранения
+func (r *NewsRepository) ListUndeduplicated(limit int) ([]models.NewsFingerprint, error) {
+	rows, err := r.DB.Query(`
+        SELECT id, COALESCE(source_id, 0), title, COALESCE(excerpt, ''), link, published_at
+        FROM news
+        WHERE dedup_checked_at IS NULL
+        ORDER BY id
+        LIMIT $1
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.NewsFingerprint{}
+	for rows.Next() {
+		var f models.NewsFingerprint
+		if err := rows.Scan(&f.ID, &f.SourceID, &f.Title, &f.Excerpt, &f.Link, &f.PublishedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
+
+// DuplicateCandidates — уже проверенные новости с той же канонической ссылкой
+// в любое время или других источников, опубликованные в [from, to]. Похожие
+// тексты одного источника (ежедневные сводки, шаблонные посты) — разные новости.
+func (r *NewsRepository) DuplicateCandidates(id, sourceID int64, canonicalURL string, from, to time.Time) ([]models.NewsFingerprint, error) {
+	rows, err := r.DB.Query(`
+        SELECT id, COALESCE(canonical_url, ''), COALESCE(simhash, 0), COALESCE(duplicate_of, 0)
+        FROM news
+        WHERE id <> $1 AND dedup_checked_at IS NOT NULL
+          AND (canonical_url = $2
+               OR (published_at BETWEEN $3 AND $4 AND COALESCE(source_id, 0) <> $5))
+        ORDER BY id
+    `, id, canonicalURL, from, to, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.NewsFingerprint{}
+	for rows.Next() {
+		var (
+			f    models.NewsFingerprint
+			hash int64
+		)
+		if err := rows.Scan(&f.ID, &f.CanonicalURL, &hash, &f.DuplicateOf); err != nil {
+			return nil, err
+		}
+		f.SimHash = uint64(hash)
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
+
+// SetFingerprint сохраняет отпечаток; duplicateOf = 0 — новость основная
+func (r *NewsRepository) SetFingerprint(id int64, canonicalURL string, simhash uint64, duplicateOf int64) error {
+	_, err := r.DB.Exec(`
+        UPDATE news SET canonical_url = $2, simhash = $3, duplicate_of = NULLIF($4, 0), dedup_checked_at = NOW()
+        WHERE id = $1
+    `, id, canonicalURL, int64(simhash), duplicateOf)
+	return err
+}
+
+// ------------------------ RETENTION ------------------------
+
+// PruneNews архивирует (archive=true) или удаляет до limit новостей вне политики хранения:
+// опубликованных раньше cutoff (nil — без ограничения по возрасту) или не входящих
+// в perSource последних новостей своего источника (0 — без ограничения).
+// Новости из закладок не трогаются.
+func (r *NewsRepository) PruneNews(cutoff *time.Time, perSource int, archive bool, limit int) (int64, error) {
+	expired := `
+        WITH expired AS (
+            SELECT r.id FROM (
+                SELECT n.id, n.published_at, n.archived_at,
+                       ROW_NUMBER() OVER (PARTITION BY n.source_id ORDER BY n.published_at DESC, n.id DESC) AS rn
+                FROM news n
+            ) r
+            WHERE (($1::timestamp IS NOT NULL AND r.published_at < $1) OR ($2 > 0 AND r.rn > $2))
+              AND NOT EXISTS (SELECT 1 FROM news_bookmarks b WHERE b.news_id = r.id)`
+
+	var query string
+	if archive {
+		query = expired + `
+              AND r.archived_at IS NULL
+            LIMIT $3
+        )
+        UPDATE news SET description = NULL, excerpt = NULL, image_url = NULL, archived_at = NOW()
+        WHERE id IN (SELECT id FROM expired)`
+	} else {
+		query = expired + `
+            LIMIT $3
+        )
+        DELETE FROM news WHERE id IN (SELECT id FROM expired)`
+	}
+
+	res, err := r.DB.Exec(query, cutoff, perSource, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"database/sql"
+	"dl/models"
+	"errors"
+	"time"
+)
+
+var ErrNewsSourceNotFound = errors.New("news source not found")
+
+const newsSourceColumns = `id, url, name, language, COALESCE(category, ''), poll_interval_minutes, enabled,
+        created_at, updated_at, next_fetch_at, last_fetched_at, COALESCE(last_status, ''),
+        COALESCE(last_error, ''), last_item_count, consecutive_failures,
+        COALESCE(etag, ''), COALESCE(last_modified, '')`
+
+func scanNewsSource(row rowScanner) (*models.NewsSource, error) {
+	var (
+		s         models.NewsSource
+		fetchedAt sql.NullTime
+		itemCount sql.NullInt64
+	)
+	if err := row.Scan(&s.ID, &s.URL, &s.Name, &s.Language, &s.Category, &s.PollIntervalMinutes, &s.Enabled,
+		&s.CreatedAt, &s.UpdatedAt, &s.NextFetchAt, &fetchedAt, &s.LastStatus,
+		&s.LastError, &itemCount, &s.ConsecutiveFailures, &s.ETag, &s.LastModified); err != nil {
+		return nil, err
+	}
+	s.LastFetchedAt = nullTimePtr(fetchedAt)
+	if itemCount.Valid {
+		n := int(itemCount.Int64)
+		s.LastItemCount = &n
+	}
+	return &s, nil
+}
+
+func (r *NewsRepository) querySources(query string, args ...interface{}) ([]models.NewsSource, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.NewsSource
+	for rows.Next() {
+		s, err := scanNewsSource(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// ------------------------ NEWS SOURCES ------------------------
+
+func (r *NewsRepository) ListSources() ([]models.NewsSource, error) {
+	return r.querySources(`SELECT ` + newsSourceColumns + ` FROM news_sources ORDER BY id`)
+}
+
+func (r *NewsRepository) GetSource(id int64) (*models.NewsSource, error) {
+	s, err := scanNewsSource(r.DB.QueryRow(`SELECT `+newsSourceColumns+` FROM news_sources WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNewsSourceNotFound
+	}
+	return s, err
+}
+
+func (r *NewsRepository) CreateSource(in models.NewsSourceInput) (*models.NewsSource, error) {
+	return scanNewsSource(r.DB.QueryRow(`
+        INSERT INTO news_sources (url, name, language, category, poll_interval_minutes)
+        VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+        RETURNING `+newsSourceColumns,
+		in.URL, in.Name, in.Language, in.Category, in.PollIntervalMinutes))
+}
+
+// UpdateSource меняет переданные поля. Включённый заново источник опрашивается сразу.
+func (r *NewsRepository) UpdateSource(id int64, p models.NewsSourcePatch) (*models.NewsSource, error) {
+	s, err := scanNewsSource(r.DB.QueryRow(`
+        UPDATE news_sources SET
+            name                  = COALESCE($2, name),
+            language              = COALESCE($3, language),
+            category              = CASE WHEN $4::text IS NULL THEN category ELSE NULLIF($4, '') END,
+            poll_interval_minutes = COALESCE($5, poll_interval_minutes),
+            next_fetch_at         = CASE WHEN $6::boolean AND NOT enabled THEN NOW() ELSE next_fetch_at END,
+            enabled               = COALESCE($6, enabled),
+            updated_at            = NOW()
+        WHERE id = $1
+        RETURNING `+newsSourceColumns,
+		id, p.Name, p.Language, p.Category, p.PollIntervalMinutes, p.Enabled))
+	if err == sql.ErrNoRows {
+		return nil, ErrNewsSourceNotFound
+	}
+	return s, err
+}
+
+// ListDueSources — включённые источники, которые пора опросить
+func (r *NewsRepository) ListDueSources(now time.Time) ([]models.NewsSource, error) {
+	return r.querySources(`
+        SELECT `+newsSourceColumns+`
+        FROM news_sources
+        WHERE enabled AND next_fetch_at <= $1
+        ORDER BY next_fetch_at
+    `, now)
+}
+
+// RecordSourceFetch сохраняет результат опроса, валидаторы ответа и время следующего опроса
+func (r *NewsRepository) RecordSourceFetch(id int64, status, errMsg string, itemCount int, etag, lastModified string, fetchedAt, nextFetchAt time.Time) error {
+	_, err := r.DB.Exec(`
+        UPDATE news_sources SET
+            last_fetched_at      = $2,
+            last_status          = $3,
+            last_error           = NULLIF($4, ''),
+            last_item_count      = $5,
+            next_fetch_at        = $6,
+            etag                 = NULLIF($7, ''),
+            last_modified        = NULLIF($8, ''),
+            consecutive_failures = CASE WHEN $3 = 'error' THEN consecutive_failures + 1 ELSE 0 END
+        WHERE id = $1
+    `, id, fetchedAt, status, errMsg, itemCount, nextFetchAt, etag, lastModified)
+	return err
+}
@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"database/sql"
+	"dl/models"
+	"time"
+)
+
+type PrivacyRepository struct {
+	DB *sql.DB
+}
+
+func NewPrivacyRepository(db *sql.DB) *PrivacyRepository {
+	return &PrivacyRepository{DB: db}
+}
+
+// ------------------------ SETTINGS ------------------------
+
+// GetPrivacy возвращает настройки пользователя или значения по умолчанию
+func (r *PrivacyRepository) GetPrivacy(userID int64) (models.PrivacySettings, error) {
+	s := models.DefaultPrivacySettings()
+	err := r.DB.QueryRow(`
+        SELECT about_visibility, avatar_visibility, stats_visibility,
+               badges_visibility, activity_visibility, hide_from_leaderboard
+        FROM user_privacy WHERE user_id = $1
+    `, userID).Scan(&s.About, &s.Avatar, &s.Stats, &s.Badges, &s.Activity, &s.HideFromLeaderboard)
+
+	if err == sql.ErrNoRows {
+		return models.DefaultPrivacySettings(), nil
+	}
+	return s, err
+}
+
+func (r *PrivacyRepository) SavePrivacy(userID int64, s models.PrivacySettings) error {
+	_, err := r.DB.Exec(`
+        INSERT INTO user_privacy (user_id, about_visibility, avatar_visibility, stats_visibility,
+                                  badges_visibility, activity_visibility, hide_from_leaderboard, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (user_id) DO UPDATE SET
+            about_visibility = EXCLUDED.about_visibility,
+            avatar_visibility = EXCLUDED.avatar_visibility,
+            stats_visibility = EXCLUDED.stats_visibility,
+            badges_visibility = EXCLUDED.badges_visibility,
+            activity_visibility = EXCLUDED.activity_visibility,
+            hide_from_leaderboard = EXCLUDED.hide_from_leaderboard,
+            updated_at = EXCLUDED.updated_at
+    `, userID, s.About, s.Avatar, s.Stats, s.Badges, s.Activity, s.HideFromLeaderboard, time.Now())
+	return err
+}
+
+// ------------------------ FRIENDS ------------------------
+
+func (r *PrivacyRepository) AreFriends(a, b int64) (bool, error) {
+	if a > b {
+		a, b = b, a
+	}
+	var exists bool
+	err := r.DB.QueryRow(`
+        SELECT EXISTS (SELECT 1 FROM friendships WHERE user_id = $1 AND friend_id = $2)
+    `, a, b).Scan(&exists)
+	return exists, err
+}
+
+// RequestFriend — заявка from -> to. Если to уже звал from в друзья,
+// заявка принимается сразу. Возвращает новое состояние (models.Friend*).
+func (r *PrivacyRepository) RequestFriend(from, to int64) (string, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        DELETE FROM friend_requests WHERE from_user_id = $1 AND to_user_id = $2
+    `, to, from)
+	if err != nil {
+		return "", err
+	}
+
+	status := models.FriendRequested
+	if n, _ := res.RowsAffected(); n > 0 {
+		a, b := from, to
+		if a > b {
+			a, b = b, a
+		}
+		_, err = tx.Exec(`
+            INSERT INTO friendships (user_id, friend_id) VALUES ($1, $2)
+            ON CONFLICT DO NOTHING
+        `, a, b)
+		status = models.FriendAccepted
+	} else {
+		// уже друзья — повторная заявка ничего не меняет
+		var friends bool
+		err = tx.QueryRow(`
+            SELECT EXISTS (SELECT 1 FROM friendships WHERE user_id = LEAST($1, $2)::bigint AND friend_id = GREATEST($1, $2)::bigint)
+        `, from, to).Scan(&friends)
+		if err != nil {
+			return "", err
+		}
+		if friends {
+			return models.FriendAccepted, nil
+		}
+		_, err = tx.Exec(`
+            INSERT INTO friend_requests (from_user_id, to_user_id) VALUES ($1, $2)
+            ON CONFLICT DO NOTHING
+        `, from, to)
+	}
+	if err != nil {
+		return "", err
+	}
+	return status, tx.Commit()
+}
+
+// RemoveFriend удаляет дружбу и заявки в обе стороны (отказ, отмена, удаление из друзей)
+func (r *PrivacyRepository) RemoveFriend(a, b int64) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	lo, hi := a, b
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	if _, err := tx.Exec(`DELETE FROM friendships WHERE user_id = $1 AND friend_id = $2`, lo, hi); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+        DELETE FROM friend_requests
+        WHERE (from_user_id = $1 AND to_user_id = $2) OR (from_user_id = $2 AND to_user_id = $1)
+    `, a, b); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListFriends — друзья и заявки в обе стороны, новые первыми (удалённые аккаунты не показываем)
+func (r *PrivacyRepository) ListFriends(userID int64) (models.FriendList, error) {
+	list := models.FriendList{Friends: []models.Friend{}, Incoming: []models.Friend{}, Outgoing: []models.Friend{}}
+
+	rows, err := r.DB.Query(`
+        SELECT k.kind, u.username, k.created_at
+        FROM (
+            SELECT 'friend' AS kind, CASE WHEN user_id = $1 THEN friend_id ELSE user_id END AS other_id, created_at
+            FROM friendships WHERE user_id = $1 OR friend_id = $1
+            UNION ALL
+            SELECT 'incoming', from_user_id, created_at FROM friend_requests WHERE to_user_id = $1
+            UNION ALL
+            SELECT 'outgoing', to_user_id, created_at FROM friend_requests WHERE from_user_id = $1
+        ) k
+        JOIN users u ON u.id = k.other_id
+        WHERE u.deleted_at IS NULL
+        ORDER BY k.created_at DESC
+    `, userID)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			kind string
+			f    models.Friend
+		)
+		if err := rows.Scan(&kind, &f.Username, &f.Since); err != nil {
+			return list, err
+		}
+		switch kind {
+		case "friend":
+			list.Friends = append(list.Friends, f)
+		case "incoming":
+			list.Incoming = append(list.Incoming, f)
+		default:
+			list.Outgoing = append(list.Outgoing, f)
+		}
+	}
+	return list, rows.Err()
+}
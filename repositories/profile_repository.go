@@ -4,9 +4,15 @@ import (
 	"database/sql"
 	"dl/models"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
+// ErrUserNotFound — пользователя нет или аккаунт удалён
+var ErrUserNotFound = errors.New("user not found")
+
 type ProfileRepository struct {
 	DB *sql.DB
 }
@@ -20,7 +26,7 @@ func NewProfileRepository(db *sql.DB) *ProfileRepository {
 func (r *ProfileRepository) GetProfileByID(userID int64) (*models.UserProfile, error) {
 	var p models.UserProfile
 	err := r.DB.QueryRow(`
-        SELECT id, username, first_name, last_name, gender, birth_date,
+        SELECT id, username, first_name, last_name, gender, to_char(birth_date, 'YYYY-MM-DD'),
                bio, profile_picture, rating, email, updated_at
         FROM users WHERE id = $1
     `, userID).Scan(
@@ -35,16 +41,91 @@ func (r *ProfileRepository) GetProfileByID(userID int64) (*models.UserProfile, e
 	return &p, err
 }
 
+// GetPublicProfileByUsername — данные для публичной страницы (без удалённых аккаунтов)
+func (r *ProfileRepository) GetPublicProfileByUsername(username string) (*models.UserProfile, error) {
+	var p models.UserProfile
+	err := r.DB.QueryRow(`
+        SELECT id, username, first_name, last_name, bio, profile_picture, rating, level, league
+        FROM users WHERE username = $1 AND deleted_at IS NULL
+    `, username).Scan(
+		&p.ID, &p.Username, &p.FirstName, &p.LastName, &p.Bio,
+		&p.ProfilePicture, &p.Rating, &p.Level, &p.League,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	return &p, err
+}
+
+// ResolveUsernameRedirect — текущее имя пользователя, который раньше носил oldUsername
+// (пока действует редирект)
+func (r *ProfileRepository) ResolveUsernameRedirect(oldUsername string, now time.Time) (string, error) {
+	var username string
+	err := r.DB.QueryRow(`
+        SELECT u.username FROM username_history h
+        JOIN users u ON u.id = h.user_id
+        WHERE LOWER(h.old_username) = LOWER($1) AND h.redirect_until > $2 AND u.deleted_at IS NULL
+        ORDER BY h.changed_at DESC
+        LIMIT 1
+    `, oldUsername, now).Scan(&username)
+
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	return username, err
+}
+
 // ------------------------ UPDATE PROFILE ------------------------
 
-func (r *ProfileRepository) UpdateProfile(userID int64, first, last, gender, bio, birth string) error {
-	_, err := r.DB.Exec(`
-        UPDATE users
-        SET first_name = $1, last_name = $2, gender = $3, bio = $4,
-            birth_date = $5, updated_at = $6
-        WHERE id = $7
-    `, first, last, gender, bio, birth, time.Now(), userID)
-	return err
+// Колонки, которые можно менять через UpdateProfileFields
+var profileColumns = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"gender":     true,
+	"bio":        true,
+	"birth_date": true,
+}
+
+// UpdateProfileFields обновляет только переданные колонки; nil — записать NULL
+func (r *ProfileRepository) UpdateProfileFields(userID int64, fields map[string]*string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	columns := make([]string, 0, len(fields))
+	for col := range fields {
+		if !profileColumns[col] {
+			return fmt.Errorf("unknown profile column %q", col)
+		}
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	sets := make([]string, 0, len(columns)+1)
+	args := make([]interface{}, 0, len(columns)+2)
+	for i, col := range columns {
+		sets = append(sets, fmt.Sprintf("%s = $%d", col, i+1))
+		if v := fields[col]; v != nil {
+			args = append(args, *v)
+		} else {
+			args = append(args, nil)
+		}
+	}
+	sets = append(sets, fmt.Sprintf("updated_at = $%d", len(args)+1))
+	args = append(args, time.Now(), userID)
+
+	res, err := r.DB.Exec(
+		`UPDATE users SET `+strings.Join(sets, ", ")+fmt.Sprintf(` WHERE id = $%d AND deleted_at IS NULL`, len(args)),
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
 }
 
 // ------------------------ UPDATE AVATAR ------------------------
@@ -56,9 +137,132 @@ func (r *ProfileRepository) UpdateAvatar(userID int64, filePath string) error {
 	return err
 }
 
-// ------------------------ DELETE ------------------------
+// ------------------------ ACCOUNT DELETION ------------------------
+
+func (r *ProfileRepository) GetPasswordHash(userID int64) ([]byte, error) {
+	var hash []byte
+	err := r.DB.QueryRow(`
+        SELECT password_hash FROM users WHERE id = $1 AND deleted_at IS NULL
+    `, userID).Scan(&hash)
 
-func (r *ProfileRepository) DeleteUser(userID int64) error {
-	_, err := r.DB.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
+	}
+	return hash, err
+}
+
+func (r *ProfileRepository) ScheduleDeletion(userID int64, dueAt time.Time) error {
+	_, err := r.DB.Exec(`
+        UPDATE users SET deletion_due_at = $1 WHERE id = $2 AND deleted_at IS NULL
+    `, dueAt, userID)
 	return err
 }
+
+// ListDueDeletions — аккаунты, у которых истёк срок отмены удаления
+func (r *ProfileRepository) ListDueDeletions(now time.Time) ([]int64, error) {
+	rows, err := r.DB.Query(`
+        SELECT id FROM users
+        WHERE deletion_due_at IS NOT NULL AND deletion_due_at <= $1 AND deleted_at IS NULL
+    `, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AnonymizeUser стирает персональные данные, оставляя строку-надгробие
+// (user_actions, eco_results и рейтинг остаются в агрегатах).
+// Возвращает пути файлов (аватар, архивы выгрузок), которые надо удалить с диска.
+func (r *ProfileRepository) AnonymizeUser(userID int64) ([]string, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var files []string
+
+	var avatar sql.NullString
+	err = tx.QueryRow(`
+        SELECT profile_picture FROM users
+        WHERE id = $1 AND deleted_at IS NULL
+        FOR UPDATE
+    `, userID).Scan(&avatar)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if avatar.Valid && avatar.String != "" {
+		files = append(files, avatar.String)
+	}
+
+	rows, err := tx.Query(`
+        SELECT file_path FROM data_exports WHERE user_id = $1 AND file_path IS NOT NULL
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, q := range []string{
+		`DELETE FROM email_verifications WHERE user_id = $1`,
+		`DELETE FROM password_resets WHERE user_id = $1`,
+		`DELETE FROM magic_links WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM api_keys WHERE user_id = $1`,
+		`DELETE FROM notifications WHERE user_id = $1`,
+		`DELETE FROM data_exports WHERE user_id = $1`,
+		`DELETE FROM friendships WHERE user_id = $1 OR friend_id = $1`,
+		`DELETE FROM friend_requests WHERE from_user_id = $1 OR to_user_id = $1`,
+		`DELETE FROM user_privacy WHERE user_id = $1`,
+		`DELETE FROM username_history WHERE user_id = $1`,
+		`DELETE FROM news_bookmarks WHERE user_id = $1`,
+		`DELETE FROM news_likes WHERE user_id = $1`,
+		`DELETE FROM news_reads WHERE user_id = $1`,
+	} {
+		if _, err := tx.Exec(q, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// username/email уникальны — надгробие получает служебные значения по id
+	_, err = tx.Exec(`
+        UPDATE users
+        SET username = 'deleted_' || id,
+            email = 'deleted_' || id || '@deleted.invalid',
+            password_hash = ''::bytea,
+            is_verified = FALSE,
+            first_name = NULL, last_name = NULL, gender = NULL,
+            birth_date = NULL, bio = NULL, profile_picture = NULL,
+            deletion_due_at = NULL, deleted_at = $2, updated_at = $2
+        WHERE id = $1
+    `, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return files, tx.Commit()
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RateLimitRepository — счётчики rate limit в Postgres (общие для всех реплик).
+// Реализует middleware.RateLimitStore.
+type RateLimitRepository struct {
+	DB *sql.DB
+}
+
+func NewRateLimitRepository(db *sql.DB) *RateLimitRepository {
+	return &RateLimitRepository{DB: db}
+}
+
+// ------------------------ HIT ------------------------
+
+func (r *RateLimitRepository) Hit(key string, windowStart time.Time, window time.Duration) (int, int, error) {
+	var current, previous int
+	err := r.DB.QueryRow(`
+        WITH cur AS (
+            INSERT INTO rate_limit_counters (key, window_start, count)
+            VALUES ($1, $2, 1)
+            ON CONFLICT (key, window_start)
+            DO UPDATE SET count = rate_limit_counters.count + 1
+            RETURNING count
+        )
+        SELECT cur.count,
+               COALESCE((SELECT count FROM rate_limit_counters
+                         WHERE key = $1 AND window_start = $3), 0)
+        FROM cur
+    `, key, windowStart.UTC(), windowStart.Add(-window).UTC()).Scan(&current, &previous)
+	return current, previous, err
+}
+
+// ------------------------ CLEANUP ------------------------
+
+func (r *RateLimitRepository) DeleteExpired(before time.Time) (int64, error) {
+	res, err := r.DB.Exec(`
+        DELETE FROM rate_limit_counters WHERE window_start < $1
+    `, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -86,12 +86,50 @@ func (r *RatingRepository) GetUserActions(userID int64) ([]models.UserAction, er
     return actions, rows.Err()
 }
 
+func (r *RatingRepository) GetRecentUserActions(userID int64, limit int) ([]models.UserAction, error) {
+    rows, err := r.DB.Query(`
+        SELECT a.name, ua.points, ua.created_at
+        FROM user_actions ua
+        JOIN eco_actions a ON ua.action_id = a.id
+        WHERE ua.user_id = $1
+        ORDER BY ua.created_at DESC
+        LIMIT $2
+    `, userID, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var actions []models.UserAction
+    for rows.Next() {
+        var a models.UserAction
+        if err := rows.Scan(&a.ActionName, &a.Points, &a.CreatedAt); err != nil {
+            return nil, err
+        }
+        actions = append(actions, a)
+    }
+    return actions, rows.Err()
+}
+
+func (r *RatingRepository) GetActivityStats(userID int64) (models.UserActivityStats, error) {
+    var s models.UserActivityStats
+    err := r.DB.QueryRow(`
+        SELECT COUNT(*), COUNT(DISTINCT action_id), COUNT(DISTINCT created_at::date)
+        FROM user_actions WHERE user_id = $1
+    `, userID).Scan(&s.TotalActions, &s.DistinctActions, &s.ActiveDays)
+    return s, err
+}
+
 // ------------------------ LEADERBOARD ------------------------
 
 func (r *RatingRepository) GetLeaderboard(limit int) ([]models.LeaderboardEntry, error) {
+    // скрывшиеся из рейтинга и удалённые аккаунты не показываем
     rows, err := r.DB.Query(`
-        SELECT username, rating, level, league
-        FROM users ORDER BY rating DESC LIMIT $1
+        SELECT u.username, u.rating, u.level, u.league
+        FROM users u
+        LEFT JOIN user_privacy p ON p.user_id = u.id
+        WHERE u.deleted_at IS NULL AND NOT COALESCE(p.hide_from_leaderboard, FALSE)
+        ORDER BY u.rating DESC LIMIT $1
     `, limit)
     if err != nil {
         return nil, err
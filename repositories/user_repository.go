@@ -47,6 +47,18 @@ func (r *UserRepository) GetUserByEmail(email string) (int64, []byte, bool, erro
 	return id, password, verified, err
 }
 
+func (r *UserRepository) GetUsernameAndEmail(userID int64) (string, string, error) {
+	var username, email string
+	err := r.DB.QueryRow(`
+        SELECT username, email FROM users WHERE id = $1
+    `, userID).Scan(&username, &email)
+
+	if err == sql.ErrNoRows {
+		return "", "", errors.New("user not found")
+	}
+	return username, email, err
+}
+
 // ------------------------ EMAIL VERIFICATION ------------------------
 
 func (r *UserRepository) StoreVerificationCode(userID int64, code string, expires time.Time) error {
@@ -119,3 +131,244 @@ func (r *UserRepository) UpdateUserPassword(userID int64, hashed []byte) error {
     `, hashed, userID)
 	return err
 }
+
+// ------------------------ EXTERNAL IDENTITIES (OIDC) ------------------------
+
+func (r *UserRepository) GetUserIDByIdentity(provider, subject string) (int64, error) {
+	var userID int64
+	err := r.DB.QueryRow(`
+        SELECT user_id FROM user_identities
+        WHERE provider = $1 AND subject = $2
+    `, provider, subject).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return 0, errors.New("identity not found")
+	}
+	return userID, err
+}
+
+func (r *UserRepository) CreateIdentity(userID int64, provider, subject, email string) error {
+	_, err := r.DB.Exec(`
+        INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+        VALUES ($1, $2, $3, $4, NOW())
+    `, userID, provider, subject, email)
+	return err
+}
+
+func (r *UserRepository) TouchIdentity(provider, subject string) error {
+	_, err := r.DB.Exec(`
+        UPDATE user_identities SET last_login_at = NOW()
+        WHERE provider = $1 AND subject = $2
+    `, provider, subject)
+	return err
+}
+
+// CreateExternalUser создаёт пользователя без пароля (вход только через провайдера).
+// Email уже подтверждён провайдером.
+func (r *UserRepository) CreateExternalUser(username, email, firstName, lastName string) (int64, error) {
+	var id int64
+	err := r.DB.QueryRow(`
+        INSERT INTO users (username, email, password_hash, is_verified, first_name, last_name)
+        VALUES ($1, $2, ''::bytea, true, NULLIF($3, ''), NULLIF($4, ''))
+        RETURNING id
+    `, username, email, firstName, lastName).Scan(&id)
+	return id, err
+}
+
+// ------------------------ OIDC STATE ------------------------
+
+func (r *UserRepository) SaveOIDCState(state, provider, nonce, codeVerifier string, expires time.Time) error {
+	_, err := r.DB.Exec(`
+        INSERT INTO oidc_states (state, provider, nonce, code_verifier, expires_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `, state, provider, nonce, codeVerifier, expires)
+	return err
+}
+
+// ConsumeOIDCState удаляет state и возвращает его данные (одноразовый)
+func (r *UserRepository) ConsumeOIDCState(state string) (provider, nonce, codeVerifier string, expires time.Time, err error) {
+	err = r.DB.QueryRow(`
+        DELETE FROM oidc_states WHERE state = $1
+        RETURNING provider, nonce, code_verifier, expires_at
+    `, state).Scan(&provider, &nonce, &codeVerifier, &expires)
+	return
+}
+
+func (r *UserRepository) DeleteExpiredOIDCStates() error {
+	_, err := r.DB.Exec(`DELETE FROM oidc_states WHERE expires_at < NOW()`)
+	return err
+}
+
+// ------------------------ MAGIC LINKS ------------------------
+
+func (r *UserRepository) CreateMagicLink(userID int64, tokenHash, deviceHash string, expires time.Time) error {
+	_, err := r.DB.Exec(`
+        INSERT INTO magic_links (user_id, token_hash, device_hash, expires_at)
+        VALUES ($1, $2, $3, $4)
+    `, userID, tokenHash, deviceHash, expires)
+	return err
+}
+
+func (r *UserRepository) GetMagicLink(tokenHash string) (int64, string, time.Time, bool, error) {
+	var (
+		userID     int64
+		deviceHash string
+		expires    time.Time
+		usedAt     sql.NullTime
+	)
+
+	err := r.DB.QueryRow(`
+        SELECT user_id, device_hash, expires_at, used_at
+        FROM magic_links WHERE token_hash = $1
+    `, tokenHash).Scan(&userID, &deviceHash, &expires, &usedAt)
+
+	return userID, deviceHash, expires, usedAt.Valid, err
+}
+
+// ConsumeMagicLink атомарно помечает ссылку использованной.
+// Возвращает ошибку, если ссылка уже использована или истекла.
+func (r *UserRepository) ConsumeMagicLink(tokenHash string) (int64, error) {
+	var userID int64
+	now := time.Now()
+	err := r.DB.QueryRow(`
+        UPDATE magic_links SET used_at = $2
+        WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+        RETURNING user_id
+    `, tokenHash, now).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return 0, errors.New("magic link expired or already used")
+	}
+	return userID, err
+}
+
+// SetMagicLinkConfirmCode сохраняет хэш кода подтверждения (предыдущий код перестаёт действовать).
+// Счётчик попыток не сбрасывается, чтобы перезапросом кода нельзя было продлить подбор.
+func (r *UserRepository) SetMagicLinkConfirmCode(tokenHash, codeHash string) error {
+	_, err := r.DB.Exec(`
+        UPDATE magic_links SET confirm_code_hash = $2
+        WHERE token_hash = $1 AND used_at IS NULL
+    `, tokenHash, codeHash)
+	return err
+}
+
+// UseMagicLinkConfirmAttempt тратит одну попытку ввода кода и возвращает хэш кода.
+// Пустая строка — кода нет или попытки закончились.
+func (r *UserRepository) UseMagicLinkConfirmAttempt(tokenHash string, maxAttempts int) (string, error) {
+	var codeHash string
+	err := r.DB.QueryRow(`
+        UPDATE magic_links SET confirm_attempts = confirm_attempts + 1
+        WHERE token_hash = $1 AND used_at IS NULL
+          AND confirm_code_hash IS NOT NULL AND confirm_attempts < $2
+        RETURNING confirm_code_hash
+    `, tokenHash, maxAttempts).Scan(&codeHash)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return codeHash, err
+}
+
+func (r *UserRepository) DeleteExpiredMagicLinks() error {
+	_, err := r.DB.Exec(`DELETE FROM magic_links WHERE expires_at < $1`, time.Now().Add(-24*time.Hour))
+	return err
+}
+
+// ------------------------ ROLE ------------------------
+
+func (r *UserRepository) GetUserRole(userID int64) (string, error) {
+	var role string
+	err := r.DB.QueryRow(`SELECT role FROM users WHERE id = $1`, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", errors.New("user not found")
+	}
+	return role, err
+}
+
+// ------------------------ ACCOUNT DELETION ------------------------
+
+// CancelDeletion снимает запланированное удаление; true — удаление было запланировано
+func (r *UserRepository) CancelDeletion(userID int64) (bool, error) {
+	res, err := r.DB.Exec(`
+        UPDATE users SET deletion_due_at = NULL
+        WHERE id = $1 AND deletion_due_at IS NOT NULL AND deleted_at IS NULL
+    `, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ------------------------ USERNAME CHANGE ------------------------
+
+// GetUsernameChange — текущее имя и время последней смены (нулевое, если не менялось)
+func (r *UserRepository) GetUsernameChange(userID int64) (string, time.Time, error) {
+	var (
+		username  string
+		changedAt sql.NullTime
+	)
+	err := r.DB.QueryRow(`
+        SELECT username, username_changed_at FROM users
+        WHERE id = $1 AND deleted_at IS NULL
+    `, userID).Scan(&username, &changedAt)
+
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, ErrUserNotFound
+	}
+	return username, changedAt.Time, err
+}
+
+// UsernameTaken — имя или похожее на него (username_skeleton) занято другим
+// пользователем либо удерживается чужим действующим редиректом
+func (r *UserRepository) UsernameTaken(username string, exceptUserID int64, now time.Time) (bool, error) {
+	var taken bool
+	err := r.DB.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM users
+            WHERE username_skeleton(username) = username_skeleton($1) AND id <> $2
+        ) OR EXISTS (
+            SELECT 1 FROM username_history
+            WHERE username_skeleton(old_username) = username_skeleton($1)
+              AND user_id <> $2 AND redirect_until > $3
+        )
+    `, username, exceptUserID, now).Scan(&taken)
+	return taken, err
+}
+
+// ChangeUsername меняет имя и записывает старое в историю для редиректа.
+// oldUsername защищает от одновременной смены из двух сессий.
+func (r *UserRepository) ChangeUsername(userID int64, oldUsername, newUsername string, redirectUntil time.Time) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+        UPDATE users SET username = $3, username_changed_at = NOW()
+        WHERE id = $1 AND username = $2 AND deleted_at IS NULL
+    `, userID, oldUsername, newUsername)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	// вернул себе одно из старых имён — редирект с него больше не нужен
+	if _, err := tx.Exec(`
+        DELETE FROM username_history WHERE user_id = $1 AND LOWER(old_username) = LOWER($2)
+    `, userID, newUsername); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+        INSERT INTO username_history (user_id, old_username, redirect_until)
+        VALUES ($1, $2, $3)
+    `, userID, oldUsername, redirectUntil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
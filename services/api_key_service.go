@@ -0,0 +1,138 @@
+package services
+
+import (
+	"dl/models"
+	"dl/repositories"
+	"dl/utils"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Префикс, по которому API-ключ отличается от JWT в заголовке Authorization
+const APIKeyPrefix = "eco_"
+
+// Доступные scopes для API-ключей
+var APIKeyScopes = map[string]bool{
+	"read:profile":     true,
+	"read:actions":     true,
+	"write:actions":    true,
+	"read:leaderboard": true,
+}
+
+const (
+	maxAPIKeysPerUser = 20
+	maxAPIKeyTTLDays  = 365
+)
+
+type APIKeyService struct {
+	Repo  *repositories.APIKeyRepository
+	Audit *AuditService
+}
+
+func NewAPIKeyService(repo *repositories.APIKeyRepository, audit *AuditService) *APIKeyService {
+	return &APIKeyService{Repo: repo, Audit: audit}
+}
+
+// --------------------------------------------------------
+// CREATE
+// --------------------------------------------------------
+
+// Create выпускает новый ключ. Полный ключ возвращается только здесь.
+func (s *APIKeyService) Create(userID int64, name string, scopes []string, expiresInDays int, meta utils.RequestMeta) (*models.CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, errors.New("name is required (max 100 characters)")
+	}
+
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	for _, sc := range scopes {
+		if !APIKeyScopes[sc] {
+			return nil, errors.New("unknown scope: " + sc)
+		}
+	}
+
+	if expiresInDays < 0 || expiresInDays > maxAPIKeyTTLDays {
+		return nil, errors.New("expires_in_days must be between 0 and 365 (0 — no expiry)")
+	}
+
+	count, err := s.Repo.CountActive(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, errors.New("too many active api keys")
+	}
+
+	// eco_<8 символов prefix>_<секрет>
+	prefix := APIKeyPrefix + strings.ToLower(utils.GenerateVerificationCode()[:8])
+	key := prefix + "_" + utils.RandomURLToken(32)
+
+	apiKey := models.APIKey{
+		UserID: userID,
+		Name:   name,
+		Prefix: prefix,
+		Scopes: scopes,
+	}
+	if expiresInDays > 0 {
+		expires := time.Now().Add(time.Duration(expiresInDays) * 24 * time.Hour)
+		apiKey.ExpiresAt = &expires
+	}
+
+	if err := s.Repo.Create(&apiKey, utils.HashToken(key)); err != nil {
+		return nil, err
+	}
+
+	s.Audit.Record(models.AuditAPIKeyCreated, userID, userID, meta, map[string]interface{}{
+		"key_id": apiKey.ID,
+		"prefix": apiKey.Prefix,
+		"scopes": apiKey.Scopes,
+	})
+
+	return &models.CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// --------------------------------------------------------
+// LIST / REVOKE
+// --------------------------------------------------------
+
+func (s *APIKeyService) List(userID int64) ([]models.APIKey, error) {
+	return s.Repo.ListByUser(userID)
+}
+
+func (s *APIKeyService) Revoke(userID, keyID int64, meta utils.RequestMeta) error {
+	if err := s.Repo.Revoke(userID, keyID); err != nil {
+		return err
+	}
+
+	s.Audit.Record(models.AuditAPIKeyRevoked, userID, userID, meta, map[string]interface{}{"key_id": keyID})
+	return nil
+}
+
+// --------------------------------------------------------
+// AUTHENTICATE (для middleware)
+// --------------------------------------------------------
+
+func (s *APIKeyService) AuthenticateAPIKey(key string) (int64, []string, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return 0, nil, errors.New("invalid api key")
+	}
+
+	apiKey, err := s.Repo.GetByHash(utils.HashToken(key))
+	if err != nil {
+		return 0, nil, errors.New("invalid api key")
+	}
+
+	if apiKey.RevokedAt != nil {
+		return 0, nil, errors.New("api key revoked")
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return 0, nil, errors.New("api key expired")
+	}
+
+	_ = s.Repo.TouchLastUsed(apiKey.ID)
+
+	return apiKey.UserID, apiKey.Scopes, nil
+}
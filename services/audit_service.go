@@ -0,0 +1,139 @@
+package services
+
+import (
+	"dl/models"
+	"dl/repositories"
+	"dl/utils"
+	"errors"
+	"log"
+	"time"
+)
+
+// События, которые пользователь видит в "recent security activity"
+var userVisibleAuditEvents = []string{
+	models.AuditAccountCreated,
+	models.AuditEmailVerified,
+	models.AuditLoginSuccess,
+	models.AuditLoginFailed,
+	models.AuditPasswordResetRequest,
+	models.AuditPasswordReset,
+	models.AuditMagicLinkRequested,
+	models.AuditMagicLinkLogin,
+	models.AuditOIDCLogin,
+	models.AuditOIDCIdentityLinked,
+	models.AuditProfileUpdated,
+	models.AuditAvatarUpdated,
+	models.AuditDeletionScheduled,
+	models.AuditDeletionCancelled,
+	models.AuditPrivacyUpdated,
+	models.AuditUsernameChanged,
+	models.AuditAPIKeyCreated,
+	models.AuditAPIKeyRevoked,
+	models.AuditDataExportRequested,
+	models.AuditDataExportDownload,
+}
+
+const (
+	recentActivityLimit = 50
+	maxAuditSearchLimit = 500
+)
+
+type AuditService struct {
+	Repo      *repositories.AuditRepository
+	Retention time.Duration
+}
+
+func NewAuditService(repo *repositories.AuditRepository, retention time.Duration) *AuditService {
+	return &AuditService{Repo: repo, Retention: retention}
+}
+
+// --------------------------------------------------------
+// RECORD
+// --------------------------------------------------------
+
+// Record пишет событие в журнал. actorID/targetID = 0 — не задан.
+// Ошибка записи не прерывает основную операцию, только логируется.
+// Безопасно вызывать на nil (аудит не подключён, например в тестах).
+func (s *AuditService) Record(eventType string, actorID, targetID int64, meta utils.RequestMeta, metadata map[string]interface{}) {
+	if s == nil {
+		return
+	}
+
+	e := &models.AuditEvent{
+		EventType: eventType,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+		Metadata:  metadata,
+	}
+	if actorID != 0 {
+		e.ActorID = &actorID
+	}
+	if targetID != 0 {
+		e.TargetID = &targetID
+	}
+
+	if err := s.Repo.Insert(e); err != nil {
+		log.Printf("audit: failed to record %s: %v", eventType, err)
+	}
+}
+
+// --------------------------------------------------------
+// USER: RECENT SECURITY ACTIVITY
+// --------------------------------------------------------
+
+func (s *AuditService) RecentActivity(userID int64) ([]models.AuditEvent, error) {
+	return s.Repo.ListForUser(userID, userVisibleAuditEvents, recentActivityLimit)
+}
+
+// --------------------------------------------------------
+// ADMIN: SEARCH
+// --------------------------------------------------------
+
+func (s *AuditService) Search(adminID int64, f models.AuditFilter, meta utils.RequestMeta) ([]models.AuditEvent, error) {
+	if f.Limit <= 0 || f.Limit > maxAuditSearchLimit {
+		f.Limit = 100
+	}
+	if f.From != nil && f.To != nil && f.To.Before(*f.From) {
+		return nil, errors.New("'to' must be after 'from'")
+	}
+
+	events, err := s.Repo.Search(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// просмотр журнала тоже журналируется
+	s.Record(models.AuditAdminAuditSearch, adminID, 0, meta, map[string]interface{}{
+		"event_type": f.EventType,
+		"actor_id":   f.ActorID,
+		"target_id":  f.TargetID,
+		"ip":         f.IP,
+		"results":    len(events),
+	})
+
+	return events, nil
+}
+
+// --------------------------------------------------------
+// RETENTION
+// --------------------------------------------------------
+
+// ApplyRetention удаляет события старше срока хранения
+func (s *AuditService) ApplyRetention() (int64, error) {
+	if s.Retention <= 0 {
+		return 0, nil
+	}
+
+	deleted, err := s.Repo.DeleteOlderThan(time.Now().Add(-s.Retention))
+	if err != nil {
+		return 0, err
+	}
+
+	if deleted > 0 {
+		s.Record(models.AuditRetentionPurge, 0, 0, utils.RequestMeta{}, map[string]interface{}{
+			"deleted":        deleted,
+			"retention_days": int(s.Retention.Hours() / 24),
+		})
+	}
+	return deleted, nil
+}
@@ -1,30 +1,47 @@
 package services
 
 import (
+	"dl/models"
 	"dl/repositories"
 	"dl/utils"
 	"errors"
+	"log"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
-	Repo *repositories.UserRepository
+	Repo   *repositories.UserRepository
+	Hasher utils.PasswordHasher
+	Audit  *AuditService
+
+	UsernameCooldown    time.Duration // как часто можно менять имя
+	UsernameRedirectTTL time.Duration // сколько старое имя ведёт на новое и недоступно другим
 }
 
-func NewAuthService(repo *repositories.UserRepository) *AuthService {
-	return &AuthService{Repo: repo}
+const (
+	DefaultUsernameCooldown    = 30 * 24 * time.Hour
+	DefaultUsernameRedirectTTL = 90 * 24 * time.Hour
+)
+
+func NewAuthService(repo *repositories.UserRepository, hasher utils.PasswordHasher, audit *AuditService) *AuthService {
+	return &AuthService{
+		Repo:                repo,
+		Hasher:              hasher,
+		Audit:               audit,
+		UsernameCooldown:    DefaultUsernameCooldown,
+		UsernameRedirectTTL: DefaultUsernameRedirectTTL,
+	}
 }
 
 // --------------------------------------------------------
 // REGISTER
 // --------------------------------------------------------
 
-func (s *AuthService) Register(username, email, password string) (string, string, error) {
+func (s *AuthService) Register(username, email, password string, meta utils.RequestMeta) (string, string, error) {
 	// trim
 	username = strings.TrimSpace(username)
 	email = strings.TrimSpace(email)
@@ -33,21 +50,30 @@ func (s *AuthService) Register(username, email, password string) (string, string
 	if err := utils.ValidateEmail(email); err != nil {
 		return "", "", err
 	}
-	if err := utils.ValidateUsername(username); err != nil {
+	if fe := utils.CheckNewUsername(username); fe != nil {
+		return "", "", &utils.ValidationError{Fields: map[string]utils.FieldError{"username": *fe}}
+	}
+	if err := utils.CheckPassword(password, username, email); err != nil {
 		return "", "", err
 	}
-	if err := utils.ValidatePassword(password); err != nil {
+
+	// похожие имена (Adm1n / admin) и имена с действующим редиректом тоже заняты
+	taken, err := s.Repo.UsernameTaken(username, 0, time.Now())
+	if err != nil {
 		return "", "", err
 	}
+	if taken {
+		return "", "", errors.New("username already exists")
+	}
 
 	// hash
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashed, err := s.Hasher.Hash(password)
 	if err != nil {
 		return "", "", err
 	}
 
 	// try to create user
-	userID, err := s.Repo.CreateUser(username, email, hashed)
+	userID, err := s.Repo.CreateUser(username, email, []byte(hashed))
 
 	// unique errors
 	if err != nil {
@@ -71,6 +97,8 @@ func (s *AuthService) Register(username, email, password string) (string, string
 		return "", "", err
 	}
 
+	s.Audit.Record(models.AuditAccountCreated, userID, userID, meta, map[string]interface{}{"method": "password"})
+
 	// send email
 	go utils.SendVerificationEmail(email, code)
 
@@ -82,22 +110,38 @@ func (s *AuthService) Register(username, email, password string) (string, string
 // LOGIN
 // --------------------------------------------------------
 
-func (s *AuthService) Login(email, password string) (string, string, error) {
+func (s *AuthService) Login(email, password string, meta utils.RequestMeta) (string, string, error) {
 	email = strings.TrimSpace(email)
 
 	userID, hashed, verified, err := s.Repo.GetUserByEmail(email)
 	if err != nil {
+		s.Audit.Record(models.AuditLoginFailed, 0, 0, meta, map[string]interface{}{"email": email, "reason": "unknown_email"})
 		return "", "", errors.New("invalid email or password")
 	}
 
-	if bcrypt.CompareHashAndPassword(hashed, []byte(password)) != nil {
+	ok, needsRehash, err := s.Hasher.Verify(password, string(hashed))
+	if err != nil || !ok {
+		s.Audit.Record(models.AuditLoginFailed, 0, userID, meta, map[string]interface{}{"reason": "wrong_password"})
 		return "", "", errors.New("invalid email or password")
 	}
 
 	if !verified {
+		s.Audit.Record(models.AuditLoginFailed, 0, userID, meta, map[string]interface{}{"reason": "email_not_verified"})
 		return "", "", errors.New("email not verified")
 	}
 
+	// хэш старым алгоритмом/параметрами — пересчитываем, пока знаем пароль
+	if needsRehash {
+		if rehashed, err := s.Hasher.Hash(password); err == nil {
+			if err := s.Repo.UpdateUserPassword(userID, []byte(rehashed)); err != nil {
+				log.Println("password rehash failed:", err)
+			}
+		}
+	}
+
+	s.Audit.Record(models.AuditLoginSuccess, userID, userID, meta, map[string]interface{}{"method": "password"})
+	cancelPendingDeletion(s.Repo, s.Audit, userID, meta)
+
 	return utils.GenerateTokens(userID)
 }
 
@@ -105,7 +149,7 @@ func (s *AuthService) Login(email, password string) (string, string, error) {
 // VERIFY EMAIL
 // --------------------------------------------------------
 
-func (s *AuthService) VerifyEmail(code string) (string, string, error) {
+func (s *AuthService) VerifyEmail(code string, meta utils.RequestMeta) (string, string, error) {
 	// userID, expires, err := s.Repo.GetUserByVerificationCode(code)
 	userID, _, err := s.Repo.GetUserByVerificationCode(code)
 	if err != nil {
@@ -123,6 +167,8 @@ func (s *AuthService) VerifyEmail(code string) (string, string, error) {
 
 	// _ = s.Repo.DeleteVerificationCode(userID)
 
+	s.Audit.Record(models.AuditEmailVerified, userID, userID, meta, nil)
+
 	return utils.GenerateTokens(userID)
 }
 
@@ -130,7 +176,7 @@ func (s *AuthService) VerifyEmail(code string) (string, string, error) {
 // REQUEST PASSWORD RESET
 // --------------------------------------------------------
 
-func (s *AuthService) RequestPasswordReset(email string) error {
+func (s *AuthService) RequestPasswordReset(email string, meta utils.RequestMeta) error {
 	userID, _, _, err := s.Repo.GetUserByEmail(email)
 	if err != nil {
 		return errors.New("user not found")
@@ -143,6 +189,8 @@ func (s *AuthService) RequestPasswordReset(email string) error {
 		return err
 	}
 
+	s.Audit.Record(models.AuditPasswordResetRequest, 0, userID, meta, nil)
+
 	resetLink := "http://localhost:5173/reset-password?token=" + token
 	go utils.SendResetPasswordEmail(email, resetLink)
 
@@ -153,7 +201,7 @@ func (s *AuthService) RequestPasswordReset(email string) error {
 // RESET PASSWORD
 // --------------------------------------------------------
 
-func (s *AuthService) ResetPassword(token, newPassword string) error {
+func (s *AuthService) ResetPassword(token, newPassword string, meta utils.RequestMeta) error {
 	userID, expires, used, err := s.Repo.GetPasswordReset(token)
 	if err != nil {
 		return errors.New("invalid or expired token")
@@ -164,21 +212,231 @@ func (s *AuthService) ResetPassword(token, newPassword string) error {
 	}
 
 	// validate new password
-	if err := utils.ValidatePassword(newPassword); err != nil {
+	username, email, err := s.Repo.GetUsernameAndEmail(userID)
+	if err != nil {
+		return err
+	}
+	if err := utils.CheckPassword(newPassword, username, email); err != nil {
 		return err
 	}
 
 	// hash new password
-	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashed, err := s.Hasher.Hash(newPassword)
 	if err != nil {
 		return err
 	}
 
 	// update password
-	if err := s.Repo.UpdateUserPassword(userID, hashed); err != nil {
+	if err := s.Repo.UpdateUserPassword(userID, []byte(hashed)); err != nil {
 		return err
 	}
 
 	// mark token as used
-	return s.Repo.MarkResetTokenUsed(token)
+	if err := s.Repo.MarkResetTokenUsed(token); err != nil {
+		return err
+	}
+
+	s.Audit.Record(models.AuditPasswordReset, userID, userID, meta, nil)
+	return nil
+}
+
+// --------------------------------------------------------
+// MAGIC LINK LOGIN
+// --------------------------------------------------------
+
+const magicLinkTTL = 15 * time.Minute
+
+const (
+	magicLinkCodeLength      = 6
+	magicLinkMaxCodeAttempts = 5
+)
+
+// ErrMagicLinkConfirmationRequired — ссылку открыли не на том устройстве,
+// с которого её запросили; на email отправлен код, нужен повторный запрос с ним.
+var ErrMagicLinkConfirmationRequired = errors.New("link was requested from another device, enter the code sent to your email")
+
+// ErrMagicLinkCodeInvalid — неверный код подтверждения или попытки закончились
+var ErrMagicLinkCodeInvalid = errors.New("invalid confirmation code")
+
+// RequestMagicLink отправляет одноразовую ссылку для входа.
+// deviceID — идентификатор устройства, запросившего ссылку.
+func (s *AuthService) RequestMagicLink(email, deviceID string, meta utils.RequestMeta) error {
+	email = strings.TrimSpace(email)
+
+	userID, _, _, err := s.Repo.GetUserByEmail(email)
+	if err != nil {
+		// не раскрываем, есть ли такой email
+		return nil
+	}
+
+	token := utils.RandomURLToken(32)
+	expires := time.Now().Add(magicLinkTTL)
+
+	if err := s.Repo.CreateMagicLink(userID, utils.HashToken(token), utils.HashToken(deviceID), expires); err != nil {
+		return err
+	}
+	_ = s.Repo.DeleteExpiredMagicLinks()
+
+	s.Audit.Record(models.AuditMagicLinkRequested, 0, userID, meta, nil)
+
+	link := "http://localhost:5173/magic-login?token=" + token
+	go utils.SendMagicLinkEmail(email, link)
+
+	return nil
+}
+
+// ConsumeMagicLink проверяет ссылку и выдаёт обычную пару токенов.
+// Если устройство не совпадает, без code на email отправляется код подтверждения
+// и возвращается ErrMagicLinkConfirmationRequired, а ссылка остаётся действительной.
+func (s *AuthService) ConsumeMagicLink(token, deviceID, code string, meta utils.RequestMeta) (string, string, error) {
+	tokenHash := utils.HashToken(token)
+
+	linkUserID, deviceHash, expires, used, err := s.Repo.GetMagicLink(tokenHash)
+	if err != nil {
+		return "", "", errors.New("invalid or expired link")
+	}
+	if used || time.Now().After(expires) {
+		return "", "", errors.New("link expired or already used")
+	}
+
+	sameDevice := utils.TokenHashEqual(deviceHash, utils.HashToken(deviceID))
+	if !sameDevice {
+		if code == "" {
+			if err := s.sendMagicLinkCode(linkUserID, tokenHash); err != nil {
+				return "", "", err
+			}
+			return "", "", ErrMagicLinkConfirmationRequired
+		}
+
+		codeHash, err := s.Repo.UseMagicLinkConfirmAttempt(tokenHash, magicLinkMaxCodeAttempts)
+		if err != nil {
+			return "", "", err
+		}
+		if codeHash == "" || !utils.TokenHashEqual(codeHash, utils.HashToken(code)) {
+			return "", "", ErrMagicLinkCodeInvalid
+		}
+	}
+
+	userID, err := s.Repo.ConsumeMagicLink(tokenHash)
+	if err != nil {
+		return "", "", err
+	}
+
+	// ссылка пришла на email — значит, он подтверждён
+	if err := s.Repo.SetUserVerified(userID); err != nil {
+		return "", "", err
+	}
+
+	s.Audit.Record(models.AuditMagicLinkLogin, userID, userID, meta, map[string]interface{}{"other_device": !sameDevice})
+	cancelPendingDeletion(s.Repo, s.Audit, userID, meta)
+
+	return utils.GenerateTokens(userID)
+}
+
+// sendMagicLinkCode отправляет владельцу ссылки код для входа с другого устройства
+func (s *AuthService) sendMagicLinkCode(userID int64, tokenHash string) error {
+	_, email, err := s.Repo.GetUsernameAndEmail(userID)
+	if err != nil {
+		return err
+	}
+
+	code := utils.RandomDigits(magicLinkCodeLength)
+	if err := s.Repo.SetMagicLinkConfirmCode(tokenHash, utils.HashToken(code)); err != nil {
+		return err
+	}
+
+	go utils.SendMagicLinkCodeEmail(email, code)
+	return nil
+}
+
+// --------------------------------------------------------
+// USERNAME CHANGE
+// --------------------------------------------------------
+
+var ErrUsernameUnchanged = errors.New("new username is the same as the current one")
+
+// UsernameCooldownError — имя менялось недавно, следующая смена возможна после Until
+type UsernameCooldownError struct {
+	Until time.Time
+}
+
+func (e *UsernameCooldownError) Error() string {
+	return "username can be changed again after " + e.Until.UTC().Format(time.RFC3339)
+}
+
+// UsernameChange — результат смены имени
+type UsernameChange struct {
+	Username         string    `json:"username"`
+	PreviousUsername string    `json:"previous_username"`
+	RedirectUntil    time.Time `json:"redirect_until"`
+	NextChangeAt     time.Time `json:"next_change_at"`
+}
+
+// ChangeUsername меняет имя с учётом кулдауна. Старое имя ещё UsernameRedirectTTL
+// ведёт на профиль и не может быть занято другим пользователем.
+func (s *AuthService) ChangeUsername(userID int64, newUsername string, meta utils.RequestMeta) (*UsernameChange, error) {
+	newUsername = strings.TrimSpace(newUsername)
+
+	if fe := utils.CheckNewUsername(newUsername); fe != nil {
+		return nil, &utils.ValidationError{Fields: map[string]utils.FieldError{"username": *fe}}
+	}
+
+	current, changedAt, err := s.Repo.GetUsernameChange(userID)
+	if err != nil {
+		return nil, err
+	}
+	if newUsername == current {
+		return nil, ErrUsernameUnchanged
+	}
+
+	now := time.Now()
+	if !changedAt.IsZero() {
+		if until := changedAt.Add(s.UsernameCooldown); now.Before(until) {
+			return nil, &UsernameCooldownError{Until: until}
+		}
+	}
+
+	taken, err := s.Repo.UsernameTaken(newUsername, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, &utils.ValidationError{Fields: map[string]utils.FieldError{
+			"username": {Code: utils.FieldTaken, Message: "username is already taken or too similar to an existing one"},
+		}}
+	}
+
+	redirectUntil := now.Add(s.UsernameRedirectTTL)
+	if err := s.Repo.ChangeUsername(userID, current, newUsername, redirectUntil); err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return nil, &utils.ValidationError{Fields: map[string]utils.FieldError{
+				"username": {Code: utils.FieldTaken, Message: "username is already taken"},
+			}}
+		}
+		return nil, err
+	}
+
+	s.Audit.Record(models.AuditUsernameChanged, userID, userID, meta, map[string]interface{}{
+		"old_username": current,
+		"new_username": newUsername,
+	})
+
+	return &UsernameChange{
+		Username:         newUsername,
+		PreviousUsername: current,
+		RedirectUntil:    redirectUntil,
+		NextChangeAt:     now.Add(s.UsernameCooldown),
+	}, nil
+}
+
+// cancelPendingDeletion — вход в аккаунт отменяет запланированное удаление
+func cancelPendingDeletion(repo *repositories.UserRepository, audit *AuditService, userID int64, meta utils.RequestMeta) {
+	cancelled, err := repo.CancelDeletion(userID)
+	if err != nil {
+		log.Println("cancel account deletion failed:", err)
+		return
+	}
+	if cancelled {
+		audit.Record(models.AuditDeletionCancelled, userID, userID, meta, nil)
+	}
 }
@@ -24,25 +24,55 @@ func (s *EcoService) SubmitAnswers(userID int64, answers map[int]int) (*models.E
 	// Determine category
 	category, description := utils.CalculateEcoCategory(total)
 
+	// Footprint by question category
+	questions, err := s.Repo.GetQuestions()
+	if err != nil {
+		return nil, err
+	}
+	scores := categoryScores(questions, answers)
+
 	// Save answers
 	if err := s.Repo.SaveAnswers(userID, answers); err != nil {
 		return nil, err
 	}
 
 	// Save result
-	if err := s.Repo.SaveResult(userID, total, category, description); err != nil {
+	if err := s.Repo.SaveResult(userID, total, category, description, scores); err != nil {
 		return nil, err
 	}
 
 	// Return result
 	return &models.EcoResult{
-		UserID:      userID,
-		TotalScore:  total,
-		Category:    category,
-		Description: description,
+		UserID:         userID,
+		TotalScore:     total,
+		Category:       category,
+		Description:    description,
+		CategoryScores: scores,
 	}, nil
 }
 
+// categoryScores — доля набранных баллов от максимума по категориям вопросов
+func categoryScores(questions []models.EcoQuestion, answers map[int]int) map[string]float64 {
+	sum := make(map[string]int)
+	possible := make(map[string]int)
+	for _, q := range questions {
+		v, ok := answers[q.ID]
+		if !ok {
+			continue
+		}
+		sum[q.Category] += v
+		possible[q.Category] += q.MaxValue
+	}
+
+	scores := make(map[string]float64, len(sum))
+	for c, m := range possible {
+		if m > 0 {
+			scores[c] = float64(sum[c]) / float64(m)
+		}
+	}
+	return scores
+}
+
 func (s *EcoService) GetLatest(userID int64) (*models.EcoResult, error) {
 	return s.Repo.GetLatestResult(userID)
 }
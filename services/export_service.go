@@ -0,0 +1,543 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"dl/models"
+	"dl/repositories"
+	"dl/storage"
+	"dl/utils"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exportLinkTTL      = time.Hour          // срок действия подписанной ссылки
+	exportRedirectTTL  = 5 * time.Minute    // ссылка хранилища при редиректе, только чтобы начать скачивание
+	exportRetention    = 7 * 24 * time.Hour // сколько хранится готовый архив
+	exportCooldown     = 24 * time.Hour     // не чаще одной выгрузки в сутки
+	exportPollInterval = 30 * time.Second
+	exportRunTimeout   = 30 * time.Minute // дольше в running — воркер, собиравший архив, упал
+)
+
+var ErrExportNotReady = errors.New("export is not ready")
+
+// ExportService — асинхронная выгрузка персональных данных пользователя в ZIP
+type ExportService struct {
+	Repo     *repositories.ExportRepository
+	Profiles *repositories.ProfileRepository
+	Ratings  *repositories.RatingRepository
+	Signer   *utils.URLSigner
+	Storage  storage.Storage // и аватары, и готовые архивы (ключ exports/...)
+	Audit    *AuditService
+
+	// LegacyExportsDir — каталог EXPORTS_DIR, где архивы лежали до перехода на Storage:
+	// в file_path таких выгрузок путь на диске, а не ключ. Пусто — не искать.
+	LegacyExportsDir string
+
+	wake chan struct{}
+}
+
+func NewExportService(repo *repositories.ExportRepository, profiles *repositories.ProfileRepository,
+	ratings *repositories.RatingRepository, signer *utils.URLSigner, store storage.Storage, audit *AuditService) *ExportService {
+	return &ExportService{
+		Repo:     repo,
+		Profiles: profiles,
+		Ratings:  ratings,
+		Signer:   signer,
+		Storage:  store,
+		Audit:    audit,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// --------------------------------------------------------
+// REQUEST / STATUS
+// --------------------------------------------------------
+
+// RequestExport ставит выгрузку в очередь. Если есть незавершённая или
+// свежая готовая выгрузка — возвращает её вместо новой.
+func (s *ExportService) RequestExport(userID int64, meta utils.RequestMeta) (*models.DataExport, error) {
+	if e, err := s.Repo.GetLatestExport(userID); err == nil {
+		if e.Status != models.ExportReady || time.Since(e.CreatedAt) < exportCooldown {
+			s.attachDownloadURL(e)
+			return e, nil
+		}
+	}
+
+	e, err := s.Repo.CreateExport(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	s.Audit.Record(models.AuditDataExportRequested, userID, userID, meta, map[string]interface{}{"export_id": e.ID})
+	return e, nil
+}
+
+func (s *ExportService) GetStatus(userID int64) (*models.DataExport, error) {
+	e, err := s.Repo.GetLatestExport(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.attachDownloadURL(e)
+	return e, nil
+}
+
+// attachDownloadURL выдаёт новую подписанную ссылку на готовый архив
+func (s *ExportService) attachDownloadURL(e *models.DataExport) {
+	if e.Status != models.ExportReady {
+		return
+	}
+
+	expires := time.Now().Add(exportLinkTTL)
+	if e.ExpiresAt != nil && e.ExpiresAt.Before(expires) {
+		expires = *e.ExpiresAt
+	}
+
+	sig := s.Signer.Sign(exportResource(e.ID), expires)
+	e.DownloadURL = fmt.Sprintf("/export-data/download?id=%d&expires=%d&sig=%s", e.ID, expires.Unix(), sig)
+}
+
+func exportResource(id int64) string {
+	return "export:" + strconv.FormatInt(id, 10)
+}
+
+// --------------------------------------------------------
+// DOWNLOAD
+// --------------------------------------------------------
+
+// OpenDownload проверяет подпись ссылки и открывает архив. Поток закрывает вызывающий.
+func (s *ExportService) OpenDownload(id, expires int64, sig string, meta utils.RequestMeta) (io.ReadCloser, *models.DataExport, error) {
+	e, err := s.readyExport(id, expires, sig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := s.openArchive(e.FilePath)
+	if err == storage.ErrNotFound {
+		return nil, nil, ErrExportNotReady
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.Audit.Record(models.AuditDataExportDownload, e.UserID, e.UserID, meta, map[string]interface{}{"export_id": e.ID})
+	return f, e, nil
+}
+
+// DownloadURL проверяет подпись ссылки и возвращает подписанную ссылку хранилища на архив,
+// чтобы большой файл отдавало само хранилище (S3), а не наш процесс.
+// Пустая строка — архив лежит в LegacyExportsDir, его отдаёт OpenDownload.
+func (s *ExportService) DownloadURL(id, expires int64, sig string, meta utils.RequestMeta) (string, error) {
+	e, err := s.readyExport(id, expires, sig)
+	if err != nil {
+		return "", err
+	}
+
+	if legacy, ok := s.legacyArchivePath(e.FilePath); ok {
+		if _, err := os.Stat(legacy); err == nil {
+			return "", nil
+		}
+	}
+
+	url, err := s.Storage.SignedURL(e.FilePath, exportRedirectTTL)
+	if err != nil {
+		return "", err
+	}
+
+	s.Audit.Record(models.AuditDataExportDownload, e.UserID, e.UserID, meta, map[string]interface{}{"export_id": e.ID})
+	return url, nil
+}
+
+// readyExport — выгрузка по подписанной ссылке, если архив готов
+func (s *ExportService) readyExport(id, expires int64, sig string) (*models.DataExport, error) {
+	if err := s.Signer.Verify(exportResource(id), expires, sig); err != nil {
+		return nil, err
+	}
+
+	e, err := s.Repo.GetExport(id)
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != models.ExportReady || e.FilePath == "" {
+		return nil, ErrExportNotReady
+	}
+	return e, nil
+}
+
+// openArchive открывает архив по ключу хранилища, а старый — по пути на диске
+func (s *ExportService) openArchive(filePath string) (io.ReadCloser, error) {
+	if legacy, ok := s.legacyArchivePath(filePath); ok {
+		f, err := os.Open(legacy)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		// относительный путь ./exports/... совпадает по виду с ключом — пробуем хранилище
+	}
+	return s.Storage.Get(filePath)
+}
+
+// DeleteArchive удаляет архив выгрузки: из хранилища или старый файл в LegacyExportsDir
+func (s *ExportService) DeleteArchive(filePath string) error {
+	if legacy, ok := s.legacyArchivePath(filePath); ok {
+		if err := os.Remove(legacy); err == nil || !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return s.Storage.Delete(filePath)
+}
+
+// DeleteUserArchives удаляет файлы всех выгрузок пользователя (перед удалением аккаунта).
+// Строки остаются: при ошибке их можно повторить в следующий раз.
+func (s *ExportService) DeleteUserArchives(userID int64) error {
+	files, err := s.Repo.ListUserExportFiles(userID)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, f := range files {
+		if err := s.DeleteArchive(f); err != nil {
+			log.Printf("could not delete export archive %s: %v", f, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// legacyArchivePath — путь на диске, если filePath указывает внутрь LegacyExportsDir
+func (s *ExportService) legacyArchivePath(filePath string) (string, bool) {
+	if s.LegacyExportsDir == "" {
+		return "", false
+	}
+	dir, err := filepath.Abs(s.LegacyExportsDir)
+	if err != nil {
+		return "", false
+	}
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return abs, true
+}
+
+// --------------------------------------------------------
+// WORKER
+// --------------------------------------------------------
+
+// Run обрабатывает очередь выгрузок до отмены ctx
+func (s *ExportService) Run(ctx context.Context) {
+	ticker := time.NewTicker(exportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.failStale()
+		for s.processNext() {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// failStale снимает выгрузки, зависшие в running после падения реплики,
+// чтобы пользователь мог запросить новую
+func (s *ExportService) failStale() {
+	n, err := s.Repo.FailStaleExports(time.Now().Add(-exportRunTimeout), "export timed out")
+	if err != nil {
+		log.Println("export timeout check error:", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("exports: %d stuck jobs marked failed", n)
+	}
+}
+
+// processNext берёт одну задачу; false — очередь пуста или ошибка БД
+func (s *ExportService) processNext() bool {
+	e, err := s.Repo.ClaimPendingExport()
+	if err != nil {
+		log.Println("export claim error:", err)
+		return false
+	}
+	if e == nil {
+		return false
+	}
+
+	key, size, err := s.buildArchive(e)
+	if err != nil {
+		log.Printf("export %d failed: %v", e.ID, err)
+		if err := s.Repo.MarkExportFailed(e.ID, "failed to build export"); err != nil {
+			log.Println("export status error:", err)
+		}
+		return true
+	}
+
+	if err := s.Repo.MarkExportReady(e.ID, key, size, time.Now().Add(exportRetention)); err != nil {
+		log.Println("export status error:", err)
+		_ = s.Storage.Delete(key)
+	}
+	return true
+}
+
+// CleanupExpired удаляет архивы с истёкшим сроком хранения и возвращает число удалённых.
+// Ошибка с одним архивом не останавливает остальные: выгрузка остаётся ready
+// и удаляется при следующем запуске.
+func (s *ExportService) CleanupExpired() (int, error) {
+	list, err := s.Repo.ListExpiredExports(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, e := range list {
+		if e.FilePath != "" {
+			if err := s.DeleteArchive(e.FilePath); err != nil {
+				log.Printf("export %d: archive not deleted, retry later: %v", e.ID, err)
+				continue
+			}
+		}
+		if err := s.Repo.MarkExportExpired(e.ID); err != nil {
+			log.Printf("export %d: could not mark expired: %v", e.ID, err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// --------------------------------------------------------
+// ARCHIVE
+// --------------------------------------------------------
+
+// exportProfile — профиль без sql.Null* обёрток
+type exportProfile struct {
+	ID             int64     `json:"id"`
+	Username       string    `json:"username"`
+	Email          string    `json:"email"`
+	FirstName      string    `json:"first_name"`
+	LastName       string    `json:"last_name"`
+	Gender         string    `json:"gender"`
+	BirthDate      string    `json:"birth_date"`
+	Bio            string    `json:"bio"`
+	ProfilePicture string    `json:"profile_picture"`
+	Rating         int       `json:"rating"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+const exportReadme = `Выгрузка персональных данных EcoFoot
+
+profile.json            — данные профиля
+eco_answers.json/.csv   — ответы на эко-анкету
+eco_results.json/.csv   — результаты расчёта эко-следа
+user_actions.json/.csv  — история эко-действий
+notifications.json/.csv — уведомления
+news_activity.json      — закладки, лайки и прочитанные новости
+avatar/                 — загруженное фото профиля (если есть)
+`
+
+func (s *ExportService) buildArchive(e *models.DataExport) (string, int64, error) {
+	p, err := s.Profiles.GetProfileByID(e.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	answers, err := s.Repo.GetEcoAnswers(e.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	results, err := s.Repo.GetEcoResults(e.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	actions, err := s.Ratings.GetUserActions(e.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	if actions == nil {
+		actions = []models.UserAction{}
+	}
+	notifications, err := s.Repo.GetNotifications(e.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	newsActivity, err := s.Repo.GetNewsActivity(e.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// архив собираем во временном файле и целиком отправляем в хранилище
+	tmp, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", exportProfile{
+			ID:             p.ID,
+			Username:       p.Username,
+			Email:          p.Email,
+			FirstName:      p.FirstName.String,
+			LastName:       p.LastName.String,
+			Gender:         p.Gender.String,
+			BirthDate:      p.BirthDate.String,
+			Bio:            p.Bio.String,
+			ProfilePicture: p.ProfilePicture.String,
+			Rating:         p.Rating,
+			UpdatedAt:      p.UpdatedAt,
+		}},
+		{"eco_answers.json", answers},
+		{"eco_results.json", results},
+		{"user_actions.json", actions},
+		{"notifications.json", notifications},
+		{"news_activity.json", newsActivity},
+	}
+	for _, f := range files {
+		if err := writeZipJSON(zw, f.name, f.data); err != nil {
+			return "", 0, err
+		}
+	}
+
+	answerRows := make([][]string, len(answers))
+	for i, a := range answers {
+		answerRows[i] = []string{strconv.FormatInt(a.QuestionID, 10), a.Category, a.Question, strconv.Itoa(a.Value), a.CreatedAt.Format(time.RFC3339)}
+	}
+	resultRows := make([][]string, len(results))
+	for i, r := range results {
+		resultRows[i] = []string{strconv.Itoa(r.TotalScore), r.Category, r.Description, r.CreatedAt.Format(time.RFC3339)}
+	}
+	actionRows := make([][]string, len(actions))
+	for i, a := range actions {
+		actionRows[i] = []string{a.ActionName, strconv.Itoa(a.Points), a.CreatedAt.Format(time.RFC3339)}
+	}
+	notificationRows := make([][]string, len(notifications))
+	for i, n := range notifications {
+		notificationRows[i] = []string{n.Message, strconv.FormatBool(n.Read), n.CreatedAt.Format(time.RFC3339)}
+	}
+
+	tables := []struct {
+		name   string
+		header []string
+		rows   [][]string
+	}{
+		{"eco_answers.csv", []string{"question_id", "category", "question", "value", "created_at"}, answerRows},
+		{"eco_results.csv", []string{"total_score", "category", "description", "created_at"}, resultRows},
+		{"user_actions.csv", []string{"action_name", "points", "created_at"}, actionRows},
+		{"notifications.csv", []string{"message", "read", "created_at"}, notificationRows},
+	}
+	for _, t := range tables {
+		if err := writeZipCSV(zw, t.name, t.header, t.rows); err != nil {
+			return "", 0, err
+		}
+	}
+
+	if err := s.addAvatar(zw, p.ProfilePicture.String); err != nil {
+		return "", 0, err
+	}
+
+	w, err := zw.Create("README.txt")
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := io.WriteString(w, exportReadme); err != nil {
+		return "", 0, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", 0, err
+	}
+	size, err := tmp.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+
+	key := fmt.Sprintf("exports/export-%d-%s.zip", e.ID, utils.RandomURLToken(8))
+	if err := s.Storage.Put(key, tmp, size, "application/zip"); err != nil {
+		return "", 0, err
+	}
+	return key, size, nil
+}
+
+// addAvatar кладёт в архив файл аватара (public path вида /uploads/users/<file>)
+func (s *ExportService) addAvatar(zw *zip.Writer, publicPath string) error {
+	key, ok := mediaKey(publicPath)
+	if !ok {
+		return nil
+	}
+
+	src, err := s.Storage.Get(key)
+	if err != nil {
+		// файл мог быть удалён вручную — выгрузка без аватара лучше, чем никакой
+		return nil
+	}
+	defer src.Close()
+
+	w, err := zw.Create("avatar/" + path.Base(key))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeZipCSV(zw *zip.Writer, name string, header []string, rows [][]string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
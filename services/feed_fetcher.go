@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	defaultFeedTimeout  = 20 * time.Second
+	defaultFeedMaxBytes = 10 << 20 // 10MB — больше RSS-ленты не бывают
+	feedUserAgent       = "dl-news-fetcher/1.0"
+	pageMaxRedirects    = 5
+)
+
+// ErrForbiddenPageURL — ссылка статьи ведёт не на публичный http(s)-адрес
+var ErrForbiddenPageURL = errors.New("page URL is not allowed")
+
+// Диапазоны, которых нет в netip.Addr.IsPrivate и т.п., но наружу они не ведут
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("198.18.0.0/15"), // бенчмарки
+}
+
+// FeedFetcher скачивает ленты: таймаут на запрос, условные запросы
+// по ETag/Last-Modified и ограничение размера ответа
+type FeedFetcher struct {
+	Client     *http.Client
+	PageClient *http.Client // для ссылок статей из чужих лент: только публичные адреса
+	UserAgent  string
+	Timeout    time.Duration
+	MaxBytes   int64
+}
+
+func NewFeedFetcher() *FeedFetcher {
+	return &FeedFetcher{
+		Client:     &http.Client{},
+		PageClient: newPageClient(),
+		UserAgent:  feedUserAgent,
+		Timeout:    defaultFeedTimeout,
+		MaxBytes:   defaultFeedMaxBytes,
+	}
+}
+
+// FeedResult — ответ ленты. NotModified — сервер ответил 304, Feed == nil.
+type FeedResult struct {
+	Feed         *gofeed.Feed
+	NotModified  bool
+	ETag         string
+	LastModified string
+}
+
+// FeedHTTPError — лента ответила не 2xx/304. RetryAfter — из заголовка Retry-After (если был).
+type FeedHTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *FeedHTTPError) Error() string {
+	return fmt.Sprintf("feed responded with HTTP %d", e.StatusCode)
+}
+
+// Fetch скачивает ленту. etag/lastModified — валидаторы прошлого ответа (могут быть пустыми).
+func (f *FeedFetcher) Fetch(ctx context.Context, feedURL, etag, lastModified string) (*FeedResult, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res := &FeedResult{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		// 304 может не повторять валидаторы — оставляем прежние
+		if res.ETag == "" {
+			res.ETag = etag
+		}
+		if res.LastModified == "" {
+			res.LastModified = lastModified
+		}
+		res.NotModified = true
+		return res, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, &FeedHTTPError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	data, err := readLimited(resp.Body, f.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Feed, err = gofeed.NewParser().ParseString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// FetchPage скачивает HTML-страницу статьи (для og:image), не больше maxBytes.
+// Ссылка приходит из чужой ленты, поэтому идёт через PageClient.
+func (f *FeedFetcher) FetchPage(ctx context.Context, pageURL string, maxBytes int64) ([]byte, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPageURL(u); err != nil {
+		return nil, err
+	}
+
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.PageClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &FeedHTTPError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return nil, fmt.Errorf("page is %s, not HTML", ct)
+	}
+
+	// og:image лежит в <head> — хвост большой страницы не нужен
+	return io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+}
+
+// newPageClient — клиент, который соединяется только с публичными адресами.
+// Адрес проверяется после DNS при каждом соединении, в том числе на редиректах.
+func newPageClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: publicAddrOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // через прокси проверка адреса теряет смысл
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= pageMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			return checkPageURL(req.URL)
+		},
+	}
+}
+
+// checkPageURL — только http(s) с хостом
+func checkPageURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: %s", ErrForbiddenPageURL, u.Redacted())
+	}
+	return nil
+}
+
+// publicAddrOnly — net.Dialer.Control: address уже разрешён в IP
+func publicAddrOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenPageURL, network, ip)
+	}
+	return nil
+}
+
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("feed is larger than %d bytes", max)
+	}
+	return data, nil
+}
+
+// parseRetryAfter понимает обе формы: секунды и HTTP-дату
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"log"
+
+	"dl/classifier"
+	"dl/models"
+	"dl/utils"
+)
+
+const (
+	newsClassifyBatch = 200 // старых новостей за один тик планировщика
+	maxNewsTags       = 10
+)
+
+// classify — категория и теги одной новости. Категория классификатора важнее
+// рубрики источника; рубрика источника берётся, только если это категория эко-теста.
+func (s *NewsService) classify(n *models.NewsItem) {
+	res := s.Classifier.Classify(classifier.Document{
+		Title:    n.Title,
+		Text:     n.Excerpt,
+		Language: n.Language,
+		Labels:   n.Labels,
+	})
+
+	n.Tags = res.Tags
+	if n.Tags == nil {
+		n.Tags = []string{}
+	}
+
+	switch {
+	case res.Category != "":
+		n.Category, n.CategorySource = res.Category, models.CategoryAuto
+	case classifier.IsCategory(n.Category):
+		n.CategorySource = models.CategorySource
+	default:
+		n.Category, n.CategorySource = "", ""
+	}
+}
+
+func (s *NewsService) classifyItems(items []models.NewsItem) {
+	for i := range items {
+		s.classify(&items[i])
+	}
+}
+
+// ClassifyPending классифицирует новости, сохранённые до появления классификатора.
+// Возвращает, сколько обработано.
+func (s *NewsService) ClassifyPending(limit int) (int, error) {
+	items, err := s.Repo.ListUnclassified(limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range items {
+		n := &items[i]
+		s.classify(n)
+		if err := s.Repo.SetClassification(n.ID, n.Category, n.Tags, n.CategorySource); err != nil {
+			return i, err
+		}
+	}
+	return len(items), nil
+}
+
+// OverrideClassification — ручная правка модератора. Выставленная категория
+// закрепляется: классификатор её больше не меняет. category: null снимает закрепление
+// и заново классифицирует новость автоматически.
+func (s *NewsService) OverrideClassification(moderatorID, id int64, p models.NewsClassificationPatch, meta utils.RequestMeta) (*models.NewsItem, error) {
+	if !p.Category.Set && p.Tags == nil {
+		return nil, ErrNoClassificationFields
+	}
+
+	errs := make(map[string]utils.FieldError)
+	if p.Category.Set && p.Category.Value != nil && *p.Category.Value != "" && !classifier.IsCategory(*p.Category.Value) {
+		errs["category"] = utils.FieldError{
+			Code:    utils.FieldInvalidChoice,
+			Message: "must be one of the survey categories",
+			Params:  map[string]interface{}{"choices": classifier.Categories},
+		}
+	}
+
+	var tags []string
+	if p.Tags != nil {
+		tags = normalizeTags(*p.Tags)
+		if len(tags) > maxNewsTags {
+			errs["tags"] = utils.FieldError{
+				Code:    utils.FieldOutOfRange,
+				Message: "too many tags",
+				Params:  map[string]interface{}{"max": maxNewsTags},
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &utils.ValidationError{Fields: errs}
+	}
+
+	n, err := s.Repo.GetNewsItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Category.Set && p.Category.Value == nil {
+		// сброс: пересчитываем по тексту, рубрика источника — запасной вариант
+		n.Category = ""
+		if n.SourceID != 0 {
+			if src, err := s.Repo.GetSource(n.SourceID); err == nil {
+				n.Category = src.Category
+			}
+		}
+		s.classify(n)
+		if tags != nil {
+			n.Tags = tags
+		}
+		if err := s.Repo.UpdateClassification(id, n.Category, n.Tags, n.CategorySource, 0); err != nil {
+			return nil, err
+		}
+	} else {
+		if p.Category.Set {
+			n.Category = *p.Category.Value
+		}
+		if tags != nil {
+			n.Tags = tags
+		}
+		n.CategorySource = models.CategoryModerator
+		if err := s.Repo.UpdateClassification(id, n.Category, n.Tags, n.CategorySource, moderatorID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.Audit.Record(models.AuditNewsClassified, moderatorID, 0, meta, map[string]interface{}{
+		"news_id":  id,
+		"category": n.Category,
+		"tags":     n.Tags,
+		"source":   n.CategorySource,
+	})
+	return n, nil
+}
+
+// normalizeTags — нижний регистр, без повторов и пустых
+func normalizeTags(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := make(map[string]bool)
+	for _, t := range in {
+		t = classifier.NormalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+func (s *NewsService) classifyPendingLogged() {
+	if n, err := s.ClassifyPending(newsClassifyBatch); err != nil {
+		log.Println("news classification error:", err)
+	} else if n > 0 {
+		log.Printf("news classification: %d items classified", n)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"dl/models"
+	"dl/utils"
+)
+
+const (
+	newsDedupBatch = 200
+	// окно публикации, в котором ищутся копии той же новости
+	newsDuplicateWindow = 72 * time.Hour
+	// максимум различающихся битов SimHash у копий одного текста. Тексты короткие
+	// (заголовок и выжимка), разные хвосты заметно сдвигают отпечаток, поэтому порог
+	// выше привычных 3; у несвязанных текстов расстояние около 32.
+	newsDuplicateMaxDistance = 8
+)
+
+// DedupPending проверяет новые новости на дубликаты: та же ссылка после
+// канонизации или почти тот же текст (SimHash) другого источника в пределах
+// окна публикации.
+// Дубликат привязывается к основной новости кластера — самой ранней сохранённой.
+func (s *NewsService) DedupPending(limit int) (int, error) {
+	pending, err := s.Repo.ListUndeduplicated(limit)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, n := range pending {
+		canonical := utils.CanonicalURL(n.Link)
+		hash := utils.SimHash(n.Title + " " + n.Excerpt)
+
+		candidates, err := s.Repo.DuplicateCandidates(n.ID, n.SourceID, canonical,
+			n.PublishedAt.Add(-newsDuplicateWindow), n.PublishedAt.Add(newsDuplicateWindow))
+		if err != nil {
+			return i, err
+		}
+
+		primary := findPrimary(canonical, hash, candidates)
+		if err := s.Repo.SetFingerprint(n.ID, canonical, hash, primary); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+// findPrimary — id основной новости, копией которой является текущая, или 0.
+// Совпадение ссылки важнее текста; среди похожих текстов — самый близкий.
+func findPrimary(canonical string, hash uint64, candidates []models.NewsFingerprint) int64 {
+	var (
+		best     *models.NewsFingerprint
+		bestDist = newsDuplicateMaxDistance + 1
+	)
+	for i := range candidates {
+		c := &candidates[i]
+		if c.CanonicalURL == canonical {
+			best = c
+			break
+		}
+		if hash == 0 || c.SimHash == 0 {
+			continue
+		}
+		if d := utils.HammingDistance(hash, c.SimHash); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+
+	if best == nil {
+		return 0
+	}
+	if best.DuplicateOf != 0 {
+		return best.DuplicateOf
+	}
+	return best.ID
+}
+
+func (s *NewsService) dedupPendingLogged() {
+	if n, err := s.DedupPending(newsDedupBatch); err != nil {
+		log.Println("news dedup error:", err)
+	} else if n > 0 {
+		log.Printf("news dedup: %d items checked", n)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"dl/models"
+	"dl/utils"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+const (
+	newsExcerptLength  = 300
+	newsPageImageLimit = 5         // страниц статей за один опрос источника
+	newsPageMaxBytes   = 512 << 10 // og:image в <head>, хватает начала страницы
+)
+
+// feedItems переводит элементы ленты в новости: чистит описание,
+// делает текстовую выжимку, ищет картинку, автора и язык
+func feedItems(feed *gofeed.Feed, src models.NewsSource) []models.NewsItem {
+	lang := normalizeLanguage(feed.Language)
+	if lang == "" {
+		lang = src.Language
+	}
+
+	items := make([]models.NewsItem, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		published := time.Now()
+		if item.PublishedParsed != nil {
+			published = *item.PublishedParsed
+		}
+
+		// в description у многих лент анонс, в content — полный текст
+		body := item.Description
+		if body == "" {
+			body = item.Content
+		}
+
+		items = append(items, models.NewsItem{
+			Title:       utils.PlainText(item.Title, 0),
+			Link:        item.Link,
+			PublishedAt: published,
+			Source:      src.Name,
+			Description: utils.SanitizeHTML(body, item.Link),
+			Excerpt:     utils.PlainText(body, newsExcerptLength),
+			ImageURL:    itemImage(item),
+			Author:      itemAuthor(item),
+			Language:    lang,
+			Category:    src.Category,
+			SourceID:    src.ID,
+			Labels:      item.Categories,
+		})
+	}
+	return items
+}
+
+// itemImage — картинка из самой ленты: вложения, media:*, <img> в тексте
+func itemImage(item *gofeed.Item) string {
+	for _, enc := range item.Enclosures {
+		if strings.HasPrefix(enc.Type, "image/") {
+			if u := utils.SafeImageURL(enc.URL, item.Link); u != "" {
+				return u
+			}
+		}
+	}
+
+	if media, ok := item.Extensions["media"]; ok {
+		if u := mediaImage(media, item.Link); u != "" {
+			return u
+		}
+	}
+
+	if item.Image != nil {
+		if u := utils.SafeImageURL(item.Image.URL, item.Link); u != "" {
+			return u
+		}
+	}
+
+	if u := utils.FirstImageURL(item.Content, item.Link); u != "" {
+		return u
+	}
+	return utils.FirstImageURL(item.Description, item.Link)
+}
+
+// mediaImage — media:content (image), media:thumbnail, в том числе внутри media:group
+func mediaImage(media map[string][]ext.Extension, base string) string {
+	for _, c := range media["content"] {
+		if strings.HasPrefix(c.Attrs["type"], "image/") || c.Attrs["medium"] == "image" {
+			if u := utils.SafeImageURL(c.Attrs["url"], base); u != "" {
+				return u
+			}
+		}
+	}
+	for _, t := range media["thumbnail"] {
+		if u := utils.SafeImageURL(t.Attrs["url"], base); u != "" {
+			return u
+		}
+	}
+	for _, g := range media["group"] {
+		if u := mediaImage(g.Children, base); u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+func itemAuthor(item *gofeed.Item) string {
+	for _, a := range item.Authors {
+		if a != nil && strings.TrimSpace(a.Name) != "" {
+			return strings.TrimSpace(a.Name)
+		}
+	}
+	if item.DublinCoreExt != nil && len(item.DublinCoreExt.Creator) > 0 {
+		return strings.TrimSpace(item.DublinCoreExt.Creator[0])
+	}
+	return ""
+}
+
+// normalizeLanguage: "ru-RU" → "ru", "en_us" → "en"
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	if len(lang) < 2 || len(lang) > 3 {
+		return ""
+	}
+	return lang
+}
+
+// fillPageImages ищет og:image на страницах новых статей без картинки.
+// Уже сохранённые ссылки не скачиваются повторно; не больше newsPageImageLimit за раз.
+func (s *NewsService) fillPageImages(ctx context.Context, items []models.NewsItem) {
+	var links []string
+	for _, it := range items {
+		if it.ImageURL == "" && it.Link != "" {
+			links = append(links, it.Link)
+		}
+	}
+	if len(links) == 0 {
+		return
+	}
+
+	existing, err := s.Repo.ExistingLinks(links)
+	if err != nil {
+		log.Println("news existing links lookup failed:", err)
+		return
+	}
+
+	fetched := 0
+	for i := range items {
+		it := &items[i]
+		if it.ImageURL != "" || it.Link == "" || existing[it.Link] {
+			continue
+		}
+		if fetched >= newsPageImageLimit || ctx.Err() != nil {
+			return
+		}
+		fetched++
+
+		page, err := s.Fetcher.FetchPage(ctx, it.Link, newsPageMaxBytes)
+		if err != nil {
+			continue
+		}
+		it.ImageURL = utils.PageImageURL(string(page), it.Link)
+	}
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+
+	"dl/models"
+)
+
+const defaultNewsFeedSize = 50
+
+// Форматы собственной ленты новостей
+const (
+	NewsFeedRSS  = "rss"
+	NewsFeedAtom = "atom"
+	NewsFeedJSON = "json"
+)
+
+// NewsFeed — наша агрегированная лента: основные новости по тем же фильтрам, что и /news
+type NewsFeed struct {
+	Title    string
+	SiteURL  string // страница новостей
+	SelfURL  string // адрес самой ленты, с параметрами запроса
+	Language string
+	Updated  time.Time // самая свежая новость; нулевое — лента пуста
+	Items    []models.NewsItem
+}
+
+// Feed — первая страница новостей для ленты. Отметки пользователя не нужны:
+// лента публичная и кэшируется.
+func (s *NewsService) Feed(f models.NewsFilter) (*NewsFeed, error) {
+	f.ViewerID = 0
+	if f.Limit <= 0 {
+		f.Limit = defaultNewsFeedSize
+	}
+	page, err := s.ListNews(f, "")
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &NewsFeed{Title: newsFeedTitle(f), Language: strings.ToLower(f.Language), Items: page.Items}
+	for _, n := range page.Items {
+		if n.PublishedAt.After(feed.Updated) {
+			feed.Updated = n.PublishedAt
+		}
+	}
+	return feed, nil
+}
+
+func newsFeedTitle(f models.NewsFilter) string {
+	var parts []string
+	for _, p := range []string{f.Category, f.Tag, strings.TrimSpace(f.Query)} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return "EcoFoot news"
+	}
+	return "EcoFoot news: " + strings.Join(parts, ", ")
+}
+
+// Render — лента в формате format; возвращает тело и Content-Type
+func (feed *NewsFeed) Render(format string) ([]byte, string, error) {
+	switch format {
+	case NewsFeedAtom:
+		b, err := feed.atom()
+		return b, "application/atom+xml; charset=utf-8", err
+	case NewsFeedJSON:
+		b, err := feed.jsonFeed()
+		return b, "application/feed+json; charset=utf-8", err
+	default:
+		b, err := feed.rss()
+		return b, "application/rss+xml; charset=utf-8", err
+	}
+}
+
+// itemID — постоянный идентификатор новости в лентах
+func (feed *NewsFeed) itemID(n models.NewsItem) string {
+	return feed.SiteURL + "/" + strconv.FormatInt(n.ID, 10)
+}
+
+// itemCategories — категория и теги новости
+func itemCategories(n models.NewsItem) []string {
+	var out []string
+	if n.Category != "" {
+		out = append(out, n.Category)
+	}
+	for _, t := range n.Tags {
+		if t != n.Category {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// ------------------------ RSS 2.0 ------------------------
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description,omitempty"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (feed *NewsFeed) rss() ([]byte, error) {
+	doc := rssDoc{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       feed.Title,
+			Link:        feed.SiteURL,
+			Description: "Environmental news aggregated by EcoFoot",
+			Language:    feed.Language,
+			AtomLink:    rssLink{Href: feed.SelfURL, Rel: "self", Type: "application/rss+xml"},
+			Items:       make([]rssItem, 0, len(feed.Items)),
+		},
+	}
+	if !feed.Updated.IsZero() {
+		doc.Channel.LastBuildDate = feed.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, n := range feed.Items {
+		item := rssItem{
+			Title:       n.Title,
+			Link:        n.Link,
+			GUID:        rssGUID{Value: feed.itemID(n)},
+			PubDate:     n.PublishedAt.UTC().Format(time.RFC1123Z),
+			Description: firstNonEmpty(n.Description, n.Excerpt),
+			Creator:     firstNonEmpty(n.Author, n.Source),
+			Categories:  itemCategories(n),
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return marshalXML(doc)
+}
+
+// ------------------------ Atom ------------------------
+
+type atomDoc struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string      `xml:"xml:lang,attr,omitempty"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Updated  string      `xml:"updated"`
+	Author   atomPerson  `xml:"author"`
+	Links    []atomLink  `xml:"link"`
+	Subtitle string      `xml:"subtitle"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// emptyFeedUpdated — <updated> пустой Atom-ленты (поле обязательное). Фиксированное
+// значение, чтобы тело и ETag не менялись от запроса к запросу.
+var emptyFeedUpdated = time.Unix(0, 0)
+
+func (feed *NewsFeed) atom() ([]byte, error) {
+	updated := feed.Updated
+	if updated.IsZero() {
+		updated = emptyFeedUpdated
+	}
+	doc := atomDoc{
+		Lang:    feed.Language,
+		ID:      feed.SelfURL,
+		Title:   feed.Title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: "EcoFoot"},
+		Links: []atomLink{
+			{Href: feed.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: feed.SiteURL, Rel: "alternate"},
+		},
+		Subtitle: "Environmental news aggregated by EcoFoot",
+		Entries:  make([]atomEntry, 0, len(feed.Items)),
+	}
+
+	for _, n := range feed.Items {
+		published := n.PublishedAt.UTC().Format(time.RFC3339)
+		entry := atomEntry{
+			ID:        feed.itemID(n),
+			Title:     n.Title,
+			Link:      atomLink{Href: n.Link, Rel: "alternate"},
+			Published: published,
+			Updated:   published,
+		}
+		if name := firstNonEmpty(n.Author, n.Source); name != "" {
+			entry.Author = &atomPerson{Name: name}
+		}
+		if n.Excerpt != "" {
+			entry.Summary = &atomText{Type: "text", Value: n.Excerpt}
+		}
+		if n.Description != "" {
+			entry.Content = &atomText{Type: "html", Value: n.Description}
+		}
+		for _, c := range itemCategories(n) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalXML(doc)
+}
+
+// ------------------------ JSON Feed 1.1 ------------------------
+
+type jsonFeedDoc struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html,omitempty"`
+	ContentText   string           `json:"content_text,omitempty"`
+	Summary       string           `json:"summary,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+	Language      string           `json:"language,omitempty"`
+}
+
+func (feed *NewsFeed) jsonFeed() ([]byte, error) {
+	doc := jsonFeedDoc{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: feed.SiteURL,
+		FeedURL:     feed.SelfURL,
+		Description: "Environmental news aggregated by EcoFoot",
+		Language:    feed.Language,
+		Items:       make([]jsonFeedItem, 0, len(feed.Items)),
+	}
+
+	for _, n := range feed.Items {
+		item := jsonFeedItem{
+			ID:            feed.itemID(n),
+			URL:           n.Link,
+			Title:         n.Title,
+			ContentHTML:   n.Description,
+			Summary:       n.Excerpt,
+			Image:         n.ImageURL,
+			DatePublished: n.PublishedAt.UTC().Format(time.RFC3339),
+			Tags:          itemCategories(n),
+			Language:      n.Language,
+		}
+		// в JSON Feed у элемента должно быть хоть какое-то содержимое
+		if item.ContentHTML == "" {
+			item.ContentText = firstNonEmpty(n.Excerpt, n.Title)
+		}
+		if name := firstNonEmpty(n.Author, n.Source); name != "" {
+			item.Authors = []jsonFeedAuthor{{Name: name}}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
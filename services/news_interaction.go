@@ -0,0 +1,119 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"dl/models"
+)
+
+// свежие новости для счётчика непрочитанных
+const newsUnreadWindow = 7 * 24 * time.Hour
+
+// SetInteraction ставит или снимает закладку, лайк или отметку о прочтении
+func (s *NewsService) SetInteraction(userID, newsID int64, kind string, on bool) error {
+	return s.Repo.SetInteraction(kind, userID, newsID, on)
+}
+
+// attachViewerState заполняет Viewer у новостей для пользователя userID
+func (s *NewsService) attachViewerState(userID int64, items []models.NewsItem) error {
+	if userID == 0 || len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(items))
+	for i, n := range items {
+		ids[i] = n.ID
+	}
+	state, err := s.Repo.ViewerState(userID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		v := state[items[i].ID]
+		items[i].Viewer = &v
+	}
+	return nil
+}
+
+// ListBookmarks — закладки пользователя, новые первыми
+func (s *NewsService) ListBookmarks(userID int64, cursor string, limit int) (*models.NewsPage, error) {
+	if limit <= 0 {
+		limit = defaultNewsPageSize
+	}
+	if limit > maxNewsPageSize {
+		limit = maxNewsPageSize
+	}
+
+	var (
+		before   *time.Time
+		beforeID int64
+	)
+	if cursor != "" {
+		t, id, err := decodeBookmarkCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		before, beforeID = &t, id
+	}
+
+	items, err := s.Repo.ListBookmarks(userID, before, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachViewerState(userID, items); err != nil {
+		return nil, err
+	}
+
+	page := &models.NewsPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		last := page.Items[limit-1]
+		if last.Viewer != nil && last.Viewer.BookmarkedAt != nil {
+			raw := fmt.Sprintf("b:%d:%d", last.Viewer.BookmarkedAt.UnixNano(), last.ID)
+			page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(raw))
+		}
+	}
+	if page.Items == nil {
+		page.Items = []models.NewsItem{}
+	}
+	return page, nil
+}
+
+func decodeBookmarkCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidNewsCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || parts[0] != "b" {
+		return time.Time{}, 0, ErrInvalidNewsCursor
+	}
+	nanos, err1 := strconv.ParseInt(parts[1], 10, 64)
+	id, err2 := strconv.ParseInt(parts[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return time.Time{}, 0, ErrInvalidNewsCursor
+	}
+	return time.Unix(0, nanos).UTC(), id, nil
+}
+
+// UnreadCount — сколько свежих новостей пользователь ещё не открыл
+func (s *NewsService) UnreadCount(userID int64) (*models.NewsUnreadCount, error) {
+	since := time.Now().Add(-newsUnreadWindow).UTC()
+	counts, err := s.Repo.UnreadCounts(userID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &models.NewsUnreadCount{ByCategory: make(map[string]int), Since: since}
+	for category, n := range counts {
+		res.Unread += n
+		if category != "" {
+			res.ByCategory[category] = n
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dl/models"
+	"dl/repositories"
+)
+
+// Веса ранжирования подборки "для вас"
+const (
+	rankCandidateWindow = 7 * 24 * time.Hour // из каких новостей выбираем
+	rankCandidateLimit  = 300
+	rankLikedTagsWindow = 90 * 24 * time.Hour
+	rankLikedTagsLimit  = 20
+
+	rankCategoryWeight = 1.5            // самая слабая категория следа
+	rankTagWeight      = 1.0            // теги, совпавшие с лайкнутыми (в сумме не больше)
+	rankLanguageWeight = 0.5            // предпочитаемый язык
+	rankHalfLife       = 24 * time.Hour // за сутки ранг падает вдвое
+	rankReadFactor     = 0.3            // уже прочитанное опускается вниз
+)
+
+// NewsRankingService собирает персональную подборку: новости по слабым категориям
+// эко-следа, лайкнутым тегам и языку пользователя, с затуханием по возрасту
+type NewsRankingService struct {
+	News *NewsService
+	Eco  *repositories.EcoRepository
+}
+
+func NewNewsRankingService(news *NewsService, eco *repositories.EcoRepository) *NewsRankingService {
+	return &NewsRankingService{News: news, Eco: eco}
+}
+
+// rankProfile — то, что известно о предпочтениях пользователя
+type rankProfile struct {
+	footprint      map[string]float64 // категория → доля от максимального следа
+	maxFootprint   float64
+	likedTags      map[string]int
+	maxLikedTag    int
+	language       string
+	languageSource string // "activity" — по отметкам, "header" — Accept-Language
+}
+
+// ForYou — страница подборки. fallbackLang — язык из Accept-Language, если по
+// отметкам язык не понять. Ранг пересчитывается на каждый запрос; курсор — смещение.
+func (s *NewsRankingService) ForYou(userID int64, fallbackLang, cursor string, limit int) (*models.NewsPage, error) {
+	if limit <= 0 {
+		limit = defaultNewsPageSize
+	}
+	if limit > maxNewsPageSize {
+		limit = maxNewsPageSize
+	}
+	offset, err := decodeRankCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := s.profile(userID, fallbackLang)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	since := now.Add(-rankCandidateWindow)
+	items, err := s.News.Repo.ListNews(models.NewsFilter{From: &since, Limit: rankCandidateLimit})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > rankCandidateLimit {
+		items = items[:rankCandidateLimit]
+	}
+	if err := s.News.attachViewerState(userID, items); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		items[i].Explanation = profile.rank(&items[i], now)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Explanation.Score > items[j].Explanation.Score
+	})
+
+	page := &models.NewsPage{Items: []models.NewsItem{}}
+	if offset < len(items) {
+		end := min(offset+limit, len(items))
+		page.Items = items[offset:end]
+		if end < len(items) {
+			page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte("f:" + strconv.Itoa(end)))
+		}
+	}
+	return page, nil
+}
+
+func (s *NewsRankingService) profile(userID int64, fallbackLang string) (*rankProfile, error) {
+	footprint, err := s.Eco.GetCategoryScores(userID)
+	if err != nil {
+		return nil, err
+	}
+	liked, err := s.News.Repo.LikedTags(userID, time.Now().Add(-rankLikedTagsWindow), rankLikedTagsLimit)
+	if err != nil {
+		return nil, err
+	}
+	lang, err := s.News.Repo.PreferredLanguage(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &rankProfile{footprint: footprint, likedTags: liked, language: lang, languageSource: "activity"}
+	if lang == "" && fallbackLang != "" {
+		p.language, p.languageSource = fallbackLang, "header"
+	}
+	for _, v := range footprint {
+		p.maxFootprint = math.Max(p.maxFootprint, v)
+	}
+	for _, n := range liked {
+		p.maxLikedTag = max(p.maxLikedTag, n)
+	}
+	return p, nil
+}
+
+// rank — ранг новости и его объяснение: (1 + бонусы) × свежесть × прочитанность
+func (p *rankProfile) rank(n *models.NewsItem, now time.Time) *models.NewsRankExplanation {
+	ex := &models.NewsRankExplanation{Reasons: []models.NewsRankReason{}}
+	relevance := 1.0
+
+	if share := p.footprint[n.Category]; share > 0 && p.maxFootprint > 0 {
+		w := rankCategoryWeight * share / p.maxFootprint
+		relevance += w
+		ex.Reasons = append(ex.Reasons, models.NewsRankReason{
+			Kind: "footprint_category", Value: n.Category, Weight: round2(w),
+			Detail: fmt.Sprintf("your footprint in %s is %.0f%% of the maximum", n.Category, share*100),
+		})
+	}
+
+	if p.maxLikedTag > 0 {
+		var (
+			matched []string
+			w       float64
+		)
+		for _, t := range n.Tags {
+			if c := p.likedTags[t]; c > 0 {
+				matched = append(matched, t)
+				w += rankTagWeight * float64(c) / float64(p.maxLikedTag)
+			}
+		}
+		if len(matched) > 0 {
+			w = math.Min(w, rankTagWeight)
+			relevance += w
+			ex.Reasons = append(ex.Reasons, models.NewsRankReason{
+				Kind: "liked_tag", Value: strings.Join(matched, ","), Weight: round2(w),
+				Detail: "you liked articles with these tags",
+			})
+		}
+	}
+
+	if p.language != "" && n.Language == p.language {
+		relevance += rankLanguageWeight
+		detail := "written in the language you read most"
+		if p.languageSource == "header" {
+			detail = "written in your browser language"
+		}
+		ex.Reasons = append(ex.Reasons, models.NewsRankReason{
+			Kind: "language", Value: n.Language, Weight: rankLanguageWeight, Detail: detail,
+		})
+	}
+
+	age := max(now.Sub(n.PublishedAt), 0)
+	freshness := math.Pow(0.5, age.Hours()/rankHalfLife.Hours())
+	ex.Reasons = append(ex.Reasons, models.NewsRankReason{
+		Kind: "freshness", Weight: round2(freshness),
+		Detail: fmt.Sprintf("published %.0f hours ago", age.Hours()),
+	})
+
+	score := relevance * freshness
+	if n.Viewer != nil && n.Viewer.Read {
+		score *= rankReadFactor
+		ex.Reasons = append(ex.Reasons, models.NewsRankReason{
+			Kind: "already_read", Weight: rankReadFactor, Detail: "you have already read this",
+		})
+	}
+
+	ex.Score = math.Round(score*10000) / 10000
+	return ex
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func decodeRankCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), "f:") {
+		return 0, ErrInvalidNewsCursor
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "f:"))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidNewsCursor
+	}
+	return offset, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"expvar"
+	"time"
+)
+
+const newsRetentionBatch = 1000 // строк за один запрос, чтобы не держать долгие блокировки
+
+// NewsRetention — политика хранения новостей. Новость вне политики — старше MaxAge
+// или не среди PerSource последних своего источника. Нули отключают ограничение.
+type NewsRetention struct {
+	MaxAge    time.Duration
+	PerSource int
+	Archive   bool // true — оставить заголовок и ссылку, удалить текст; false — удалить строку
+}
+
+func (p NewsRetention) Enabled() bool {
+	return p.MaxAge > 0 || p.PerSource > 0
+}
+
+// Метрики чистки, доступны в /admin/metrics (expvar)
+var newsRetentionMetrics = expvar.NewMap("news_retention")
+
+// NewsRetentionResult — итог одного прогона
+type NewsRetentionResult struct {
+	Archived int64
+	Deleted  int64
+}
+
+// ApplyRetention приводит таблицу новостей к политике s.Retention.
+// Работает пачками, пока есть что чистить.
+//
+// Лишние по PerSource новости могут ещё стоять в ленте источника: удалённую
+// строку следующий опрос вставит заново (ON CONFLICT (link) её уже не найдёт),
+// и чистка с опросом будут гонять её по кругу. Поэтому их всегда только
+// архивируем, а удаляем лишь то, что старше MaxAge.
+func (s *NewsService) ApplyRetention() (NewsRetentionResult, error) {
+	var res NewsRetentionResult
+	p := s.Retention
+	if !p.Enabled() {
+		return res, nil
+	}
+
+	var cutoff *time.Time
+	if p.MaxAge > 0 {
+		t := time.Now().Add(-p.MaxAge)
+		cutoff = &t
+	}
+
+	newsRetentionMetrics.Add("runs", 1)
+	var err error
+	switch {
+	case p.Archive:
+		err = s.prune(cutoff, p.PerSource, true, &res)
+	case p.PerSource > 0:
+		if cutoff != nil {
+			err = s.prune(cutoff, 0, false, &res)
+		}
+		if err == nil {
+			err = s.prune(nil, p.PerSource, true, &res)
+		}
+	default:
+		err = s.prune(cutoff, 0, false, &res)
+	}
+	if err != nil {
+		newsRetentionMetrics.Add("errors", 1)
+		return res, err
+	}
+
+	last := new(expvar.String)
+	last.Set(time.Now().UTC().Format(time.RFC3339))
+	newsRetentionMetrics.Set("last_run", last)
+	return res, nil
+}
+
+// prune — один вид чистки пачками до конца
+func (s *NewsService) prune(cutoff *time.Time, perSource int, archive bool, res *NewsRetentionResult) error {
+	for {
+		n, err := s.Repo.PruneNews(cutoff, perSource, archive, newsRetentionBatch)
+		if err != nil {
+			return err
+		}
+		if archive {
+			res.Archived += n
+			newsRetentionMetrics.Add("archived", n)
+		} else {
+			res.Deleted += n
+			newsRetentionMetrics.Add("deleted", n)
+		}
+		if n < newsRetentionBatch {
+			return nil
+		}
+	}
+}
@@ -1,58 +1,501 @@
 package services
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"dl/classifier"
 	"dl/models"
 	"dl/repositories"
+	"dl/utils"
 
-	"github.com/mmcdole/gofeed"
+	"github.com/lib/pq"
+)
+
+const (
+	newsTestSampleLen   = 3
+	newsSchedulerTick   = time.Minute
+	newsMaxBackoff      = 24 * time.Hour
+	defaultNewsWorkers  = 4
+	MinNewsPollInterval = 5    // минут
+	MaxNewsPollInterval = 1440 // сутки
+	defaultNewsPageSize = 20
+	maxNewsPageSize     = 100
+	maxNewsQueryLength  = 200
+)
+
+var (
+	ErrNewsSourceExists       = errors.New("news source with this URL already exists")
+	ErrNoSourceFields         = errors.New("no fields to update")
+	ErrNoClassificationFields = errors.New("category or tags required")
+	ErrInvalidNewsCursor      = errors.New("invalid cursor")
 )
 
 type NewsService struct {
-	Repo *repositories.NewsRepository
+	Repo    *repositories.NewsRepository
+	Audit   *AuditService
+	Fetcher *FeedFetcher
+
+	Classifier classifier.Classifier
+	Retention  NewsRetention
+
+	DefaultPollInterval int // минут, для источников без своего интервала
+	Concurrency         int // сколько лент качается одновременно
 }
 
-func NewNewsService(repo *repositories.NewsRepository) *NewsService {
-	return &NewsService{Repo: repo}
+func NewNewsService(repo *repositories.NewsRepository, audit *AuditService) *NewsService {
+	return &NewsService{
+		Repo:                repo,
+		Audit:               audit,
+		Fetcher:             NewFeedFetcher(),
+		Classifier:          classifier.Default(),
+		DefaultPollInterval: 30,
+		Concurrency:         defaultNewsWorkers,
+	}
 }
 
-func (s *NewsService) UpdateNews() error {
-	parser := gofeed.NewParser()
+// --------------------------------------------------------
+// FETCHING
+// --------------------------------------------------------
 
-	feeds := []string{
-		"https://ecogosfond.kz/feed/",
+// Run раз в минуту опрашивает источники, у которых подошло время, до отмены ctx
+func (s *NewsService) Run(ctx context.Context) {
+	ticker := time.NewTicker(newsSchedulerTick)
+	defer ticker.Stop()
+
+	for {
+		if err := s.UpdateNews(ctx); err != nil {
+			log.Println("news update error:", err)
+		}
+		s.classifyPendingLogged()
+		s.dedupPendingLogged()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// UpdateNews опрашивает включённые источники, у которых подошло время,
+// не больше Concurrency одновременно. Ошибка одного источника не мешает
+// остальным — она пишется в его статус.
+func (s *NewsService) UpdateNews(ctx context.Context) error {
+	sources, err := s.Repo.ListDueSources(time.Now())
+	if err != nil {
+		return err
 	}
 
-	var allNews []models.NewsItem
+	workers := s.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
 
-	for _, url := range feeds {
-		feed, err := parser.ParseURL(url)
-		if err != nil {
-			log.Println("⚠️ RSS parse error:", err)
+	for _, src := range sources {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+			wg.Add(1)
+			go func(src models.NewsSource) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s.pollSource(ctx, src)
+			}(src)
 			continue
 		}
+		break
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (s *NewsService) pollSource(ctx context.Context, src models.NewsSource) {
+	started := time.Now()
+
+	var items []models.NewsItem
+	res, err := s.Fetcher.Fetch(ctx, src.URL, src.ETag, src.LastModified)
+	if err == nil && !res.NotModified {
+		items = feedItems(res.Feed, src)
+		s.fillPageImages(ctx, items)
+		s.classifyItems(items)
+		err = s.Repo.SaveNews(items)
+	}
+
+	// остановка сервера — не вина источника
+	if ctx.Err() != nil {
+		return
+	}
+
+	status, msg := models.NewsSourceOK, ""
+	etag, lastModified := src.ETag, src.LastModified
+	switch {
+	case err != nil:
+		status, msg = models.NewsSourceError, err.Error()
+		log.Printf("news source %d (%s): %v", src.ID, src.URL, err)
+	case res.NotModified:
+		status = models.NewsSourceNotModified
+		etag, lastModified = res.ETag, res.LastModified
+	default:
+		etag, lastModified = res.ETag, res.LastModified
+	}
+
+	next := started.Add(nextPollDelay(src, err))
+	if err := s.Repo.RecordSourceFetch(src.ID, status, msg, len(items), etag, lastModified, started, next); err != nil {
+		log.Printf("news source %d status update failed: %v", src.ID, err)
+	}
+}
+
+// nextPollDelay — обычный интервал; после ошибок экспоненциально больше
+// (интервал * 2^(ошибок подряд - 1), не дольше суток), но не раньше Retry-After
+func nextPollDelay(src models.NewsSource, fetchErr error) time.Duration {
+	interval := time.Duration(src.PollIntervalMinutes) * time.Minute
+	if fetchErr == nil {
+		return interval
+	}
+
+	failures := src.ConsecutiveFailures // до этой ошибки
+	if failures > 10 {
+		failures = 10
+	}
+	delay := interval << failures
+	if delay > newsMaxBackoff {
+		delay = newsMaxBackoff
+	}
+
+	var httpErr *FeedHTTPError
+	if errors.As(fetchErr, &httpErr) && httpErr.RetryAfter > delay {
+		delay = min(httpErr.RetryAfter, newsMaxBackoff)
+	}
+	return delay
+}
+
+// --------------------------------------------------------
+// READING
+// --------------------------------------------------------
+
+// ListNews — страница ленты. cursor — next_cursor предыдущей страницы.
+func (s *NewsService) ListNews(f models.NewsFilter, cursor string) (*models.NewsPage, error) {
+	if f.Limit <= 0 {
+		f.Limit = defaultNewsPageSize
+	}
+	if f.Limit > maxNewsPageSize {
+		f.Limit = maxNewsPageSize
+	}
+	f.Query = strings.TrimSpace(f.Query)
+	f.Language = strings.ToLower(strings.TrimSpace(f.Language))
+	if f.Tag != "" {
+		f.Tag = classifier.NormalizeTag(f.Tag)
+	}
+
+	errs := make(map[string]utils.FieldError)
+	if f.Category != "" && !classifier.IsCategory(f.Category) {
+		errs["category"] = utils.FieldError{
+			Code:    utils.FieldInvalidChoice,
+			Message: "must be one of the survey categories",
+			Params:  map[string]interface{}{"choices": classifier.Categories},
+		}
+	}
+	if f.Language != "" {
+		if fe := validateLanguage(f.Language); fe != nil {
+			errs["language"] = *fe
+		}
+	}
+	if fe := maxLength(f.Query, maxNewsQueryLength); fe != nil {
+		errs["q"] = *fe
+	}
+	if f.From != nil && f.To != nil && !f.To.After(*f.From) {
+		errs["to"] = utils.FieldError{Code: utils.FieldOutOfRange, Message: "must be after 'from'"}
+	}
+	if len(errs) > 0 {
+		return nil, &utils.ValidationError{Fields: errs}
+	}
+
+	if cursor != "" {
+		if err := decodeNewsCursor(cursor, &f); err != nil {
+			return nil, err
+		}
+	}
+
+	items, err := s.Repo.ListNews(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachViewerState(f.ViewerID, items); err != nil {
+		return nil, err
+	}
+
+	page := &models.NewsPage{Items: items}
+	if len(items) > f.Limit {
+		page.Items = items[:f.Limit]
+		page.NextCursor = encodeNewsCursor(f, page.Items[f.Limit-1])
+	}
+	if page.Items == nil {
+		page.Items = []models.NewsItem{}
+	}
+	return page, nil
+}
+
+// GetNews — одна новость; viewerID (0 — аноним) получает свои отметки
+func (s *NewsService) GetNews(viewerID, id int64) (*models.NewsItem, error) {
+	n, err := s.Repo.GetNewsItem(id)
+	if err != nil {
+		return nil, err
+	}
+	items := []models.NewsItem{*n}
+	if err := s.attachViewerState(viewerID, items); err != nil {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// Курсор непрозрачен для клиента: base64 от "t:<published unix nano>:<id>"
+// для хронологической ленты или "o:<offset>" для поиска
+func encodeNewsCursor(f models.NewsFilter, last models.NewsItem) string {
+	var raw string
+	if f.Query != "" {
+		raw = fmt.Sprintf("o:%d", f.Offset+f.Limit)
+	} else {
+		raw = fmt.Sprintf("t:%d:%d", last.PublishedAt.UnixNano(), last.ID)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeNewsCursor(cursor string, f *models.NewsFilter) error {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidNewsCursor
+	}
+	parts := strings.Split(string(raw), ":")
+
+	switch {
+	case len(parts) == 2 && parts[0] == "o" && f.Query != "":
+		offset, err := strconv.Atoi(parts[1])
+		if err != nil || offset < 0 {
+			return ErrInvalidNewsCursor
+		}
+		f.Offset = offset
+	case len(parts) == 3 && parts[0] == "t" && f.Query == "":
+		nanos, err1 := strconv.ParseInt(parts[1], 10, 64)
+		id, err2 := strconv.ParseInt(parts[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			return ErrInvalidNewsCursor
+		}
+		published := time.Unix(0, nanos).UTC()
+		f.AfterPublished, f.AfterID = &published, id
+	default:
+		// курсор от другого вида запроса (поиск ↔ лента)
+		return ErrInvalidNewsCursor
+	}
+	return nil
+}
+
+// --------------------------------------------------------
+// SOURCES (ADMIN)
+// --------------------------------------------------------
+
+func (s *NewsService) ListSources() ([]models.NewsSource, error) {
+	return s.Repo.ListSources()
+}
+
+func (s *NewsService) CreateSource(adminID int64, in models.NewsSourceInput, meta utils.RequestMeta) (*models.NewsSource, error) {
+	in.URL = strings.TrimSpace(in.URL)
+	in.Name = strings.TrimSpace(in.Name)
+	in.Language = strings.ToLower(strings.TrimSpace(in.Language))
+	in.Category = strings.TrimSpace(in.Category)
+	if in.Language == "" {
+		in.Language = "ru"
+	}
+	if in.PollIntervalMinutes == 0 {
+		in.PollIntervalMinutes = s.DefaultPollInterval
+	}
+
+	errs := make(map[string]utils.FieldError)
+	if fe := validateFeedURL(in.URL); fe != nil {
+		errs["url"] = *fe
+	}
+	if fe := validateSourceName(in.Name); fe != nil {
+		errs["name"] = *fe
+	}
+	if fe := validateLanguage(in.Language); fe != nil {
+		errs["language"] = *fe
+	}
+	if fe := validateCategory(in.Category); fe != nil {
+		errs["category"] = *fe
+	}
+	if fe := validatePollInterval(in.PollIntervalMinutes); fe != nil {
+		errs["poll_interval_minutes"] = *fe
+	}
+	if len(errs) > 0 {
+		return nil, &utils.ValidationError{Fields: errs}
+	}
+
+	src, err := s.Repo.CreateSource(in)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return nil, ErrNewsSourceExists
+		}
+		return nil, err
+	}
+
+	s.Audit.Record(models.AuditNewsSourceCreated, adminID, 0, meta, map[string]interface{}{"source_id": src.ID, "url": src.URL})
+	return src, nil
+}
+
+// UpdateSource меняет настройки источника; enabled=false отключает опрос
+func (s *NewsService) UpdateSource(adminID, id int64, p models.NewsSourcePatch, meta utils.RequestMeta) (*models.NewsSource, error) {
+	errs := make(map[string]utils.FieldError)
+	changed := []string{}
+
+	if p.Name != nil {
+		v := strings.TrimSpace(*p.Name)
+		p.Name = &v
+		if fe := validateSourceName(v); fe != nil {
+			errs["name"] = *fe
+		}
+		changed = append(changed, "name")
+	}
+	if p.Language != nil {
+		v := strings.ToLower(strings.TrimSpace(*p.Language))
+		p.Language = &v
+		if fe := validateLanguage(v); fe != nil {
+			errs["language"] = *fe
+		}
+		changed = append(changed, "language")
+	}
+	if p.Category != nil {
+		v := strings.TrimSpace(*p.Category)
+		p.Category = &v
+		if fe := validateCategory(v); fe != nil {
+			errs["category"] = *fe
+		}
+		changed = append(changed, "category")
+	}
+	if p.PollIntervalMinutes != nil {
+		if fe := validatePollInterval(*p.PollIntervalMinutes); fe != nil {
+			errs["poll_interval_minutes"] = *fe
+		}
+		changed = append(changed, "poll_interval_minutes")
+	}
+	if p.Enabled != nil {
+		changed = append(changed, "enabled")
+	}
 
-		for _, item := range feed.Items {
-			published := time.Now()
-			if item.PublishedParsed != nil {
-				published = *item.PublishedParsed
-			}
+	if len(errs) > 0 {
+		return nil, &utils.ValidationError{Fields: errs}
+	}
+	if len(changed) == 0 {
+		return nil, ErrNoSourceFields
+	}
+
+	src, err := s.Repo.UpdateSource(id, p)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Audit.Record(models.AuditNewsSourceUpdated, adminID, 0, meta, map[string]interface{}{
+		"source_id": id,
+		"fields":    changed,
+		"enabled":   src.Enabled,
+	})
+	return src, nil
+}
+
+// TestSource пробно опрашивает источник и показывает, что из него получится.
+// Новости не сохраняются, статус источника не меняется.
+func (s *NewsService) TestSource(id int64) (*models.NewsSourceTestResult, error) {
+	src, err := s.Repo.GetSource(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// без валидаторов: админ хочет видеть содержимое ленты, а не 304
+	fetched, err := s.Fetcher.Fetch(context.Background(), src.URL, "", "")
+	if err != nil {
+		return &models.NewsSourceTestResult{OK: false, Error: err.Error()}, nil
+	}
+	feed := fetched.Feed
+
+	items := feedItems(feed, *src)
+	s.classifyItems(items)
+	res := &models.NewsSourceTestResult{
+		OK:        true,
+		FeedTitle: feed.Title,
+		FeedType:  feed.FeedType,
+		ItemCount: len(items),
+	}
+	if len(items) > newsTestSampleLen {
+		items = items[:newsTestSampleLen]
+	}
+	res.Sample = items
+	return res, nil
+}
 
-			allNews = append(allNews, models.NewsItem{
-				Title:       item.Title,
-				Link:        item.Link,
-				PublishedAt: published,
-				Source:      feed.Title,
-				Description: item.Description,
-			})
+// ------------------------ VALIDATION ------------------------
+
+func validateFeedURL(raw string) *utils.FieldError {
+	if raw == "" {
+		return &utils.FieldError{Code: utils.FieldRequired, Message: "is required"}
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &utils.FieldError{Code: utils.FieldInvalidURL, Message: "must be an absolute http(s) URL"}
+	}
+	return nil
+}
+
+func validateSourceName(name string) *utils.FieldError {
+	if name == "" {
+		return &utils.FieldError{Code: utils.FieldRequired, Message: "is required"}
+	}
+	return maxLength(name, 255)
+}
+
+func validateLanguage(lang string) *utils.FieldError {
+	if len(lang) < 2 || len(lang) > 10 {
+		return &utils.FieldError{Code: utils.FieldInvalidChoice, Message: "must be a language code like \"ru\" or \"en\""}
+	}
+	for _, r := range lang {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return &utils.FieldError{Code: utils.FieldInvalidChoice, Message: "must be a language code like \"ru\" or \"en\""}
 		}
 	}
+	return nil
+}
+
+func validateCategory(category string) *utils.FieldError {
+	return maxLength(category, 50)
+}
 
-	return s.Repo.SaveNews(allNews)
+func validatePollInterval(minutes int) *utils.FieldError {
+	if minutes < MinNewsPollInterval || minutes > MaxNewsPollInterval {
+		return &utils.FieldError{
+			Code:    utils.FieldOutOfRange,
+			Message: fmt.Sprintf("must be between %d and %d minutes", MinNewsPollInterval, MaxNewsPollInterval),
+			Params:  map[string]interface{}{"min": MinNewsPollInterval, "max": MaxNewsPollInterval},
+		}
+	}
+	return nil
 }
 
-func (s *NewsService) GetAllNews() ([]models.NewsItem, error) {
-	return s.Repo.GetAllNews()
+func maxLength(v string, max int) *utils.FieldError {
+	if len([]rune(v)) > max {
+		return &utils.FieldError{
+			Code:    utils.FieldTooLong,
+			Message: fmt.Sprintf("must be at most %d characters", max),
+			Params:  map[string]interface{}{"max": max},
+		}
+	}
+	return nil
 }
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"dl/models"
+	"dl/repositories"
+	"dl/utils"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OIDCService — вход через внешних провайдеров (authorization code + PKCE)
+type OIDCService struct {
+	Repo      *repositories.UserRepository
+	Providers map[string]*utils.OIDCProvider
+	Audit     *AuditService
+}
+
+func NewOIDCService(repo *repositories.UserRepository, providers map[string]*utils.OIDCProvider, audit *AuditService) *OIDCService {
+	return &OIDCService{Repo: repo, Providers: providers, Audit: audit}
+}
+
+// OIDCStateTTL — сколько живёт state начатого входа (и cookie с ним)
+const OIDCStateTTL = 10 * time.Minute
+
+// ErrOIDCUnverifiedAccount — email занят аккаунтом, который не подтвердил email.
+// Такой аккаунт мог зарегистрировать кто угодно, поэтому автоматически не привязываем.
+var ErrOIDCUnverifiedAccount = errors.New("an account with this email exists but is not verified; sign in with your password and confirm the email first")
+
+// --------------------------------------------------------
+// START LOGIN
+// --------------------------------------------------------
+
+// StartLogin сохраняет state/nonce/PKCE и возвращает URL провайдера для редиректа
+// и state, который нужно привязать к браузеру
+func (s *OIDCService) StartLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", "", errors.New("unknown provider")
+	}
+
+	state := utils.RandomURLToken(24)
+	nonce := utils.RandomURLToken(24)
+	verifier, challenge := utils.GeneratePKCE()
+
+	if err := s.Repo.SaveOIDCState(state, providerName, nonce, verifier, time.Now().Add(OIDCStateTTL)); err != nil {
+		return "", "", err
+	}
+	_ = s.Repo.DeleteExpiredOIDCStates()
+
+	redirectURL, err := provider.AuthCodeURL(ctx, state, nonce, challenge)
+	return redirectURL, state, err
+}
+
+// --------------------------------------------------------
+// FINISH LOGIN (callback)
+// --------------------------------------------------------
+
+func (s *OIDCService) FinishLogin(ctx context.Context, providerName, state, code string, meta utils.RequestMeta) (string, string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", "", errors.New("unknown provider")
+	}
+
+	savedProvider, nonce, verifier, expires, err := s.Repo.ConsumeOIDCState(state)
+	if err != nil || savedProvider != providerName || time.Now().After(expires) {
+		return "", "", errors.New("invalid or expired login state")
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return "", "", err
+	}
+
+	userID, err := s.resolveUser(providerName, claims, meta)
+	if err != nil {
+		return "", "", err
+	}
+
+	s.Audit.Record(models.AuditOIDCLogin, userID, userID, meta, map[string]interface{}{"provider": providerName})
+	cancelPendingDeletion(s.Repo, s.Audit, userID, meta)
+
+	return utils.GenerateTokens(userID)
+}
+
+// resolveUser находит пользователя по внешней identity, привязывает её к
+// существующему аккаунту по email (подтверждённому и провайдером, и у нас)
+// или создаёт новый аккаунт
+func (s *OIDCService) resolveUser(providerName string, claims *utils.OIDCClaims, meta utils.RequestMeta) (int64, error) {
+	if userID, err := s.Repo.GetUserIDByIdentity(providerName, claims.Subject); err == nil {
+		_ = s.Repo.TouchIdentity(providerName, claims.Subject)
+		return userID, nil
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" {
+		return 0, errors.New("provider did not return an email")
+	}
+	if !claims.EmailVerified {
+		return 0, errors.New("email is not verified by provider")
+	}
+
+	// существующий аккаунт с тем же email — привязываем, только если его email подтверждён
+	if userID, _, verified, err := s.Repo.GetUserByEmail(email); err == nil {
+		if !verified {
+			return 0, ErrOIDCUnverifiedAccount
+		}
+		if err := s.Repo.CreateIdentity(userID, providerName, claims.Subject, email); err != nil {
+			return 0, err
+		}
+		s.Audit.Record(models.AuditOIDCIdentityLinked, userID, userID, meta, map[string]interface{}{"provider": providerName})
+		return userID, nil
+	}
+
+	userID, err := s.createUser(email, claims)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.Repo.CreateIdentity(userID, providerName, claims.Subject, email); err != nil {
+		return 0, err
+	}
+	s.Audit.Record(models.AuditAccountCreated, userID, userID, meta, map[string]interface{}{"method": "oidc", "provider": providerName})
+	return userID, nil
+}
+
+func (s *OIDCService) createUser(email string, claims *utils.OIDCClaims) (int64, error) {
+	hint := claims.GivenName
+	if hint == "" {
+		hint = email
+	}
+
+	// при коллизии username пробуем другой суффикс; похожие имена и имена
+	// с действующим редиректом тоже заняты, как при регистрации
+	for attempt := 0; attempt < 5; attempt++ {
+		username := utils.GenerateUsername(hint)
+
+		taken, err := s.Repo.UsernameTaken(username, 0, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		if taken {
+			continue
+		}
+
+		userID, err := s.Repo.CreateExternalUser(username, email, claims.GivenName, claims.FamilyName)
+		if err == nil {
+			return userID, nil
+		}
+
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			if strings.Contains(pgErr.Message, "users_username_key") {
+				continue
+			}
+			if strings.Contains(pgErr.Message, "users_email_key") {
+				return 0, errors.New("email already exists")
+			}
+		}
+		return 0, err
+	}
+
+	return 0, errors.New("could not generate unique username")
+}
@@ -1,30 +1,259 @@
 package services
 
 import (
+	"bytes"
 	"dl/models"
 	"dl/repositories"
+	"dl/storage"
+	"dl/utils"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPasswordRequired = errors.New("password is required")
+	ErrWrongPassword    = errors.New("wrong password")
+	ErrNoPasswordSet    = errors.New("account has no password, set one via password reset first")
 )
 
 type ProfileService struct {
-	Repo *repositories.ProfileRepository
+	Repo   *repositories.ProfileRepository
+	Hasher utils.PasswordHasher
+	Audit  *AuditService
+
+	Storage       storage.Storage
+	DeletionGrace time.Duration // сколько дней можно передумать (войти в аккаунт)
+
+	// Exports удаляет архивы выгрузок, в том числе старые файлы в EXPORTS_DIR.
+	// nil — архивы удаляются из Storage по ключу.
+	Exports *ExportService
 }
 
-func NewProfileService(repo *repositories.ProfileRepository) *ProfileService {
-	return &ProfileService{Repo: repo}
+func NewProfileService(repo *repositories.ProfileRepository, hasher utils.PasswordHasher, store storage.Storage, deletionGrace time.Duration, audit *AuditService) *ProfileService {
+	return &ProfileService{
+		Repo:          repo,
+		Hasher:        hasher,
+		Audit:         audit,
+		Storage:       store,
+		DeletionGrace: deletionGrace,
+	}
 }
 
 func (s *ProfileService) GetProfile(userID int64) (*models.UserProfile, error) {
 	return s.Repo.GetProfileByID(userID)
 }
 
-func (s *ProfileService) UpdateProfile(userID int64, first, last, gender, bio, birth string) error {
-	return s.Repo.UpdateProfile(userID, first, last, gender, bio, birth)
+var ErrNoProfileFields = errors.New("no fields to update")
+
+// UpdateProfile применяет частичное обновление. Все поля проверяются сразу,
+// ошибки возвращаются одним *utils.ValidationError.
+func (s *ProfileService) UpdateProfile(userID int64, patch models.ProfilePatch, meta utils.RequestMeta) error {
+	fields := make(map[string]*string)
+	errs := make(map[string]utils.FieldError)
+
+	check := func(name string, opt models.OptionalString, validate func(string) *utils.FieldError) {
+		if !opt.Set {
+			return
+		}
+		if opt.Value == nil {
+			fields[name] = nil
+			return
+		}
+		v := strings.TrimSpace(*opt.Value)
+		if name == "bio" {
+			v = utils.NormalizeNewlines(v)
+		}
+		if fe := validate(v); fe != nil {
+			errs[name] = *fe
+			return
+		}
+		fields[name] = &v
+	}
+
+	check("first_name", patch.FirstName, utils.ValidatePersonName)
+	check("last_name", patch.LastName, utils.ValidatePersonName)
+	check("gender", patch.Gender, utils.ValidateGender)
+	check("bio", patch.Bio, utils.ValidateBio)
+	check("birth_date", patch.BirthDate, utils.ValidateBirthDate)
+
+	if len(errs) > 0 {
+		return &utils.ValidationError{Fields: errs}
+	}
+	if len(fields) == 0 {
+		return ErrNoProfileFields
+	}
+
+	if err := s.Repo.UpdateProfileFields(userID, fields); err != nil {
+		return err
+	}
+
+	changed := make([]string, 0, len(fields))
+	for name := range fields {
+		changed = append(changed, name)
+	}
+	sort.Strings(changed)
+
+	s.Audit.Record(models.AuditProfileUpdated, userID, userID, meta, map[string]interface{}{"fields": changed})
+	return nil
 }
 
-func (s *ProfileService) UpdateProfilePicture(userID int64, filePath string) error {
-	return s.Repo.UpdateAvatar(userID, filePath)
+// UpdateAvatar перекодирует загруженное изображение, сохраняет его с превью
+// под именем-хэшем содержимого и удаляет файлы предыдущего аватара
+func (s *ProfileService) UpdateAvatar(userID int64, data []byte, meta utils.RequestMeta) (string, map[string]string, error) {
+	processed, err := utils.ProcessAvatar(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	profile, err := s.Repo.GetProfileByID(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// хэш включает userID: одинаковые картинки разных пользователей не делят файлы,
+	// поэтому удаление старого аватара не задевает чужие
+	name := utils.HashToken(fmt.Sprintf("%d:", userID) + string(processed.Main))[:32]
+	publicPath := "/uploads/users/" + name + ".jpg"
+
+	files := map[string][]byte{publicPath: processed.Main}
+	thumbs := utils.AvatarThumbnailURLs(publicPath)
+	for _, size := range utils.AvatarThumbnailSizes {
+		files[thumbs[strconv.Itoa(size)]] = processed.Thumbnails[size]
+	}
+
+	var written []string
+	for public, content := range files {
+		key, _ := mediaKey(public)
+		if err := s.Storage.Put(key, bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+			s.deleteObjects(written)
+			return "", nil, err
+		}
+		written = append(written, key)
+	}
+
+	if err := s.Repo.UpdateAvatar(userID, publicPath); err != nil {
+		s.deleteObjects(written)
+		return "", nil, err
+	}
+
+	if old := profile.ProfilePicture.String; old != "" && old != publicPath {
+		s.deleteObjects(avatarKeys(old))
+	}
+
+	s.Audit.Record(models.AuditAvatarUpdated, userID, userID, meta, nil)
+	return publicPath, thumbs, nil
+}
+
+// avatarKeys — ключи хранилища для аватара и его превью
+func avatarKeys(publicPath string) []string {
+	var keys []string
+	if k, ok := mediaKey(publicPath); ok {
+		keys = append(keys, k)
+	}
+	for _, thumb := range utils.AvatarThumbnailURLs(publicPath) {
+		if k, ok := mediaKey(thumb); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (s *ProfileService) deleteObjects(keys []string) {
+	for _, k := range keys {
+		if err := s.Storage.Delete(k); err != nil {
+			log.Printf("could not delete %s: %v", k, err)
+		}
+	}
+}
+
+// --------------------------------------------------------
+// ACCOUNT DELETION
+// --------------------------------------------------------
+
+// DeleteProfile планирует удаление аккаунта через DeletionGrace.
+// До этого срока удаление отменяется входом в аккаунт.
+func (s *ProfileService) DeleteProfile(userID int64, password string, meta utils.RequestMeta) (time.Time, error) {
+	if password == "" {
+		return time.Time{}, ErrPasswordRequired
+	}
+
+	hash, err := s.Repo.GetPasswordHash(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(hash) == 0 {
+		return time.Time{}, ErrNoPasswordSet
+	}
+
+	ok, _, err := s.Hasher.Verify(password, string(hash))
+	if err != nil || !ok {
+		return time.Time{}, ErrWrongPassword
+	}
+
+	dueAt := time.Now().Add(s.DeletionGrace)
+	if err := s.Repo.ScheduleDeletion(userID, dueAt); err != nil {
+		return time.Time{}, err
+	}
+
+	s.Audit.Record(models.AuditDeletionScheduled, userID, userID, meta, map[string]interface{}{"due_at": dueAt})
+	return dueAt, nil
+}
+
+// PurgeDueDeletions анонимизирует аккаунты с истёкшей отсрочкой и удаляет их файлы
+func (s *ProfileService) PurgeDueDeletions() (int, error) {
+	ids, err := s.Repo.ListDueDeletions(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		// архивы с персональными данными удаляем до строк data_exports: иначе
+		// при ошибке файл останется, а найти его будет уже не по чему
+		if s.Exports != nil {
+			if err := s.Exports.DeleteUserArchives(id); err != nil {
+				log.Printf("account %d: export archives not deleted, retry later: %v", id, err)
+				continue
+			}
+		}
+
+		files, err := s.Repo.AnonymizeUser(id)
+		if err != nil {
+			log.Printf("account %d anonymization failed: %v", id, err)
+			continue
+		}
+
+		for _, f := range files {
+			switch {
+			case strings.HasPrefix(f, "/uploads/"):
+				s.deleteObjects(avatarKeys(f))
+			case s.Exports != nil:
+				// выгрузка, собранная уже после DeleteUserArchives
+				if err := s.Exports.DeleteArchive(f); err != nil {
+					log.Printf("could not delete export archive %s: %v", f, err)
+				}
+			default:
+				s.deleteObjects([]string{f})
+			}
+		}
+
+		s.Audit.Record(models.AuditAccountDeleted, 0, id, utils.RequestMeta{}, nil)
+		purged++
+	}
+	return purged, nil
 }
 
-func (s *ProfileService) DeleteProfile(userID int64) error {
-	return s.Repo.DeleteUser(userID)
+// mediaKey переводит публичный путь /uploads/<key> в ключ хранилища
+func mediaKey(publicPath string) (string, bool) {
+	rel, ok := strings.CutPrefix(publicPath, "/uploads/")
+	if !ok {
+		return "", false
+	}
+	key, err := storage.CleanKey(rel)
+	return key, err == nil
 }
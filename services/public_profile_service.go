@@ -0,0 +1,246 @@
+package services
+
+import (
+	"dl/models"
+	"dl/repositories"
+	"dl/utils"
+	"errors"
+	"strings"
+	"time"
+)
+
+const publicRecentActionsLimit = 10
+
+// UsernameMovedError — пользователь сменил имя, страница доступна по новому
+type UsernameMovedError struct {
+	Username string
+}
+
+func (e *UsernameMovedError) Error() string {
+	return "user has been renamed to " + e.Username
+}
+
+// PublicProfileService — публичные страницы пользователей и настройки приватности
+type PublicProfileService struct {
+	Profiles *repositories.ProfileRepository
+	Ratings  *repositories.RatingRepository
+	Privacy  *repositories.PrivacyRepository
+	Audit    *AuditService
+}
+
+func NewPublicProfileService(profiles *repositories.ProfileRepository, ratings *repositories.RatingRepository,
+	privacy *repositories.PrivacyRepository, audit *AuditService) *PublicProfileService {
+	return &PublicProfileService{Profiles: profiles, Ratings: ratings, Privacy: privacy, Audit: audit}
+}
+
+// --------------------------------------------------------
+// PUBLIC PROFILE
+// --------------------------------------------------------
+
+// GetPublicProfile собирает профиль с учётом приватности. viewerID = 0 — аноним.
+func (s *PublicProfileService) GetPublicProfile(username string, viewerID int64) (*models.PublicProfile, error) {
+	p, err := s.Profiles.GetPublicProfileByUsername(username)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		// старое имя после смены — отправляем на новое
+		current, rerr := s.Profiles.ResolveUsernameRedirect(username, time.Now())
+		if rerr != nil {
+			return nil, rerr
+		}
+		return nil, &UsernameMovedError{Username: current}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := s.Privacy.GetPrivacy(p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// дружбу проверяем лениво — только если какой-то раздел "friends"
+	friendChecked, isFriend := false, false
+	var friendErr error
+	canSee := func(visibility string) bool {
+		switch {
+		case viewerID != 0 && viewerID == p.ID:
+			return true
+		case visibility == models.VisibilityPublic:
+			return true
+		case visibility == models.VisibilityFriends && viewerID != 0:
+			if !friendChecked {
+				isFriend, friendErr = s.Privacy.AreFriends(viewerID, p.ID)
+				friendChecked = true
+			}
+			return isFriend
+		}
+		return false
+	}
+
+	out := &models.PublicProfile{Username: p.Username}
+
+	if canSee(settings.About) {
+		out.FirstName = p.FirstName.String
+		out.LastName = p.LastName.String
+		out.Bio = p.Bio.String
+	} else {
+		out.HiddenSections = append(out.HiddenSections, "about")
+	}
+
+	if canSee(settings.Avatar) {
+		out.Avatar = p.ProfilePicture.String
+		out.Thumbs = utils.AvatarThumbnailURLs(p.ProfilePicture.String)
+	} else {
+		out.HiddenSections = append(out.HiddenSections, "avatar")
+	}
+
+	if canSee(settings.Stats) {
+		out.Rating, out.Level, out.League = &p.Rating, &p.Level, p.League
+	} else {
+		out.HiddenSections = append(out.HiddenSections, "stats")
+	}
+
+	if canSee(settings.Badges) {
+		stats, err := s.Ratings.GetActivityStats(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		out.Badges = computeBadges(stats)
+	} else {
+		out.HiddenSections = append(out.HiddenSections, "badges")
+	}
+
+	if canSee(settings.Activity) {
+		out.RecentActions, err = s.Ratings.GetRecentUserActions(p.ID, publicRecentActionsLimit)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		out.HiddenSections = append(out.HiddenSections, "activity")
+	}
+
+	// без проверки дружбы ответ мог скрыть лишнее — это ошибка, а не приватность
+	if friendErr != nil {
+		return nil, friendErr
+	}
+	return out, nil
+}
+
+// Бейджи считаются из истории действий, отдельно не хранятся
+var badgeRules = []struct {
+	badge models.Badge
+	earns func(models.UserActivityStats) bool
+}{
+	{models.Badge{Code: "first_step", Name: "First Step", Description: "Logged the first eco action"},
+		func(s models.UserActivityStats) bool { return s.TotalActions >= 1 }},
+	{models.Badge{Code: "eco_regular", Name: "Eco Regular", Description: "Logged 10 eco actions"},
+		func(s models.UserActivityStats) bool { return s.TotalActions >= 10 }},
+	{models.Badge{Code: "eco_champion", Name: "Eco Champion", Description: "Logged 100 eco actions"},
+		func(s models.UserActivityStats) bool { return s.TotalActions >= 100 }},
+	{models.Badge{Code: "explorer", Name: "Explorer", Description: "Tried 5 different eco actions"},
+		func(s models.UserActivityStats) bool { return s.DistinctActions >= 5 }},
+	{models.Badge{Code: "consistent", Name: "Consistent", Description: "Was active on 7 different days"},
+		func(s models.UserActivityStats) bool { return s.ActiveDays >= 7 }},
+}
+
+func computeBadges(stats models.UserActivityStats) []models.Badge {
+	badges := []models.Badge{}
+	for _, rule := range badgeRules {
+		if rule.earns(stats) {
+			badges = append(badges, rule.badge)
+		}
+	}
+	return badges
+}
+
+// --------------------------------------------------------
+// FRIENDS
+// --------------------------------------------------------
+
+// ErrFriendSelf — заявка самому себе
+var ErrFriendSelf = errors.New("cannot add yourself as a friend")
+
+// RequestFriend отправляет заявку в друзья или принимает встречную
+func (s *PublicProfileService) RequestFriend(userID int64, username string) (string, error) {
+	friendID, err := s.friendID(userID, username)
+	if err != nil {
+		return "", err
+	}
+	return s.Privacy.RequestFriend(userID, friendID)
+}
+
+// RemoveFriend — удалить из друзей, отклонить или отозвать заявку
+func (s *PublicProfileService) RemoveFriend(userID int64, username string) error {
+	friendID, err := s.friendID(userID, username)
+	if err != nil {
+		return err
+	}
+	return s.Privacy.RemoveFriend(userID, friendID)
+}
+
+func (s *PublicProfileService) ListFriends(userID int64) (models.FriendList, error) {
+	return s.Privacy.ListFriends(userID)
+}
+
+func (s *PublicProfileService) friendID(userID int64, username string) (int64, error) {
+	p, err := s.Profiles.GetPublicProfileByUsername(username)
+	if err != nil {
+		return 0, err
+	}
+	if p.ID == userID {
+		return 0, ErrFriendSelf
+	}
+	return p.ID, nil
+}
+
+// --------------------------------------------------------
+// PRIVACY SETTINGS
+// --------------------------------------------------------
+
+func (s *PublicProfileService) GetPrivacy(userID int64) (models.PrivacySettings, error) {
+	return s.Privacy.GetPrivacy(userID)
+}
+
+func (s *PublicProfileService) UpdatePrivacy(userID int64, patch models.PrivacyPatch, meta utils.RequestMeta) (models.PrivacySettings, error) {
+	settings, err := s.Privacy.GetPrivacy(userID)
+	if err != nil {
+		return settings, err
+	}
+
+	errs := make(map[string]utils.FieldError)
+	apply := func(name string, v *string, dst *string) {
+		if v == nil {
+			return
+		}
+		value := strings.TrimSpace(*v)
+		switch value {
+		case models.VisibilityPublic, models.VisibilityFriends, models.VisibilityPrivate:
+			*dst = value
+		default:
+			errs[name] = utils.FieldError{
+				Code:    utils.FieldInvalidChoice,
+				Message: "must be one of: public, friends, private",
+				Params:  map[string]interface{}{"choices": []string{models.VisibilityPublic, models.VisibilityFriends, models.VisibilityPrivate}},
+			}
+		}
+	}
+
+	apply("about", patch.About, &settings.About)
+	apply("avatar", patch.Avatar, &settings.Avatar)
+	apply("stats", patch.Stats, &settings.Stats)
+	apply("badges", patch.Badges, &settings.Badges)
+	apply("activity", patch.Activity, &settings.Activity)
+	if patch.HideFromLeaderboard != nil {
+		settings.HideFromLeaderboard = *patch.HideFromLeaderboard
+	}
+
+	if len(errs) > 0 {
+		return settings, &utils.ValidationError{Fields: errs}
+	}
+
+	if err := s.Privacy.SavePrivacy(userID, settings); err != nil {
+		return settings, err
+	}
+
+	s.Audit.Record(models.AuditPrivacyUpdated, userID, userID, meta, nil)
+	return settings, nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage — хранилище файлов (аватары, архивы выгрузок и другие медиа).
+// Ключ — относительный путь через "/", например "users/<hash>.jpg".
+type Storage interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// SignedURL — ссылка для прямого скачивания, действующая ttl
+	// (у локального драйвера ссылка публичная и не истекает)
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// CleanKey нормализует ключ и запрещает выход за пределы хранилища
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// FromEnv создаёт хранилище по STORAGE_DRIVER (local | s3):
+//
+//	local: UPLOADS_DIR (./uploads)
+//	s3:    S3_ENDPOINT, S3_REGION (us-east-1), S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY,
+//	       S3_PATH_STYLE (true для MinIO и совместимых)
+func FromEnv() (Storage, error) {
+	switch driver := getenv("STORAGE_DRIVER", "local"); driver {
+	case "local":
+		return NewLocalStorage(getenv("UPLOADS_DIR", "./uploads"), "/uploads")
+
+	case "s3":
+		pathStyle, _ := strconv.ParseBool(getenv("S3_PATH_STYLE", "false"))
+		s := NewS3Storage(
+			os.Getenv("S3_ENDPOINT"),
+			getenv("S3_REGION", "us-east-1"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+			pathStyle,
+		)
+		if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required")
+		}
+		return s, nil
+
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
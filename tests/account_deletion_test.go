@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"dl/repositories"
+	"dl/services"
+	"dl/storage"
+	"dl/utils"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDeleteProfileRequiresPassword(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	hasher := utils.NewArgon2Hasher(utils.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	hash, _ := hasher.Hash("Correct#Horse9")
+	store, _ := storage.NewLocalStorage(t.TempDir(), "/uploads")
+	service := services.NewProfileService(repositories.NewProfileRepository(db), hasher, store, 14*24*time.Hour, nil)
+
+	if _, err := service.DeleteProfile(7, "", utils.RequestMeta{}); !errors.Is(err, services.ErrPasswordRequired) {
+		t.Fatalf("empty password: got %v", err)
+	}
+
+	mock.ExpectQuery("SELECT password_hash FROM users").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow([]byte(hash)))
+	if _, err := service.DeleteProfile(7, "wrong", utils.RequestMeta{}); !errors.Is(err, services.ErrWrongPassword) {
+		t.Fatalf("wrong password: got %v", err)
+	}
+
+	mock.ExpectQuery("SELECT password_hash FROM users").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"password_hash"}).AddRow([]byte(hash)))
+	mock.ExpectExec("UPDATE users SET deletion_due_at").WithArgs(sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	dueAt, err := service.DeleteProfile(7, "Correct#Horse9", utils.RequestMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(dueAt); d < 13*24*time.Hour || d > 15*24*time.Hour {
+		t.Errorf("unexpected due date %v", dueAt)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPurgeDueDeletionsAnonymizesAndRemovesFiles(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	uploads := t.TempDir()
+	store, _ := storage.NewLocalStorage(uploads, "/uploads")
+	for _, key := range []string{"users/a.png", "exports/export-1.zip"} {
+		if err := store.Put(key, strings.NewReader("x"), 1, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	avatar := filepath.Join(uploads, "users", "a.png")
+	archive := filepath.Join(uploads, "exports", "export-1.zip")
+
+	service := services.NewProfileService(repositories.NewProfileRepository(db), nil, store, 0, nil)
+
+	mock.ExpectQuery("SELECT id FROM users").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT profile_picture FROM users").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"profile_picture"}).AddRow("/uploads/users/a.png"))
+	mock.ExpectQuery("SELECT file_path FROM data_exports").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"file_path"}).AddRow("exports/export-1.zip"))
+	for i := 0; i < 14; i++ {
+		mock.ExpectExec("DELETE FROM").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(`UPDATE users\s+SET username = 'deleted_' \|\| id`).WithArgs(int64(7), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := service.PurgeDueDeletions()
+	if err != nil || n != 1 {
+		t.Fatalf("purge: n=%d err=%v", n, err)
+	}
+	for _, f := range []string{avatar, archive} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", f)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPurgeDueDeletionsRemovesLegacyExportArchives(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	store, _ := storage.NewLocalStorage(t.TempDir(), "/uploads")
+	if err := store.Put("exports/export-2.zip", strings.NewReader("x"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	// архив, собранный до перехода на Storage: абсолютный путь в EXPORTS_DIR
+	legacyDir := t.TempDir()
+	legacy := filepath.Join(legacyDir, "export-1-old.zip")
+	if err := os.WriteFile(legacy, []byte("personal data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exportRepo := repositories.NewExportRepository(db)
+	exports := services.NewExportService(exportRepo, nil, nil, nil, store, nil)
+	exports.LegacyExportsDir = legacyDir
+	service := services.NewProfileService(repositories.NewProfileRepository(db), nil, store, 0, nil)
+	service.Exports = exports
+
+	exportFiles := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"file_path"}).AddRow(legacy).AddRow("exports/export-2.zip")
+	}
+
+	// не смогли получить список архивов — аккаунт не трогаем до следующего запуска
+	mock.ExpectQuery("SELECT id FROM users").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("SELECT file_path FROM data_exports WHERE user_id").WithArgs(int64(7)).WillReturnError(sqlmock.ErrCancelled)
+	if n, err := service.PurgeDueDeletions(); err != nil || n != 0 {
+		t.Fatalf("purge with db error: n=%d err=%v", n, err)
+	}
+
+	mock.ExpectQuery("SELECT id FROM users").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("SELECT file_path FROM data_exports WHERE user_id").WithArgs(int64(7)).WillReturnRows(exportFiles())
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT profile_picture FROM users").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"profile_picture"}).AddRow(nil))
+	mock.ExpectQuery("SELECT file_path FROM data_exports").WithArgs(int64(7)).WillReturnRows(exportFiles())
+	for i := 0; i < 14; i++ {
+		mock.ExpectExec("DELETE FROM").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(`UPDATE users\s+SET username = 'deleted_' \|\| id`).WithArgs(int64(7), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if n, err := service.PurgeDueDeletions(); err != nil || n != 1 {
+		t.Fatalf("purge: n=%d err=%v", n, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("legacy export archive was not removed: %v", err)
+	}
+	if _, err := store.Get("exports/export-2.zip"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("export archive was not removed: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"dl/middleware"
+	"dl/utils"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeAPIKeys map[string][]string
+
+func (f fakeAPIKeys) AuthenticateAPIKey(key string) (int64, []string, error) {
+	scopes, ok := f[key]
+	if !ok {
+		return 0, nil, errors.New("invalid api key")
+	}
+	return 42, scopes, nil
+}
+
+func TestAPIKeyAuthAndScopes(t *testing.T) {
+	keys := fakeAPIKeys{
+		"eco_writer_secret": {"write:actions"},
+		"eco_reader_secret": {"read:leaderboard"},
+	}
+
+	var gotUser int64
+	final := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = utils.UserIDFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.JWTOrAPIKeyAuth(keys, middleware.RequireScope("write:actions", final))
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"bearer key with scope", "Authorization", "Bearer eco_writer_secret", http.StatusOK},
+		{"x-api-key with scope", "X-API-Key", "eco_writer_secret", http.StatusOK},
+		{"key without scope", "X-API-Key", "eco_reader_secret", http.StatusForbidden},
+		{"unknown key", "X-API-Key", "eco_unknown", http.StatusUnauthorized},
+		{"no credentials", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUser = 0
+			req := httptest.NewRequest(http.MethodPost, "/add-action", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, rr.Code)
+			}
+			if tt.want == http.StatusOK && gotUser != 42 {
+				t.Errorf("expected user 42 in context, got %d", gotUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"dl/middleware"
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+type fakeRoles map[int64]string
+
+func (f fakeRoles) GetUserRole(userID int64) (string, error) {
+	role, ok := f[userID]
+	if !ok {
+		return "", errors.New("user not found")
+	}
+	return role, nil
+}
+
+func TestRequireRole(t *testing.T) {
+	handler := middleware.RequireRole(fakeRoles{1: "admin", 2: "user"}, okHandler(), "admin")
+
+	send := func(ctx func(*http.Request) *http.Request) int {
+		req := ctx(httptest.NewRequest(http.MethodGet, "/admin/audit-events", nil))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	asUser := func(id int64) func(*http.Request) *http.Request {
+		return func(r *http.Request) *http.Request {
+			return r.WithContext(utils.ContextWithUserID(r.Context(), id))
+		}
+	}
+
+	if code := send(asUser(1)); code != http.StatusOK {
+		t.Errorf("admin: expected 200, got %d", code)
+	}
+	if code := send(asUser(2)); code != http.StatusForbidden {
+		t.Errorf("user: expected 403, got %d", code)
+	}
+	// даже админский API-ключ не даёт доступа к админке
+	if code := send(func(r *http.Request) *http.Request {
+		ctx := utils.ContextWithScopes(utils.ContextWithUserID(r.Context(), 1), []string{"read:profile"})
+		return r.WithContext(ctx)
+	}); code != http.StatusForbidden {
+		t.Errorf("api key: expected 403, got %d", code)
+	}
+}
+
+func TestAuditSearchIsFilteredAndAudited(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	service := services.NewAuditService(repositories.NewAuditRepository(db), 365*24*time.Hour)
+
+	target := int64(5)
+	rows := sqlmock.NewRows([]string{"id", "created_at", "event_type", "actor_id", "target_id", "ip", "user_agent", "metadata"}).
+		AddRow(10, time.Now(), models.AuditLoginFailed, nil, 5, "10.0.0.1", "curl", []byte(`{"reason":"wrong_password"}`))
+
+	mock.ExpectQuery(`FROM audit_events\s+WHERE target_id = \$1 AND event_type = \$2\s+ORDER BY id DESC\s+LIMIT \$3`).
+		WithArgs(target, models.AuditLoginFailed, 100).
+		WillReturnRows(rows)
+	mock.ExpectExec("INSERT INTO audit_events").
+		WithArgs(models.AuditAdminAuditSearch, int64(1), nil, "10.0.0.9", "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(11, 1))
+
+	events, err := service.Search(1, models.AuditFilter{TargetID: &target, EventType: models.AuditLoginFailed},
+		utils.RequestMeta{IP: "10.0.0.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Metadata["reason"] != "wrong_password" || events[0].ActorID != nil {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -3,7 +3,9 @@ package tests
 import (
 	"bytes"
 	"dl/handlers"
+	"dl/repositories"
 	"dl/services"
+	"dl/utils"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -17,6 +19,10 @@ func TestRegisterHandler(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
+	mock.ExpectQuery("username_skeleton").
+		WithArgs("UserTest", 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"taken"}).AddRow(false))
+
 	mock.ExpectQuery("INSERT INTO users").
 		WithArgs("UserTest", "testemail@gmail.com", sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
@@ -25,7 +31,7 @@ func TestRegisterHandler(t *testing.T) {
 		WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	authService := &services.AuthService{DB: db}
+	authService := services.NewAuthService(repositories.NewUserRepository(db), utils.NewArgon2Hasher(utils.DefaultArgon2Params), nil)
 	handler := &handlers.AuthHandler{Service: authService}
 
 	body := map[string]string{
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"database/sql"
 	"dl/handlers"
+	"dl/repositories"
 	"dl/services"
+	"dl/utils"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -18,6 +20,9 @@ func setupTestDB(t *testing.T) *sql.DB {
 	if err != nil {
 		t.Fatalf("failed to connect test DB: %v", err)
 	}
+	if err := db.Ping(); err != nil {
+		t.Skipf("test DB is not available: %v", err)
+	}
 	db.Exec("TRUNCATE users, email_verifications RESTART IDENTITY CASCADE;")
 	return db
 }
@@ -26,7 +31,7 @@ func TestRegisterIntegration(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	service := &services.AuthService{DB: db}
+	service := services.NewAuthService(repositories.NewUserRepository(db), utils.NewArgon2Hasher(utils.DefaultArgon2Params), nil)
 	handler := &handlers.AuthHandler{Service: service}
 
 	body := map[string]string{
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"bytes"
+	"dl/repositories"
+	"dl/services"
+	"dl/storage"
+	"dl/utils"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 100, 255})
+		}
+	}
+	return img
+}
+
+// jpegWithOrientation вставляет APP1/Exif с тегом Orientation и GPS-маркером сразу после SOI
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, []byte("\x00\x00\x00\x00GPS-SECRET")...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	seg = append(seg, payload...)
+
+	raw := buf.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, seg...), raw[2:]...)
+}
+
+func decodeSize(t *testing.T, data []byte) (int, int) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "jpeg" {
+		t.Fatalf("output is not jpeg: %v %s", err, format)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestProcessAvatarResizesAndCrops(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(1600, 800)); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := utils.ProcessAvatar(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h := decodeSize(t, out.Main); w != 1024 || h != 512 {
+		t.Errorf("main size %dx%d, want 1024x512", w, h)
+	}
+	for _, size := range utils.AvatarThumbnailSizes {
+		if w, h := decodeSize(t, out.Thumbnails[size]); w != size || h != size {
+			t.Errorf("thumbnail %d is %dx%d", size, w, h)
+		}
+	}
+}
+
+func TestProcessAvatarAppliesAndStripsExif(t *testing.T) {
+	data := jpegWithOrientation(t, testImage(200, 100), 6) // повернуть на 90°
+
+	out, err := utils.ProcessAvatar(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h := decodeSize(t, out.Main); w != 100 || h != 200 {
+		t.Errorf("orientation not applied: %dx%d", w, h)
+	}
+	if bytes.Contains(out.Main, []byte("Exif")) || bytes.Contains(out.Main, []byte("GPS-SECRET")) {
+		t.Error("metadata leaked into re-encoded image")
+	}
+}
+
+func TestProcessAvatarRejectsBadInput(t *testing.T) {
+	var tiny bytes.Buffer
+	_ = png.Encode(&tiny, testImage(10, 10))
+
+	tests := map[string][]byte{
+		"html renamed to png": []byte("<html><script>alert(1)</script></html>"),
+		"truncated png":       tiny.Bytes()[:20],
+		"too small":           tiny.Bytes(),
+		"too large":           make([]byte, utils.MaxAvatarBytes+1),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := utils.ProcessAvatar(data); !errors.Is(err, utils.ErrInvalidImage) {
+				t.Fatalf("got %v, want ErrInvalidImage", err)
+			}
+		})
+	}
+}
+
+func TestUpdateAvatarReplacesOldFiles(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	uploads := t.TempDir()
+	store, _ := storage.NewLocalStorage(uploads, "/uploads")
+	service := services.NewProfileService(repositories.NewProfileRepository(db), nil, store, 0, nil)
+
+	oldPublic := "/uploads/users/0123456789abcdef0123456789abcdef.jpg"
+	oldFiles := []string{filepath.Join(uploads, "users", "0123456789abcdef0123456789abcdef.jpg")}
+	for _, thumb := range utils.AvatarThumbnailURLs(oldPublic) {
+		oldFiles = append(oldFiles, filepath.Join(uploads, thumb[len("/uploads/"):]))
+	}
+	_ = os.MkdirAll(filepath.Join(uploads, "users"), 0755)
+	for _, f := range oldFiles {
+		_ = os.WriteFile(f, []byte("old"), 0644)
+	}
+
+	cols := []string{"id", "username", "first_name", "last_name", "gender", "birth_date", "bio", "profile_picture", "rating", "email", "updated_at"}
+	mock.ExpectQuery("FROM users WHERE id").WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(3, "eco", nil, nil, nil, nil, nil, oldPublic, 0, "e@x.io", time.Now()))
+	mock.ExpectExec("UPDATE users SET profile_picture").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, testImage(300, 300))
+
+	publicPath, thumbs, err := service.UpdateAvatar(3, buf.Bytes(), utils.RequestMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thumbs) != len(utils.AvatarThumbnailSizes) {
+		t.Errorf("thumbnails: %v", thumbs)
+	}
+	if _, err := os.Stat(filepath.Join(uploads, publicPath[len("/uploads/"):])); err != nil {
+		t.Errorf("new avatar not written: %v", err)
+	}
+	for _, f := range oldFiles {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("old file %s not removed", f)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
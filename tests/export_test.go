@@ -0,0 +1,401 @@
+package tests
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"dl/handlers"
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/storage"
+	"dl/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestURLSignerVerify(t *testing.T) {
+	signer := utils.NewURLSigner("test-signing-secret")
+	expires := time.Now().Add(time.Hour)
+	sig := signer.Sign("export:7", expires)
+
+	if err := signer.Verify("export:7", expires.Unix(), sig); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		resource string
+		expires  int64
+		sig      string
+		want     error
+	}{
+		{"other resource", "export:8", expires.Unix(), sig, utils.ErrSignatureInvalid},
+		{"extended expiry", "export:7", expires.Add(time.Hour).Unix(), sig, utils.ErrSignatureInvalid},
+		{"garbage signature", "export:7", expires.Unix(), "not-hex", utils.ErrSignatureInvalid},
+		{"other secret", "export:7", expires.Unix(), utils.NewURLSigner("another-secret").Sign("export:7", expires), utils.ErrSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := signer.Verify(tt.resource, tt.expires, tt.sig); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if err := signer.Verify("export:7", past.Unix(), signer.Sign("export:7", past)); !errors.Is(err, utils.ErrSignatureExpired) {
+		t.Fatalf("expired link: got %v", err)
+	}
+}
+
+var exportRowColumns = []string{"id", "user_id", "status", "file_path", "size_bytes", "error", "created_at", "completed_at", "expires_at"}
+
+func newExportWorker(t *testing.T) (*services.ExportService, sqlmock.Sqlmock, *sql.DB, string) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	store, err := storage.NewLocalStorage(dir, "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := services.NewExportService(repositories.NewExportRepository(db), repositories.NewProfileRepository(db),
+		repositories.NewRatingRepository(db), utils.NewURLSigner("test-signing-secret"), store, nil)
+	return service, mock, db, dir
+}
+
+// runExportWorkerOnce — один проход воркера: отменённый ctx завершает Run после разбора очереди
+func runExportWorkerOnce(service *services.ExportService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	service.Run(ctx)
+}
+
+func expectExportClaim(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE data_exports SET status = 'failed', error = \\$2, completed_at = \\$3\\s+WHERE status = 'running'").
+		WithArgs(sqlmock.AnyArg(), "export timed out", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("UPDATE data_exports SET status = 'running', started_at = NOW\\(\\)").
+		WillReturnRows(sqlmock.NewRows(exportRowColumns).
+			AddRow(11, 7, models.ExportRunning, "", 0, "", time.Now(), nil, nil))
+}
+
+func expectExportPersonalData(mock sqlmock.Sqlmock) {
+	now := time.Now()
+	mock.ExpectQuery("FROM users WHERE id = \\$1").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "first_name", "last_name", "gender", "birth_date",
+			"bio", "profile_picture", "rating", "email", "updated_at"}).
+			AddRow(7, "greenfox", "Anna", nil, "female", "1990-04-01", "Hi", "/uploads/users/avatar-7.png", 120, "anna@example.com", now))
+	mock.ExpectQuery("FROM eco_answers a").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"question_id", "category", "question", "value", "created_at"}).
+			AddRow(3, "water", "How long is your shower?", 2, now))
+	mock.ExpectQuery("FROM eco_results").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"total_score", "category", "description", "created_at"}).
+			AddRow(70, "Eco Impactful", "Room to improve", now))
+	mock.ExpectQuery("FROM user_actions ua").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "points", "created_at"}).AddRow("Recycled, sorted", 5, now))
+	mock.ExpectQuery("FROM notifications").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"message", "read", "created_at"}).AddRow("Welcome", true, now))
+	mock.ExpectQuery("FROM news_bookmarks").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"action", "id", "title", "link", "created_at"}).
+			AddRow(models.NewsBookmark, 42, "Rivers", "https://example.com/rivers", now))
+}
+
+func exportArchives(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "exports", "export-11-*.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestExportWorkerBuildsArchive(t *testing.T) {
+	service, mock, db, dir := newExportWorker(t)
+	defer db.Close()
+
+	if err := os.MkdirAll(filepath.Join(dir, "users"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "users", "avatar-7.png"), []byte("png-bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectExportClaim(mock)
+	expectExportPersonalData(mock)
+	mock.ExpectExec("SET status = 'ready'").
+		WithArgs(int64(11), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE data_exports SET status = 'running'").WillReturnError(sql.ErrNoRows)
+
+	runExportWorkerOnce(service)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	archives := exportArchives(t, dir)
+	if len(archives) != 1 {
+		t.Fatalf("expected one archive in storage, got %v", archives)
+	}
+
+	zr, err := zip.OpenReader(archives[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(b)
+	}
+
+	for _, name := range []string{"profile.json", "eco_answers.json", "eco_results.json", "user_actions.json",
+		"notifications.json", "news_activity.json", "eco_answers.csv", "eco_results.csv", "user_actions.csv",
+		"notifications.csv", "avatar/avatar-7.png", "README.txt"} {
+		if _, ok := contents[name]; !ok {
+			t.Errorf("archive has no %s", name)
+		}
+	}
+	if len(contents) != 12 {
+		t.Errorf("unexpected archive entries: %d", len(contents))
+	}
+
+	var profile map[string]interface{}
+	if err := json.Unmarshal([]byte(contents["profile.json"]), &profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile["username"] != "greenfox" || profile["email"] != "anna@example.com" || profile["last_name"] != "" {
+		t.Errorf("unexpected profile.json: %v", profile)
+	}
+	if contents["avatar/avatar-7.png"] != "png-bytes" {
+		t.Errorf("unexpected avatar contents %q", contents["avatar/avatar-7.png"])
+	}
+	if !strings.HasPrefix(contents["user_actions.csv"], "action_name,points,created_at\n\"Recycled, sorted\",5,") {
+		t.Errorf("unexpected user_actions.csv: %q", contents["user_actions.csv"])
+	}
+	if !strings.Contains(contents["news_activity.json"], `"news_id": 42`) {
+		t.Errorf("unexpected news_activity.json: %s", contents["news_activity.json"])
+	}
+}
+
+func TestExportWorkerMarksFailedBuild(t *testing.T) {
+	service, mock, db, dir := newExportWorker(t)
+	defer db.Close()
+
+	expectExportClaim(mock)
+	mock.ExpectQuery("FROM users WHERE id = \\$1").WithArgs(int64(7)).WillReturnError(errors.New("connection reset"))
+	mock.ExpectExec("UPDATE data_exports SET status = 'failed', error = \\$2, completed_at = \\$3 WHERE id = \\$1").
+		WithArgs(int64(11), "failed to build export", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE data_exports SET status = 'running'").WillReturnError(sql.ErrNoRows)
+
+	runExportWorkerOnce(service)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if archives := exportArchives(t, dir); len(archives) != 0 {
+		t.Errorf("expected no archive for a failed export, got %v", archives)
+	}
+}
+
+func TestExportWorkerDropsArchiveOfTimedOutJob(t *testing.T) {
+	service, mock, db, dir := newExportWorker(t)
+	defer db.Close()
+
+	// пока архив собирался, выгрузку сняли по таймауту — готовой она не становится
+	expectExportClaim(mock)
+	expectExportPersonalData(mock)
+	mock.ExpectExec("SET status = 'ready'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("UPDATE data_exports SET status = 'running'").WillReturnError(sql.ErrNoRows)
+
+	runExportWorkerOnce(service)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if archives := exportArchives(t, dir); len(archives) != 0 {
+		t.Errorf("expected the orphaned archive to be removed, got %v", archives)
+	}
+}
+
+func TestFailStaleExports(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	repo := repositories.NewExportRepository(db)
+
+	cutoff := time.Now().Add(-30 * time.Minute)
+	mock.ExpectExec("WHERE status = 'running' AND COALESCE\\(started_at, created_at\\) < \\$1").
+		WithArgs(cutoff, "export timed out", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.FailStaleExports(cutoff, "export timed out")
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 stale exports, got %d, %v", n, err)
+	}
+
+	mock.ExpectExec("SET status = 'ready'.*WHERE id = \\$1 AND status = 'running'").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.MarkExportReady(11, "exports/x.zip", 10, time.Now()); !errors.Is(err, repositories.ErrExportNotRunning) {
+		t.Errorf("expected ErrExportNotRunning for a timed-out job, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func downloadExport(t *testing.T, service *services.ExportService, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	return downloadExportWith(t, &handlers.ExportHandler{Service: service}, header)
+}
+
+func downloadExportWith(t *testing.T, handler *handlers.ExportHandler, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	service := handler.Service
+	expires := time.Now().Add(time.Hour)
+	sig := service.Signer.Sign("export:11", expires)
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/export-data/download?id=11&expires=%d&sig=%s", expires.Unix(), sig), nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	handler.Download(rr, req)
+	return rr
+}
+
+func expectReadyExport(mock sqlmock.Sqlmock, filePath string, size int) {
+	mock.ExpectQuery("FROM data_exports WHERE id = \\$1").WithArgs(int64(11)).
+		WillReturnRows(sqlmock.NewRows(exportRowColumns).
+			AddRow(11, 7, models.ExportReady, filePath, size, "", time.Now(), time.Now(), time.Now().Add(time.Hour)))
+}
+
+func TestExportDownloadSupportsRanges(t *testing.T) {
+	service, mock, db, dir := newExportWorker(t)
+	defer db.Close()
+
+	if err := os.MkdirAll(filepath.Join(dir, "exports"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "exports", "export-11-abc.zip"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectReadyExport(mock, "exports/export-11-abc.zip", 10)
+	rr := downloadExport(t, service, map[string]string{"Range": "bytes=4-"})
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "456789" {
+		t.Fatalf("expected 206 with the tail, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/zip" || rr.Header().Get("Content-Range") != "bytes 4-9/10" {
+		t.Errorf("unexpected headers: %v", rr.Header())
+	}
+
+	expectReadyExport(mock, "exports/export-11-abc.zip", 10)
+	if rr := downloadExport(t, service, nil); rr.Code != http.StatusOK || rr.Body.String() != "0123456789" {
+		t.Errorf("expected full archive, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExportDownloadRedirectsToStorage(t *testing.T) {
+	service, mock, db, _ := newExportWorker(t)
+	defer db.Close()
+	handler := &handlers.ExportHandler{Service: service, Redirect: true}
+
+	// архив в хранилище — отдаёт само хранилище по подписанной ссылке
+	expectReadyExport(mock, "exports/export-11-abc.zip", 10)
+	rr := downloadExportWith(t, handler, nil)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/uploads/exports/export-11-abc.zip" {
+		t.Fatalf("expected redirect to storage, got %d %v", rr.Code, rr.Header())
+	}
+
+	// старый архив на диске по-прежнему отдаём сами
+	legacyDir := t.TempDir()
+	service.LegacyExportsDir = legacyDir
+	legacy := filepath.Join(legacyDir, "export-11-old.zip")
+	if err := os.WriteFile(legacy, []byte("legacy-zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectReadyExport(mock, legacy, 10)
+	expectReadyExport(mock, legacy, 10)
+	if rr := downloadExportWith(t, handler, nil); rr.Code != http.StatusOK || rr.Body.String() != "legacy-zip" {
+		t.Fatalf("expected legacy archive, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExportLegacyFilePathFallback(t *testing.T) {
+	service, mock, db, _ := newExportWorker(t)
+	defer db.Close()
+
+	// архив, собранный до перехода на Storage: в file_path абсолютный путь в EXPORTS_DIR
+	legacyDir := t.TempDir()
+	service.LegacyExportsDir = legacyDir
+	legacy := filepath.Join(legacyDir, "export-11-old.zip")
+	if err := os.WriteFile(legacy, []byte("legacy-zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectReadyExport(mock, legacy, 10)
+	if rr := downloadExport(t, service, nil); rr.Code != http.StatusOK || rr.Body.String() != "legacy-zip" {
+		t.Fatalf("expected legacy archive, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// путь вне EXPORTS_DIR не читается с диска
+	outside := filepath.Join(t.TempDir(), "secret.zip")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectReadyExport(mock, outside, 6)
+	if rr := downloadExport(t, service, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a path outside EXPORTS_DIR, got %d", rr.Code)
+	}
+
+	// первый архив удалить не удаётся (непустой каталог) — остальные всё равно чистятся
+	stuck := filepath.Join(legacyDir, "export-10-stuck.zip")
+	if err := os.MkdirAll(filepath.Join(stuck, "inner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery("WHERE status = 'ready' AND expires_at < \\$1").
+		WillReturnRows(sqlmock.NewRows(exportRowColumns).
+			AddRow(10, 7, models.ExportReady, stuck, 10, "", time.Now(), time.Now(), time.Now().Add(-time.Hour)).
+			AddRow(11, 7, models.ExportReady, legacy, 10, "", time.Now(), time.Now(), time.Now().Add(-time.Hour)))
+	mock.ExpectExec("SET status = 'expired'").WithArgs(int64(11)).WillReturnResult(sqlmock.NewResult(0, 1))
+	if n, err := service.CleanupExpired(); err != nil || n != 1 {
+		t.Fatalf("cleanup: %d, %v", n, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("expected the legacy archive to be removed, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
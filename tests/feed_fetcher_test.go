@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"context"
+	"database/sql/driver"
+	"dl/repositories"
+	"dl/services"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestFeedFetcherConditionalGet(t *testing.T) {
+	const etag = `"v1"`
+	const modified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+	var full, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" || strings.Contains(r.Header.Get("User-Agent"), "Go-http-client") {
+			t.Errorf("missing fetcher User-Agent: %q", r.Header.Get("User-Agent"))
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&full, 1)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", modified)
+		w.Write([]byte(testRSS))
+	}))
+	defer srv.Close()
+
+	f := services.NewFeedFetcher()
+
+	res, err := f.Fetch(context.Background(), srv.URL, "", "")
+	if err != nil || res.NotModified || len(res.Feed.Items) != 2 {
+		t.Fatalf("first fetch: %+v %v", res, err)
+	}
+	if res.ETag != etag || res.LastModified != modified {
+		t.Errorf("validators not returned: %q %q", res.ETag, res.LastModified)
+	}
+
+	// 304 без заголовков — валидаторы сохраняются прежние
+	res, err = f.Fetch(context.Background(), srv.URL, res.ETag, res.LastModified)
+	if err != nil || !res.NotModified || res.Feed != nil || res.ETag != etag || res.LastModified != modified {
+		t.Fatalf("conditional fetch: %+v %v", res, err)
+	}
+	if full != 1 || notModified != 1 {
+		t.Errorf("expected 1 full and 1 conditional request, got %d/%d", full, notModified)
+	}
+}
+
+func TestFeedFetcherErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		case "/busy":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/huge":
+			w.Write([]byte(strings.Repeat("x", 2048)))
+		}
+	}))
+	defer srv.Close()
+
+	f := services.NewFeedFetcher()
+	f.Timeout = 50 * time.Millisecond
+	f.MaxBytes = 1024
+
+	started := time.Now()
+	if _, err := f.Fetch(context.Background(), srv.URL+"/slow", "", ""); err == nil || time.Since(started) > 500*time.Millisecond {
+		t.Errorf("timeout not applied: %v after %v", err, time.Since(started))
+	}
+
+	_, err := f.Fetch(context.Background(), srv.URL+"/busy", "", "")
+	var httpErr *services.FeedHTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable || httpErr.RetryAfter != 120*time.Second {
+		t.Errorf("busy: %v", err)
+	}
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/huge", "", ""); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("size limit: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Fetch(ctx, srv.URL, "", ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context: %v", err)
+	}
+}
+
+// nextFetchIn проверяет, что next_fetch_at примерно через d от момента вызова
+type nextFetchIn time.Duration
+
+func (d nextFetchIn) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	diff := time.Until(t) - time.Duration(d)
+	return diff > -time.Minute && diff < time.Minute
+}
+
+func TestUpdateNewsBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" {
+			w.Header().Set("Retry-After", "36000")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	now := time.Now()
+	rows := sqlmock.NewRows(newsSourceRowColumns).
+		// три ошибки подряд: 10 мин * 2^3
+		AddRow(1, srv.URL+"/down", "Down", "ru", "", 10, true, now, now, now, nil, "error", "x", nil, 3, `"v1"`, "").
+		// очень долго падает — не больше суток
+		AddRow(2, srv.URL+"/down", "Dead", "ru", "", 60, true, now, now, now, nil, "error", "x", nil, 20, "", "").
+		// Retry-After больше обычного backoff
+		AddRow(3, srv.URL+"/limited", "Limited", "ru", "", 30, true, now, now, now, nil, "", "", nil, 0, "", "")
+	mock.ExpectQuery("FROM news_sources\\s+WHERE enabled").WillReturnRows(rows)
+
+	// при ошибке валидаторы не теряются
+	mock.ExpectExec("UPDATE news_sources SET").
+		WithArgs(int64(1), sqlmock.AnyArg(), "error", sqlmock.AnyArg(), 0, nextFetchIn(80*time.Minute), `"v1"`, "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE news_sources SET").
+		WithArgs(int64(2), sqlmock.AnyArg(), "error", sqlmock.AnyArg(), 0, nextFetchIn(24*time.Hour), "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE news_sources SET").
+		WithArgs(int64(3), sqlmock.AnyArg(), "error", sqlmock.AnyArg(), 0, nextFetchIn(10*time.Hour), "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	service := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	service.Concurrency = 1
+	if err := service.UpdateNews(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateNewsBoundedConcurrency(t *testing.T) {
+	var (
+		mu               sync.Mutex
+		inFlight, maxObs int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxObs {
+			maxObs = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	mock.MatchExpectationsInOrder(false)
+
+	now := time.Now()
+	rows := sqlmock.NewRows(newsSourceRowColumns)
+	for i := 1; i <= 6; i++ {
+		rows.AddRow(i, srv.URL, "S", "ru", "", 30, true, now, now, now, nil, "ok", "", 2, 0, `"v1"`, "")
+	}
+	mock.ExpectQuery("FROM news_sources\\s+WHERE enabled").WillReturnRows(rows)
+	for i := 1; i <= 6; i++ {
+		mock.ExpectExec("UPDATE news_sources SET").
+			WithArgs(int64(i), sqlmock.AnyArg(), "not_modified", "", 0, nextFetchIn(30*time.Minute), `"v1"`, "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	service := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	service.Concurrency = 2
+	if err := service.UpdateNews(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if maxObs != 2 {
+		t.Errorf("expected at most 2 parallel fetches (and some parallelism), got %d", maxObs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateNewsCancelledDoesNotMarkFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM news_sources\\s+WHERE enabled").WillReturnRows(
+		newsSourceRow(sqlmock.NewRows(newsSourceRowColumns), 1, srv.URL, 30))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	service := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	if err := service.UpdateNews(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context error, got %v", err)
+	}
+	if time.Since(now) > time.Second {
+		t.Error("cancellation did not stop the fetch")
+	}
+	// никаких UPDATE news_sources — статус источника не тронут
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFetchPageRefusesNonPublicAddresses(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	f := services.NewFeedFetcher()
+	for _, link := range []string{
+		srv.URL + "/article",                       // loopback
+		"http://169.254.169.254/latest/meta-data/", // метаданные облака
+		"http://10.0.0.1/",
+		"http://[::1]/",
+		"file:///etc/passwd",
+		"gopher://example.com/",
+	} {
+		if _, err := f.FetchPage(context.Background(), link, 1024); !errors.Is(err, services.ErrForbiddenPageURL) {
+			t.Errorf("%s: expected ErrForbiddenPageURL, got %v", link, err)
+		}
+	}
+	if hits != 0 {
+		t.Errorf("expected no requests to reach the loopback server, got %d", hits)
+	}
+}
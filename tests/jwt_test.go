@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"dl/utils"
+)
+
+const testJWTSecret = "test-secret-0123456789abcdefghijklmnop"
+
+// setupTestKeys настраивает HS256-ключ для тестов, которым нужны токены
+func setupTestKeys(t *testing.T) *utils.KeyManager {
+	t.Helper()
+
+	key, err := utils.NewHMACKey(testJWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	km := utils.NewKeyManager("ecofoot", "ecofoot-api")
+	if err := km.SetActive(key); err != nil {
+		t.Fatal(err)
+	}
+	utils.SetKeyManager(km)
+	return km
+}
+
+func TestWeakSecretRejected(t *testing.T) {
+	for _, secret := range []string{"", "my-secret"} {
+		if _, err := utils.NewHMACKey(secret); err == nil {
+			t.Errorf("expected secret %q to be rejected", secret)
+		}
+	}
+}
+
+func TestRefreshTokenIsNotAccessToken(t *testing.T) {
+	setupTestKeys(t)
+
+	access, refresh, err := utils.GenerateTokens(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := utils.ParseToken(access)
+	if err != nil || claims.UserID != 7 {
+		t.Fatalf("access token should be valid: %v", err)
+	}
+	if _, err := utils.ParseToken(refresh); err == nil {
+		t.Error("refresh token must not be accepted as access token")
+	}
+	if _, err := utils.ParseRefreshToken(refresh); err != nil {
+		t.Errorf("refresh token should be valid: %v", err)
+	}
+}
+
+func TestKeyRotationGracePeriod(t *testing.T) {
+	km := setupTestKeys(t)
+
+	oldAccess, _, err := utils.GenerateTokens(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// переключаемся на EdDSA, старый HS256-ключ остаётся в grace-периоде
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	newKey, err := utils.NewAsymmetricKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := km.SetActive(newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := utils.ParseToken(oldAccess); err != nil {
+		t.Errorf("token signed with previous key should be accepted: %v", err)
+	}
+
+	newAccess, _, err := utils.GenerateTokens(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.ParseToken(newAccess); err != nil {
+		t.Errorf("token signed with new key should be accepted: %v", err)
+	}
+
+	jwks := km.PublicJWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != newKey.Kid || jwks.Keys[0].Kty != "OKP" {
+		t.Errorf("JWKS should contain only the EdDSA key, got %+v", jwks.Keys)
+	}
+
+	// после окончания grace-периода старый ключ не принимается
+	oldKey, _ := utils.NewHMACKey(testJWTSecret)
+	km.AddVerificationKey(oldKey, time.Now().Add(-time.Second))
+	if _, err := utils.ParseToken(oldAccess); err == nil {
+		t.Error("token signed with retired key must be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"bytes"
+	"dl/handlers"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMagicLinkRequiresConfirmationOnOtherDevice(t *testing.T) {
+	setupTestKeys(t)
+
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	handler := &handlers.AuthHandler{Service: services.NewAuthService(repositories.NewUserRepository(db), utils.NewArgon2Hasher(utils.DefaultArgon2Params), nil)}
+
+	linkRow := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"user_id", "device_hash", "expires_at", "used_at"}).
+			AddRow(1, utils.HashToken("device-a"), time.Now().Add(time.Minute), nil)
+	}
+
+	expectLink := func() {
+		mock.ExpectQuery("SELECT user_id, device_hash, expires_at, used_at").
+			WithArgs(utils.HashToken("tok")).
+			WillReturnRows(linkRow())
+	}
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/magic-link/verify", bytes.NewBufferString(body))
+		req.AddCookie(&http.Cookie{Name: "magic_device", Value: "device-b"})
+		rr := httptest.NewRecorder()
+		handler.ConsumeMagicLink(rr, req)
+		return rr
+	}
+
+	// другое устройство без кода — 409, на email уходит код, ссылка не тратится
+	// (старый клиентский флаг confirm больше ничего не подтверждает)
+	expectLink()
+	mock.ExpectQuery("SELECT username, email FROM users").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "email"}).AddRow("alice", "alice@example.com"))
+	mock.ExpectExec("UPDATE magic_links SET confirm_code_hash").
+		WithArgs(utils.HashToken("tok"), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if rr := send(`{"token":"tok","confirm":true}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// неверный код — 401
+	expectLink()
+	mock.ExpectQuery("UPDATE magic_links SET confirm_attempts").
+		WithArgs(utils.HashToken("tok"), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"confirm_code_hash"}).AddRow(utils.HashToken("123456")))
+
+	if rr := send(`{"token":"tok","code":"000000"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong code, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// верный код — ссылка используется
+	expectLink()
+	mock.ExpectQuery("UPDATE magic_links SET confirm_attempts").
+		WithArgs(utils.HashToken("tok"), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"confirm_code_hash"}).AddRow(utils.HashToken("123456")))
+	mock.ExpectQuery("UPDATE magic_links SET used_at").
+		WithArgs(utils.HashToken("tok"), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+	mock.ExpectExec("UPDATE users SET is_verified").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if rr := send(`{"token":"tok","code":"123456"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMagicLinkCodeAttemptsExhausted(t *testing.T) {
+	setupTestKeys(t)
+
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	handler := &handlers.AuthHandler{Service: services.NewAuthService(repositories.NewUserRepository(db), utils.NewArgon2Hasher(utils.DefaultArgon2Params), nil)}
+
+	mock.ExpectQuery("SELECT user_id, device_hash, expires_at, used_at").
+		WithArgs(utils.HashToken("tok")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "device_hash", "expires_at", "used_at"}).
+			AddRow(1, utils.HashToken("device-a"), time.Now().Add(time.Minute), nil))
+	mock.ExpectQuery("UPDATE magic_links SET confirm_attempts").
+		WithArgs(utils.HashToken("tok"), 5).
+		WillReturnRows(sqlmock.NewRows([]string{"confirm_code_hash"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/magic-link/verify", bytes.NewBufferString(`{"token":"tok","code":"123456"}`))
+	req.AddCookie(&http.Cookie{Name: "magic_device", Value: "device-b"})
+	rr := httptest.NewRecorder()
+	handler.ConsumeMagicLink(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dl/classifier"
+	"dl/handlers"
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestClassifierCategories(t *testing.T) {
+	c := classifier.Default()
+
+	cases := []struct {
+		name string
+		doc  classifier.Document
+		want string
+	}{
+		{"ru water", classifier.Document{Title: "Уровень воды в Арале снова упал", Text: "Засуха и орошение истощают водоёмы.", Language: "ru"}, classifier.Water},
+		{"ru waste", classifier.Document{Title: "В Алматы открыли пункт приёма вторсырья", Text: "Раздельный сбор мусора и переработка пластика.", Language: "ru"}, classifier.Waste},
+		{"en transport", classifier.Document{Title: "City adds new electric buses", Text: "Public transport ridership grows as tram lines expand.", Language: "en"}, classifier.Transport},
+		{"en energy", classifier.Document{Title: "Solar farm connected to the grid", Text: "The new plant will supply electricity to 40,000 homes.", Language: "en"}, classifier.Energy},
+		{"unknown language uses all rules", classifier.Document{Title: "Landfill closed", Text: "Waste will be sent for recycling.", Language: "kk"}, classifier.Waste},
+		{"below threshold", classifier.Document{Title: "Mayor opens a new park", Text: "Residents came to the ceremony.", Language: "en"}, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := c.Classify(tc.doc).Category; got != tc.want {
+				t.Errorf("expected category %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestClassifierTags(t *testing.T) {
+	res := classifier.Default().Classify(classifier.Document{
+		Title:    "Plastic recycling plant opens",
+		Text:     "The plant cuts carbon emissions.",
+		Language: "en",
+		Labels:   []string{"Climate Change", "plastic", ""},
+	})
+
+	want := map[string]bool{"plastic": true, "recycling": true, "climate": true, "climate-change": true}
+	seen := map[string]bool{}
+	for _, tag := range res.Tags {
+		if seen[tag] {
+			t.Errorf("duplicate tag %q in %v", tag, res.Tags)
+		}
+		seen[tag] = true
+	}
+	for tag := range want {
+		if !seen[tag] {
+			t.Errorf("expected tag %q in %v", tag, res.Tags)
+		}
+	}
+}
+
+func TestClassifierLoadRejectsUnknownCategory(t *testing.T) {
+	_, err := classifier.Load(strings.NewReader(`{"en": {"categories": {"politics": {"election": 2}}}}`))
+	if err == nil {
+		t.Fatal("expected error for unknown category")
+	}
+}
+
+var newsRowColumns = []string{"id", "title", "link", "published_at", "source", "description", "source_id",
+	"excerpt", "image_url", "author", "language", "category", "tags", "category_source", "duplicate_of", "also_reported_by", "archived", "like_count"}
+
+func newsClassifyRequest(t *testing.T, h *handlers.NewsHandler, id, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPatch, "/admin/news/"+id, bytes.NewBufferString(body))
+	req.SetPathValue("id", id)
+	req = req.WithContext(utils.ContextWithUserID(req.Context(), 5))
+	rr := httptest.NewRecorder()
+	h.Classify(rr, req)
+	return rr
+}
+
+func TestModeratorOverridesCategory(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	mock.ExpectQuery("FROM news n WHERE n.id = \\$1").WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows(newsRowColumns).AddRow(9, "Bus lanes", "https://example.com/9", time.Now(),
+			"Example", "", 1, "", "", "", "en", "transport", pq.StringArray{"transport"}, models.CategoryAuto, 0, "[]", false, 0))
+	mock.ExpectExec("UPDATE news SET category").
+		WithArgs(int64(9), "energy", pq.Array([]string{"electric-buses", "ev"}), models.CategoryModerator, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	h := handlers.NewNewsHandler(services.NewNewsService(repositories.NewNewsRepository(db), nil))
+	rr := newsClassifyRequest(t, h, "9", `{"category": "energy", "tags": ["Electric buses", "EV", "ev"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var item models.NewsItem
+	json.NewDecoder(rr.Body).Decode(&item)
+	if item.Category != "energy" || item.CategorySource != models.CategoryModerator {
+		t.Errorf("unexpected classification: %+v", item)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModeratorResetsToAutomaticClassification(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	mock.ExpectQuery("FROM news n WHERE n.id = \\$1").WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows(newsRowColumns).AddRow(9, "Landfill to close next year", "https://example.com/9", time.Now(),
+			"Example", "", 0, "Waste will go to a new recycling plant.", "", "", "en", "food", pq.StringArray{}, models.CategoryModerator, 0, "[]", false, 0))
+	mock.ExpectExec("UPDATE news SET category").
+		WithArgs(int64(9), classifier.Waste, sqlmock.AnyArg(), models.CategoryAuto, int64(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	h := handlers.NewNewsHandler(services.NewNewsService(repositories.NewNewsRepository(db), nil))
+	rr := newsClassifyRequest(t, h, "9", `{"category": null}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModeratorOverrideValidation(t *testing.T) {
+	db, _, _ := sqlmock.New()
+	defer db.Close()
+
+	h := handlers.NewNewsHandler(services.NewNewsService(repositories.NewNewsRepository(db), nil))
+	rr := newsClassifyRequest(t, h, "9", `{"category": "politics"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	var body struct {
+		Fields map[string]utils.FieldError `json:"fields"`
+	}
+	json.NewDecoder(rr.Body).Decode(&body)
+	if body.Fields["category"].Code != utils.FieldInvalidChoice {
+		t.Errorf("expected invalid_choice for category, got %+v", body.Fields)
+	}
+
+	rr = newsClassifyRequest(t, h, "9", `{}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), services.ErrNoClassificationFields.Error()) {
+		t.Errorf("expected 400 for an empty patch, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestSetClassificationStoresNoSourceAsNull(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	// классификатор ничего не нашёл — category_source NULL, а не пустая строка
+	mock.ExpectExec("SET category = NULLIF\\(\\$2, ''\\), tags = \\$3, category_source = NULLIF\\(\\$4, ''\\)").
+		WithArgs(int64(9), "", sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repositories.NewNewsRepository(db).SetClassification(9, "", []string{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
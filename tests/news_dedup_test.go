@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	cases := map[string]string{
+		"https://www.Example.com/news/1/?utm_source=rss&utm_medium=feed": "example.com/news/1",
+		"http://example.com:80/news/1#comments":                          "example.com/news/1",
+		"https://example.com/a?b=2&fbclid=xyz&a=1":                       "example.com/a?a=1&b=2",
+		"https://example.com/":                                           "example.com",
+		"https://example.com:8443/a":                                     "example.com:8443/a",
+		"not a url":                                                      "not a url",
+	}
+	for in, want := range cases {
+		if got := utils.CanonicalURL(in); got != want {
+			t.Errorf("CanonicalURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSimHashSimilarity(t *testing.T) {
+	base := "В Алматы запустили новый пункт приёма вторсырья. Жители смогут сдавать пластик, стекло и макулатуру каждый день с девяти до шести."
+	copyWithTail := base + " Подробнее на сайте акимата."
+	other := "Уровень воды в Капшагайском водохранилище снизился на полтора метра из-за жаркого лета и интенсивного полива."
+
+	near := utils.HammingDistance(utils.SimHash(base), utils.SimHash(copyWithTail))
+	far := utils.HammingDistance(utils.SimHash(base), utils.SimHash(other))
+
+	if near > 8 {
+		t.Errorf("expected near-identical texts within 8 bits, got %d", near)
+	}
+	if far <= 16 {
+		t.Errorf("expected unrelated texts to differ in many bits, got %d", far)
+	}
+	if utils.SimHash("") != 0 {
+		t.Error("expected zero hash for empty text")
+	}
+}
+
+var pendingDedupColumns = []string{"id", "source_id", "title", "excerpt", "link", "published_at"}
+
+func TestDedupPendingClustersDuplicates(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	published := time.Date(2026, 5, 3, 10, 0, 0, 0, time.UTC)
+	text := "Жители смогут сдавать пластик, стекло и макулатуру каждый день с девяти до шести"
+	hash := utils.SimHash("Открыт пункт приёма вторсырья " + text)
+
+	mock.ExpectQuery("FROM news\\s+WHERE dedup_checked_at IS NULL").WithArgs(200).
+		WillReturnRows(sqlmock.NewRows(pendingDedupColumns).
+			AddRow(10, 1, "Открыт пункт приёма вторсырья", text, "https://example.com/a?utm_source=tg", published).
+			AddRow(11, 2, "Открыт пункт приёма вторсырья", text, "https://other.kz/news/5", published).
+			AddRow(12, 2, "Река вышла из берегов", "Паводок затопил три села", "https://other.kz/news/6", published))
+
+	candidateColumns := []string{"id", "canonical_url", "simhash", "duplicate_of"}
+
+	// та же ссылка без меток: дубликат основной новости 3 (кандидат 4 сам дубликат)
+	mock.ExpectQuery("FROM news\\s+WHERE id <> \\$1").
+		WithArgs(int64(10), "example.com/a", published.Add(-72*time.Hour), published.Add(72*time.Hour), int64(1)).
+		WillReturnRows(sqlmock.NewRows(candidateColumns).AddRow(4, "example.com/a", 0, 3))
+	mock.ExpectExec("UPDATE news SET canonical_url").WithArgs(int64(10), "example.com/a", int64(hash), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// другая ссылка, тот же текст
+	mock.ExpectQuery("FROM news\\s+WHERE id <> \\$1").WithArgs(int64(11), "other.kz/news/5", sqlmock.AnyArg(), sqlmock.AnyArg(), int64(2)).
+		WillReturnRows(sqlmock.NewRows(candidateColumns).AddRow(3, "example.com/a", int64(hash^1), 0))
+	mock.ExpectExec("UPDATE news SET canonical_url").WithArgs(int64(11), "other.kz/news/5", int64(hash), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// непохожая новость остаётся основной
+	mock.ExpectQuery("FROM news\\s+WHERE id <> \\$1").WithArgs(int64(12), "other.kz/news/6", sqlmock.AnyArg(), sqlmock.AnyArg(), int64(2)).
+		WillReturnRows(sqlmock.NewRows(candidateColumns).AddRow(3, "example.com/a", int64(hash), 0))
+	mock.ExpectExec("UPDATE news SET canonical_url").WithArgs(int64(12), "other.kz/news/6", sqlmock.AnyArg(), int64(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	service := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	n, err := service.DedupPending(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 checked items, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDedupKeepsSimilarItemsOfOneSource(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	// ежедневная сводка одного источника: тексты почти одинаковые, новости разные
+	published := time.Date(2026, 5, 3, 10, 0, 0, 0, time.UTC)
+	text := "Индекс качества воздуха в Алматы на утро: умеренный, рекомендации для чувствительных групп"
+	mock.ExpectQuery("FROM news\\s+WHERE dedup_checked_at IS NULL").
+		WillReturnRows(sqlmock.NewRows(pendingDedupColumns).
+			AddRow(21, 5, "Качество воздуха 3 мая", text, "https://air.kz/2026-05-03", published).
+			AddRow(22, 5, "Качество воздуха 4 мая", text, "https://air.kz/2026-05-04", published.Add(24*time.Hour)))
+
+	candidateColumns := []string{"id", "canonical_url", "simhash", "duplicate_of"}
+	sameSource := `canonical_url = \$2\s+OR \(published_at BETWEEN \$3 AND \$4 AND COALESCE\(source_id, 0\) <> \$5\)`
+
+	// первая сводка уже проверена, но того же источника — в кандидаты по тексту не попадает
+	mock.ExpectQuery(sameSource).WithArgs(int64(21), "air.kz/2026-05-03", sqlmock.AnyArg(), sqlmock.AnyArg(), int64(5)).
+		WillReturnRows(sqlmock.NewRows(candidateColumns))
+	mock.ExpectExec("UPDATE news SET canonical_url").WithArgs(int64(21), "air.kz/2026-05-03", sqlmock.AnyArg(), int64(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(sameSource).WithArgs(int64(22), "air.kz/2026-05-04", sqlmock.AnyArg(), sqlmock.AnyArg(), int64(5)).
+		WillReturnRows(sqlmock.NewRows(candidateColumns))
+	mock.ExpectExec("UPDATE news SET canonical_url").WithArgs(int64(22), "air.kz/2026-05-04", sqlmock.AnyArg(), int64(0)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	service := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	if _, err := service.DedupPending(200); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsListShowsAlsoReportedBy(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	mentions := `[{"id": 11, "source": "Other", "link": "https://other.kz/news/5"}]`
+	mock.ExpectQuery("WHERE n.duplicate_of IS NULL").
+		WillReturnRows(sqlmock.NewRows(newsRowColumns).AddRow(3, "News", "https://example.com/a", time.Now(), "Example", "", 1,
+			"", "", "", "ru", "waste", pq.StringArray{}, models.CategoryAuto, 0, mentions, false, 0))
+
+	rr := listNews(h, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page models.NewsPage
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Items) != 1 || len(page.Items[0].AlsoReportedBy) != 1 || page.Items[0].AlsoReportedBy[0].Source != "Other" {
+		t.Errorf("unexpected also_reported_by: %+v", page.Items)
+	}
+}
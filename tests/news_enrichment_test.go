@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"context"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	in := `<div class="x"><p onclick="steal()">Hello <b>world</b><script>alert(1)</script></p>` +
+		`<a href="javascript:alert(1)">bad</a> <a href="/article?id=1&x=2" target="_blank">good</a>` +
+		`<img src="/pic.jpg"><iframe src="https://evil"></iframe><style>p{}</style><span>tail</span></div>`
+
+	got := utils.SanitizeHTML(in, "https://news.example.com/feed/")
+	want := `<p>Hello <b>world</b></p><a rel="nofollow noopener noreferrer">bad</a> <a href="https://news.example.com/article?id=1&amp;x=2" rel="nofollow noopener noreferrer">good</a>tail`
+	if got != want {
+		t.Errorf("sanitize:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSafeImageURL(t *testing.T) {
+	base := "https://news.example.com/feed/"
+	tests := []struct {
+		raw, want string
+	}{
+		{"/pics/a.jpg?w=1&h=2", "https://news.example.com/pics/a.jpg?w=1&h=2"},
+		{"thumb.png", "https://news.example.com/feed/thumb.png"},
+		{` https://cdn.example.com/a".jpg`, "https://cdn.example.com/a%22.jpg"},
+		{"javascript:alert(1)", ""},
+		{"data:image/png;base64,AAAA", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := utils.SafeImageURL(tt.raw, base); got != tt.want {
+			t.Errorf("SafeImageURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestPlainTextExcerpt(t *testing.T) {
+	in := `<p>Первый&nbsp;абзац.</p><p>Второй<br>абзац <script>x()</script>с   пробелами</p>`
+	if got := utils.PlainText(in, 0); got != "Первый абзац. Второй абзац с пробелами" {
+		t.Errorf("plain text: %q", got)
+	}
+	if got := utils.PlainText(in, 20); got != "Первый абзац. Второй…" {
+		t.Errorf("excerpt: %q", got)
+	}
+}
+
+const enrichedRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel><title>Feed</title><language>en-US</language>
+<item>
+  <title>With thumbnail</title><link>https://example.com/a</link>
+  <dc:creator>Jane Doe</dc:creator>
+  <media:group><media:thumbnail url="/thumbs/a.jpg"/></media:group>
+  <description><![CDATA[<p>Some <em>text</em><script>bad()</script></p>]]></description>
+</item>
+<item>
+  <title>Needs og:image</title><link>LINK_B</link>
+  <description>Plain</description>
+</item>
+<item>
+  <title>Already stored</title><link>https://example.com/c</link>
+  <description>Old</description>
+</item>
+</channel></rss>`
+
+func TestUpdateNewsEnrichesItems(t *testing.T) {
+	var srvURL string
+	pages := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss":
+			w.Write([]byte(strings.Replace(enrichedRSS, "LINK_B", srvURL+"/article-b", 1)))
+		case "/article-b":
+			pages++
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><meta property="og:image" content="/og/b.png"></head><body>...</body></html>`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM news_sources\\s+WHERE enabled").WillReturnRows(sqlmock.NewRows(newsSourceRowColumns).
+		AddRow(1, srv.URL+"/rss", "Example", "ru", "ecology", 30, true, now, now, now, nil, "", "", nil, 0, "", ""))
+
+	// og:image ищется только для новых ссылок без картинки
+	mock.ExpectQuery("SELECT link FROM news WHERE link = ANY").
+		WithArgs(pq.Array([]string{srv.URL + "/article-b", "https://example.com/c"})).
+		WillReturnRows(sqlmock.NewRows([]string{"link"}).AddRow("https://example.com/c"))
+
+	// рубрика источника "ecology" — не категория эко-теста, классификатор её сбрасывает
+	mock.ExpectPrepare("INSERT INTO news")
+	mock.ExpectExec("INSERT INTO news").
+		WithArgs("With thumbnail", "https://example.com/a", sqlmock.AnyArg(), "Example",
+			"<p>Some <em>text</em></p>", int64(1), "Some text",
+			"https://example.com/thumbs/a.jpg", "Jane Doe", "en", "", sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO news").
+		WithArgs("Needs og:image", srv.URL+"/article-b", sqlmock.AnyArg(), "Example",
+			"Plain", int64(1), "Plain", srv.URL+"/og/b.png", "", "en", "", sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO news").
+		WithArgs("Already stored", "https://example.com/c", sqlmock.AnyArg(), "Example",
+			"Old", int64(1), "Old", "", "", "en", "", sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE news_sources SET").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	service := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	service.Fetcher.PageClient = srv.Client() // тестовый сервер на loopback
+	if err := service.UpdateNews(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if pages != 1 {
+		t.Errorf("expected exactly one article page fetch, got %d", pages)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dl/handlers"
+	"dl/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/mmcdole/gofeed"
+)
+
+var (
+	feedNewer = time.Date(2026, 5, 3, 12, 0, 0, 0, time.UTC)
+	feedOlder = feedNewer.Add(-3 * time.Hour)
+)
+
+func feedRows() *sqlmock.Rows {
+	return sqlmock.NewRows(newsRowColumns).
+		AddRow(2, "Rivers & lakes <report>", "https://example.com/rivers", feedNewer, "Eco Daily", "<p>Water <b>matters</b></p>", 1,
+			"Water matters", "https://example.com/rivers.jpg", "Anna", "en", "water", pq.StringArray{"rivers", "pollution"}, models.CategoryAuto, 0, "[]", false, 4).
+		AddRow(1, "Solar farms", "https://example.com/solar", feedOlder, "Green Wire", "", 2,
+			"Panels everywhere", "", "", "en", "water", pq.StringArray{}, models.CategoryAuto, 0, "[]", false, 0)
+}
+
+func expectFeedQuery(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`WHERE n.category = \$1 AND n.language = \$2 AND n.duplicate_of IS NULL`).
+		WithArgs("water", "en", 51).
+		WillReturnRows(feedRows())
+}
+
+func getFeed(h http.HandlerFunc, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func TestNewsFeedsRoundTripThroughGofeed(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+	h.PublicURL = "https://ecofoot.example/"
+
+	cases := []struct {
+		name        string
+		handler     func(*handlers.NewsHandler) http.HandlerFunc
+		path        string
+		feedType    string
+		contentType string
+	}{
+		{"rss", func(h *handlers.NewsHandler) http.HandlerFunc { return h.RSS }, "/news/feed.xml", "rss", "application/rss+xml"},
+		{"atom", func(h *handlers.NewsHandler) http.HandlerFunc { return h.Atom }, "/news/atom.xml", "atom", "application/atom+xml"},
+		{"json", func(h *handlers.NewsHandler) http.HandlerFunc { return h.JSONFeed }, "/news/feed.json", "json", "application/feed+json"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expectFeedQuery(mock)
+			rr := getFeed(c.handler(h), c.path+"?category=water&language=en", nil)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, c.contentType) {
+				t.Errorf("unexpected content type %q", ct)
+			}
+
+			feed, err := gofeed.NewParser().ParseString(rr.Body.String())
+			if err != nil {
+				t.Fatalf("gofeed cannot parse our %s: %v\n%s", c.name, err, rr.Body.String())
+			}
+			if feed.FeedType != c.feedType {
+				t.Errorf("expected feed type %s, got %s", c.feedType, feed.FeedType)
+			}
+			if feed.Title != "EcoFoot news: water" || feed.Language != "en" {
+				t.Errorf("unexpected feed title/language: %q %q", feed.Title, feed.Language)
+			}
+			if feed.FeedLink != "https://ecofoot.example"+c.path+"?category=water&language=en" {
+				t.Errorf("unexpected self link %q", feed.FeedLink)
+			}
+			if len(feed.Items) != 2 {
+				t.Fatalf("expected 2 items, got %d", len(feed.Items))
+			}
+
+			first := feed.Items[0]
+			if first.Title != "Rivers & lakes <report>" || first.Link != "https://example.com/rivers" {
+				t.Errorf("unexpected item: %q %q", first.Title, first.Link)
+			}
+			if first.GUID != "https://ecofoot.example/news/2" {
+				t.Errorf("unexpected guid %q", first.GUID)
+			}
+			if first.PublishedParsed == nil || !first.PublishedParsed.Equal(feedNewer) {
+				t.Errorf("unexpected published date %v", first.PublishedParsed)
+			}
+			if len(first.Authors) == 0 || first.Authors[0].Name != "Anna" {
+				t.Errorf("unexpected authors %+v", first.Authors)
+			}
+			if strings.Join(first.Categories, ",") != "water,rivers,pollution" {
+				t.Errorf("unexpected categories %v", first.Categories)
+			}
+			if !strings.Contains(first.Description+first.Content, "<b>matters</b>") {
+				t.Errorf("expected HTML body, got %q / %q", first.Description, first.Content)
+			}
+			if len(feed.Items[1].Authors) == 0 || feed.Items[1].Authors[0].Name != "Green Wire" {
+				t.Errorf("expected source as author fallback, got %+v", feed.Items[1].Authors)
+			}
+		})
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsFeedConditionalRequests(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+	h.PublicURL = "https://ecofoot.example"
+
+	expectFeedQuery(mock)
+	rr := getFeed(h.RSS, "/news/feed.xml?category=water&language=en", nil)
+	etag := rr.Header().Get("ETag")
+	if etag == "" || rr.Header().Get("Cache-Control") != "public, max-age=300" {
+		t.Fatalf("missing caching headers: %v", rr.Header())
+	}
+	if lm := rr.Header().Get("Last-Modified"); lm != feedNewer.Format(http.TimeFormat) {
+		t.Errorf("expected Last-Modified of the newest item, got %q", lm)
+	}
+
+	expectFeedQuery(mock)
+	rr = getFeed(h.RSS, "/news/feed.xml?category=water&language=en", map[string]string{"If-None-Match": etag})
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("expected empty 304 for matching ETag, got %d", rr.Code)
+	}
+
+	expectFeedQuery(mock)
+	rr = getFeed(h.RSS, "/news/feed.xml?category=water&language=en", map[string]string{"If-None-Match": `"stale"`,
+		"If-Modified-Since": feedNewer.Add(time.Hour).Format(http.TimeFormat)})
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 for stale ETag even with fresh If-Modified-Since, got %d", rr.Code)
+	}
+
+	expectFeedQuery(mock)
+	rr = getFeed(h.RSS, "/news/feed.xml?category=water&language=en", map[string]string{"If-Modified-Since": feedNewer.Format(http.TimeFormat)})
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for If-Modified-Since, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsFeedWithoutPublicURLIsPrivate(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	// ссылки из Host запроса — общий кэш не должен раздавать их другим
+	expectFeedQuery(mock)
+	rr := getFeed(h.RSS, "/news/feed.xml?category=water&language=en", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "private, max-age=300" {
+		t.Errorf("expected private caching without PUBLIC_URL, got %d %v", rr.Code, rr.Header())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmptyAtomFeedHasFixedUpdated(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+	h.PublicURL = "https://ecofoot.example"
+
+	// не time.Now(): иначе тело и ETag пустой ленты меняются каждую секунду
+	mock.ExpectQuery("AND n.duplicate_of IS NULL").WillReturnRows(sqlmock.NewRows(newsRowColumns))
+	rr := getFeed(h.Atom, "/news/atom.xml?category=water&language=en", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "<updated>1970-01-01T00:00:00Z</updated>") {
+		t.Errorf("expected a fixed updated date for an empty feed:\n%s", rr.Body.String())
+	}
+	if rr.Header().Get("Last-Modified") != "" {
+		t.Errorf("expected no Last-Modified for an empty feed, got %q", rr.Header().Get("Last-Modified"))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsFeedValidatesFilters(t *testing.T) {
+	h, _, db := newNewsListHandler(t)
+	defer db.Close()
+
+	if rr := getFeed(h.Atom, "/news/atom.xml?category=nope", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown category, got %d", rr.Code)
+	}
+	if rr := getFeed(h.JSONFeed, "/news/feed.json?from=yesterday", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad date, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/news/feed.xml", nil)
+	rr := httptest.NewRecorder()
+	h.RSS(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, got %d", rr.Code)
+	}
+}
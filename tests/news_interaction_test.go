@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dl/models"
+	"dl/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+var viewerStateColumns = []string{"id", "liked", "bookmarked_at", "read"}
+
+func newsInteractionRequest(h http.HandlerFunc, method, path, id string, userID int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	if userID != 0 {
+		req = req.WithContext(utils.ContextWithUserID(req.Context(), userID))
+	}
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func TestLikeNewsReturnsUpdatedState(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	mock.ExpectExec(`INSERT INTO news_likes \(user_id, news_id\) VALUES \(\$1, \$2\) ON CONFLICT DO NOTHING`).
+		WithArgs(int64(5), int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM news n WHERE n.id = \$1`).WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(newsRowColumns).AddRow(7, "News", "https://example.com/7", time.Now(), "Example", "", 1,
+			"", "", "", "ru", "water", pq.StringArray{}, models.CategoryAuto, 0, "[]", false, 3))
+	mock.ExpectQuery(`LEFT JOIN news_bookmarks b ON b.news_id = n.id AND b.user_id = \$1`).
+		WithArgs(int64(5), pq.Array([]int64{7})).
+		WillReturnRows(sqlmock.NewRows(viewerStateColumns).AddRow(7, true, nil, false))
+
+	rr := newsInteractionRequest(h.Like, http.MethodPut, "/news/7/like", "7", 5)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var item models.NewsItem
+	json.NewDecoder(rr.Body).Decode(&item)
+	if item.LikeCount != 3 || item.Viewer == nil || !item.Viewer.Liked || item.Viewer.Bookmarked {
+		t.Errorf("unexpected item state: like_count=%d viewer=%+v", item.LikeCount, item.Viewer)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBookmarkMissingNewsIsNotFound(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	mock.ExpectExec(`INSERT INTO news_bookmarks`).WithArgs(int64(5), int64(404)).
+		WillReturnError(&pq.Error{Code: "23503"})
+
+	rr := newsInteractionRequest(h.Bookmark, http.MethodPut, "/news/404/bookmark", "404", 5)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestUnmarkReadAndAuthRequired(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	if rr := newsInteractionRequest(h.Read, http.MethodPut, "/news/7/read", "7", 0); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rr.Code)
+	}
+	if rr := newsInteractionRequest(h.Read, http.MethodPost, "/news/7/read", "7", 5); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, got %d", rr.Code)
+	}
+
+	mock.ExpectExec(`DELETE FROM news_reads WHERE user_id = \$1 AND news_id = \$2`).WithArgs(int64(5), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM news n WHERE n.id = \$1`).WithArgs(int64(7)).
+		WillReturnRows(newsRow(sqlmock.NewRows(newsRowColumns), 7, time.Now()))
+	mock.ExpectQuery(`FROM news n\s+LEFT JOIN news_bookmarks`).
+		WillReturnRows(sqlmock.NewRows(viewerStateColumns).AddRow(7, false, nil, false))
+
+	if rr := newsInteractionRequest(h.Read, http.MethodDelete, "/news/7/read", "7", 5); rr.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsListIncludesViewerStateForSignedInUser(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(`WHERE n.duplicate_of IS NULL`).
+		WillReturnRows(newsRow(newsRow(sqlmock.NewRows(newsRowColumns), 2, now), 1, now))
+	mock.ExpectQuery(`LEFT JOIN news_bookmarks`).WithArgs(int64(5), pq.Array([]int64{2, 1})).
+		WillReturnRows(sqlmock.NewRows(viewerStateColumns).AddRow(2, false, now, true).AddRow(1, false, nil, false))
+
+	rr := newsInteractionRequest(h.List, http.MethodGet, "/news", "", 5)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page models.NewsPage
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Items) != 2 || page.Items[0].Viewer == nil || !page.Items[0].Viewer.Bookmarked || !page.Items[0].Viewer.Read {
+		t.Fatalf("unexpected viewer state: %+v", page.Items)
+	}
+	if page.Items[1].Viewer.Bookmarked || page.Items[1].Viewer.Read {
+		t.Errorf("expected second item untouched, got %+v", page.Items[1].Viewer)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMyBookmarksPagination(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	b1 := time.Date(2026, 5, 3, 12, 0, 0, 0, time.UTC)
+	b2 := b1.Add(-time.Hour)
+	now := time.Now()
+
+	mock.ExpectQuery(`FROM news_bookmarks b\s+JOIN news n ON n.id = b.news_id\s+WHERE b.user_id = \$1\s+ORDER BY b.created_at DESC`).
+		WithArgs(int64(5), 2).
+		WillReturnRows(newsRow(newsRow(sqlmock.NewRows(newsRowColumns), 8, now), 3, now))
+	mock.ExpectQuery(`LEFT JOIN news_bookmarks`).
+		WillReturnRows(sqlmock.NewRows(viewerStateColumns).AddRow(8, false, b1, false).AddRow(3, false, b2, false))
+
+	rr := newsInteractionRequest(h.Bookmarks, http.MethodGet, "/news/bookmarks?limit=1", "", 5)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page models.NewsPage
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Items) != 1 || page.Items[0].ID != 8 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+
+	mock.ExpectQuery(`WHERE b.user_id = \$1 AND \(b.created_at, b.news_id\) < \(\$2, \$3\)`).
+		WithArgs(int64(5), b1, int64(8), 2).
+		WillReturnRows(newsRow(sqlmock.NewRows(newsRowColumns), 3, now))
+	mock.ExpectQuery(`LEFT JOIN news_bookmarks`).
+		WillReturnRows(sqlmock.NewRows(viewerStateColumns).AddRow(3, false, b2, false))
+
+	rr = newsInteractionRequest(h.Bookmarks, http.MethodGet, "/news/bookmarks?limit=1&cursor="+page.NextCursor, "", 5)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnreadCount(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`NOT EXISTS \(SELECT 1 FROM news_reads rd WHERE rd.news_id = n.id AND rd.user_id = \$1\)`).
+		WithArgs(int64(5), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "count"}).AddRow("water", 3).AddRow("", 2))
+
+	rr := newsInteractionRequest(h.UnreadCount, http.MethodGet, "/news/unread-count", "", 5)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var count models.NewsUnreadCount
+	json.NewDecoder(rr.Body).Decode(&count)
+	if count.Unread != 5 || count.ByCategory["water"] != 3 || len(count.ByCategory) != 1 {
+		t.Errorf("unexpected count: %+v", count)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dl/handlers"
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func newsRow(rows *sqlmock.Rows, id int64, published time.Time) *sqlmock.Rows {
+	return rows.AddRow(id, "News", "https://example.com/", published, "Example", "", 1,
+		"", "", "", "ru", "water", pq.StringArray{"climate"}, models.CategoryAuto, 0, "[]", false, 0)
+}
+
+func newNewsListHandler(t *testing.T) (*handlers.NewsHandler, sqlmock.Sqlmock, *sql.DB) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handlers.NewNewsHandler(services.NewNewsService(repositories.NewNewsRepository(db), nil)), mock, db
+}
+
+func listNews(h *handlers.NewsHandler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/news?"+query, nil)
+	rr := httptest.NewRecorder()
+	h.List(rr, req)
+	return rr
+}
+
+func TestNewsListCursorPagination(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	t1 := time.Date(2026, 5, 3, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(-time.Hour)
+	t3 := t2.Add(-time.Hour)
+
+	// первая страница: запрашивается limit+1, чтобы понять, есть ли следующая
+	mock.ExpectQuery(`FROM news n\s+WHERE n.duplicate_of IS NULL\s+ORDER BY n.published_at DESC, n.id DESC\s+LIMIT \$1`).
+		WithArgs(3).
+		WillReturnRows(newsRow(newsRow(newsRow(sqlmock.NewRows(newsRowColumns), 30, t1), 20, t2), 10, t3))
+
+	rr := listNews(h, "limit=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page models.NewsPage
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected 2 items and a cursor, got %d items, cursor %q", len(page.Items), page.NextCursor)
+	}
+
+	// вторая страница продолжает после последней показанной новости
+	mock.ExpectQuery(`WHERE n.duplicate_of IS NULL AND \(n.published_at, n.id\) < \(\$1, \$2\)`).
+		WithArgs(t2, int64(20), 3).
+		WillReturnRows(newsRow(sqlmock.NewRows(newsRowColumns), 10, t3))
+
+	rr = listNews(h, "limit=2&cursor="+page.NextCursor)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	page = models.NewsPage{}
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Items) != 1 || page.NextCursor != "" {
+		t.Errorf("expected last page with 1 item, got %d items, cursor %q", len(page.Items), page.NextCursor)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsListFilters(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`WHERE n.source_id = \$1 AND n.category = \$2 AND n.tags @> ARRAY\[\$3\]::text\[\] AND n.language = \$4 AND n.published_at >= \$5`).
+		WithArgs(int64(4), "waste", "air-quality", "en", from, 21).
+		WillReturnRows(sqlmock.NewRows(newsRowColumns))
+
+	rr := listNews(h, "source_id=4&category=waste&tag=Air+Quality&language=EN&from=2026-01-01")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if body := rr.Body.String(); body != "{\"items\":[]}\n" {
+		t.Errorf("expected empty page, got %s", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsListDateOnlyToIncludesThatDay(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	// to=2026-01-31 — весь 31-й день: граница — начало 1 февраля
+	mock.ExpectQuery(`WHERE n.published_at >= \$1 AND n.published_at < \$2`).
+		WithArgs(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), 21).
+		WillReturnRows(sqlmock.NewRows(newsRowColumns))
+	if rr := listNews(h, "from=2026-01-31&to=2026-01-31"); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a single-day range, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// с указанным временем to остаётся как есть
+	to := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`WHERE n.published_at < \$1`).WithArgs(to, 21).
+		WillReturnRows(sqlmock.NewRows(newsRowColumns))
+	if rr := listNews(h, "to=2026-01-31T12:00:00Z"); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsSearchRankedWithOffsetCursor(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(`WHERE n.duplicate_of IS NULL AND n.search_vector @@ \(websearch_to_tsquery\('russian', \$1\) \|\| websearch_to_tsquery\('english', \$1\)\)\s+ORDER BY ts_rank\(`).
+		WithArgs("переработка пластика", 2).
+		WillReturnRows(newsRow(newsRow(sqlmock.NewRows(newsRowColumns), 5, now), 9, now))
+
+	rr := listNews(h, "q=переработка+пластика&limit=1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page models.NewsPage
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Items) != 1 || page.Items[0].ID != 5 {
+		t.Fatalf("expected the best match first, got %+v", page.Items)
+	}
+
+	mock.ExpectQuery(`LIMIT \$2 OFFSET \$3`).
+		WithArgs("переработка пластика", 2, 1).
+		WillReturnRows(newsRow(sqlmock.NewRows(newsRowColumns), 9, now))
+
+	rr = listNews(h, "q=переработка+пластика&limit=1&cursor="+page.NextCursor)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// курсор поиска не подходит для обычной ленты
+	rr = listNews(h, "limit=1&cursor="+page.NextCursor)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a search cursor on the plain feed, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewsListRejectsBadParameters(t *testing.T) {
+	h, _, db := newNewsListHandler(t)
+	defer db.Close()
+
+	for _, query := range []string{
+		"category=politics",
+		"from=yesterday",
+		"from=2026-02-01&to=2026-01-01",
+		"cursor=not-a-cursor",
+		"source_id=abc",
+	} {
+		if rr := listNews(h, query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestGetNewsItem(t *testing.T) {
+	h, mock, db := newNewsListHandler(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`FROM news n WHERE n.id = \$1`).WithArgs(int64(7)).
+		WillReturnRows(newsRow(sqlmock.NewRows(newsRowColumns), 7, time.Now()))
+	mock.ExpectQuery(`FROM news n WHERE n.id = \$1`).WithArgs(int64(8)).
+		WillReturnError(sql.ErrNoRows)
+
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{{"7", http.StatusOK}, {"8", http.StatusNotFound}, {"x", http.StatusBadRequest}} {
+		id, want := tc.id, tc.want
+		req := httptest.NewRequest(http.MethodGet, "/news/"+id, nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		h.Get(rr, req)
+		if rr.Code != want {
+			t.Errorf("news %s: expected %d, got %d", id, want, rr.Code)
+		}
+	}
+}
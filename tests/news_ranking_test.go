@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"dl/handlers"
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func rankedNewsRow(rows *sqlmock.Rows, id int64, category, lang string, tags []string, published time.Time) *sqlmock.Rows {
+	return rows.AddRow(id, "News", "https://example.com/", published, "Example", "", 1,
+		"", "", "", lang, category, pq.StringArray(tags), models.CategoryAuto, 0, "[]", false, 0)
+}
+
+func newForYouHandler(t *testing.T) (*handlers.NewsHandler, sqlmock.Sqlmock, *sql.DB) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	news := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	h := handlers.NewNewsHandler(news)
+	h.Ranking = services.NewNewsRankingService(news, repositories.NewEcoRepository(db))
+	return h, mock, db
+}
+
+func forYou(h *handlers.NewsHandler, query, acceptLanguage string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/news/for-you?"+query, nil)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	req = req.WithContext(utils.ContextWithUserID(req.Context(), 5))
+	rr := httptest.NewRecorder()
+	h.ForYou(rr, req)
+	return rr
+}
+
+func reasonKinds(item models.NewsItem) map[string]models.NewsRankReason {
+	kinds := map[string]models.NewsRankReason{}
+	if item.Explanation != nil {
+		for _, r := range item.Explanation.Reasons {
+			kinds[r.Kind] = r
+		}
+	}
+	return kinds
+}
+
+func TestForYouRanksByFootprintTagsAndLanguage(t *testing.T) {
+	h, mock, db := newForYouHandler(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM eco_results").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"total_score", "category", "description", "category_scores"}).
+			AddRow(70, "Eco Impactful", "", []byte(`{"water": 0.8, "food": 0.2, "energy": 0.4}`)))
+	mock.ExpectQuery("FROM news_likes l\\s+JOIN news n").WithArgs(int64(5), sqlmock.AnyArg(), 20).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "count"}).AddRow("plastic", 4))
+	mock.ExpectQuery("SELECT n.language").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"language"}).AddRow("ru"))
+
+	rows := sqlmock.NewRows(newsRowColumns)
+	rankedNewsRow(rows, 1, "energy", "en", nil, now.Add(-time.Hour))                 // свежая, но не в приоритете
+	rankedNewsRow(rows, 2, "water", "ru", nil, now.Add(-2*time.Hour))                // слабейшая категория, свой язык
+	rankedNewsRow(rows, 3, "food", "ru", []string{"plastic"}, now.Add(-3*time.Hour)) // лайкнутый тег
+	rankedNewsRow(rows, 4, "water", "ru", nil, now.Add(-96*time.Hour))               // старая
+	mock.ExpectQuery("WHERE n.published_at >= \\$1 AND n.duplicate_of IS NULL").WithArgs(sqlmock.AnyArg(), 301).
+		WillReturnRows(rows)
+	mock.ExpectQuery("LEFT JOIN news_bookmarks").
+		WillReturnRows(sqlmock.NewRows(viewerStateColumns).
+			AddRow(1, false, nil, false).AddRow(2, false, nil, false).AddRow(3, false, nil, true).AddRow(4, false, nil, false))
+
+	rr := forYou(h, "limit=3", "en-US")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page models.NewsPage
+	json.NewDecoder(rr.Body).Decode(&page)
+
+	if len(page.Items) != 3 || page.NextCursor == "" {
+		t.Fatalf("expected 3 items and a cursor, got %d items, cursor %q", len(page.Items), page.NextCursor)
+	}
+	if page.Items[0].ID != 2 {
+		t.Errorf("expected the weakest-category article first, got %d", page.Items[0].ID)
+	}
+
+	top := reasonKinds(page.Items[0])
+	if r, ok := top["footprint_category"]; !ok || r.Value != "water" || r.Weight != 1.5 {
+		t.Errorf("expected full footprint boost for water, got %+v", top)
+	}
+	if r, ok := top["language"]; !ok || r.Value != "ru" {
+		t.Errorf("expected language reason from activity, got %+v", top)
+	}
+	if _, ok := top["freshness"]; !ok {
+		t.Errorf("expected freshness reason, got %+v", top)
+	}
+
+	for _, item := range page.Items {
+		if item.ID == 3 {
+			kinds := reasonKinds(item)
+			if _, ok := kinds["liked_tag"]; !ok {
+				t.Errorf("expected liked_tag reason, got %+v", kinds)
+			}
+			if _, ok := kinds["already_read"]; !ok {
+				t.Errorf("expected already_read reason, got %+v", kinds)
+			}
+		}
+		if item.ID == 4 {
+			t.Errorf("expected the 4-day-old article on the next page")
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestForYouWithoutHistoryUsesHeaderLanguage(t *testing.T) {
+	h, mock, db := newForYouHandler(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM eco_results").WithArgs(int64(5)).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("FROM news_likes l\\s+JOIN news n").WillReturnRows(sqlmock.NewRows([]string{"tag", "count"}))
+	mock.ExpectQuery("SELECT n.language").WillReturnError(sql.ErrNoRows)
+
+	rows := sqlmock.NewRows(newsRowColumns)
+	rankedNewsRow(rows, 1, "water", "ru", nil, now.Add(-time.Hour))
+	rankedNewsRow(rows, 2, "water", "en", nil, now.Add(-2*time.Hour))
+	mock.ExpectQuery("AND n.duplicate_of IS NULL").WillReturnRows(rows)
+	mock.ExpectQuery("LEFT JOIN news_bookmarks").
+		WillReturnRows(sqlmock.NewRows(viewerStateColumns).AddRow(1, false, nil, false).AddRow(2, false, nil, false))
+
+	rr := forYou(h, "", "en-GB,en;q=0.9")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page models.NewsPage
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Items) != 2 || page.Items[0].ID != 2 {
+		t.Fatalf("expected the English article first, got %+v", page.Items)
+	}
+	if r := reasonKinds(page.Items[0])["language"]; r.Detail != "written in your browser language" {
+		t.Errorf("expected header language reason, got %+v", r)
+	}
+	if _, ok := reasonKinds(page.Items[0])["footprint_category"]; ok {
+		t.Error("expected no footprint boost without an eco result")
+	}
+}
+
+func TestEcoCategoryScoresFallBackToAnswers(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	// результат сохранён до появления разбивки по категориям
+	mock.ExpectQuery("FROM eco_results").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"total_score", "category", "description", "category_scores"}).
+			AddRow(40, "Eco Aware", "", nil))
+	mock.ExpectQuery("SELECT DISTINCT ON \\(question_id\\) question_id, value").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "share"}).AddRow("water", 0.6).AddRow("food", nil))
+
+	scores, err := repositories.NewEcoRepository(db).GetCategoryScores(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 1 || scores["water"] != 0.6 {
+		t.Errorf("unexpected scores: %v", scores)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
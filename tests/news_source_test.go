@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"dl/handlers"
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Eco feed</title>
+<item><title>Trees planted</title><link>https://example.com/a</link><pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate><description>A</description></item>
+<item><title>River cleaned</title><link>https://example.com/b</link><description>B</description></item>
+</channel></rss>`
+
+var newsSourceRowColumns = []string{"id", "url", "name", "language", "category", "poll_interval_minutes", "enabled",
+	"created_at", "updated_at", "next_fetch_at", "last_fetched_at", "last_status", "last_error", "last_item_count", "consecutive_failures",
+	"etag", "last_modified"}
+
+func newsSourceRow(rows *sqlmock.Rows, id int64, url string, interval int) *sqlmock.Rows {
+	now := time.Now()
+	return rows.AddRow(id, url, "Source", "ru", "", interval, true, now, now, now, nil, "", "", nil, 0, "", "")
+}
+
+func TestUpdateNewsPollsDueSources(t *testing.T) {
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(testRSS))
+	}))
+	defer feed.Close()
+
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	rows := sqlmock.NewRows(newsSourceRowColumns)
+	newsSourceRow(rows, 1, feed.URL+"/rss", 60)
+	newsSourceRow(rows, 2, feed.URL+"/broken", 15)
+	mock.ExpectQuery("FROM news_sources\\s+WHERE enabled AND next_fetch_at <= \\$1").WillReturnRows(rows)
+
+	// первый источник: две новости, статус ok (ссылки уже известны — страницы статей не качаются)
+	mock.ExpectQuery("SELECT link FROM news WHERE link = ANY").
+		WillReturnRows(sqlmock.NewRows([]string{"link"}).AddRow("https://example.com/a").AddRow("https://example.com/b"))
+	mock.ExpectPrepare("INSERT INTO news")
+	mock.ExpectExec("INSERT INTO news").
+		WithArgs("Trees planted", "https://example.com/a", sqlmock.AnyArg(), "Source", "A", int64(1), "A", "", "", "ru", "", sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO news").
+		WithArgs("River cleaned", "https://example.com/b", sqlmock.AnyArg(), "Source", "B", int64(1), "B", "", "", "ru", "", sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("UPDATE news_sources SET").
+		WithArgs(int64(1), sqlmock.AnyArg(), models.NewsSourceOK, "", 2, sqlmock.AnyArg(), "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// второй источник падает — ошибка попадает в его статус, а не в результат
+	mock.ExpectExec("UPDATE news_sources SET").
+		WithArgs(int64(2), sqlmock.AnyArg(), models.NewsSourceError, sqlmock.AnyArg(), 0, sqlmock.AnyArg(), "", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	service := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	service.Concurrency = 1 // порядок запросов к моку детерминирован
+	if err := service.UpdateNews(context.Background()); err != nil {
+		t.Fatalf("UpdateNews: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func newNewsSourceHandler() (*handlers.NewsSourceHandler, sqlmock.Sqlmock, func()) {
+	db, mock, _ := sqlmock.New()
+	service := services.NewNewsService(repositories.NewNewsRepository(db), nil)
+	return &handlers.NewsSourceHandler{Service: service}, mock, func() { db.Close() }
+}
+
+func adminRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	return req.WithContext(utils.ContextWithUserID(req.Context(), 1))
+}
+
+func TestCreateNewsSource(t *testing.T) {
+	h, mock, done := newNewsSourceHandler()
+	defer done()
+
+	rr := httptest.NewRecorder()
+	h.Sources(rr, adminRequest(http.MethodPost, "/admin/news-sources", `{"url": "ftp://x", "poll_interval_minutes": 1}`))
+	body := rr.Body.String()
+	if rr.Code != http.StatusBadRequest || !strings.Contains(body, utils.FieldInvalidURL) ||
+		!strings.Contains(body, `"name"`) || !strings.Contains(body, utils.FieldOutOfRange) {
+		t.Errorf("validation: %d %s", rr.Code, body)
+	}
+
+	mock.ExpectQuery("INSERT INTO news_sources").
+		WithArgs("https://example.com/feed", "Example", "en", "", 30).
+		WillReturnRows(newsSourceRow(sqlmock.NewRows(newsSourceRowColumns), 3, "https://example.com/feed", 30))
+
+	rr = httptest.NewRecorder()
+	h.Sources(rr, adminRequest(http.MethodPost, "/admin/news-sources", `{"url": " https://example.com/feed ", "name": "Example", "language": "EN"}`))
+	if rr.Code != http.StatusCreated {
+		t.Errorf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDisableAndTestNewsSource(t *testing.T) {
+	h, mock, done := newNewsSourceHandler()
+	defer done()
+
+	disabled := false
+	mock.ExpectQuery("UPDATE news_sources SET").
+		WithArgs(int64(3), nil, nil, nil, nil, &disabled).
+		WillReturnRows(newsSourceRow(sqlmock.NewRows(newsSourceRowColumns), 3, "https://example.com/feed", 30))
+
+	req := adminRequest(http.MethodPatch, "/admin/news-sources/3", `{"enabled": false}`)
+	req.SetPathValue("id", "3")
+	rr := httptest.NewRecorder()
+	h.Update(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("disable: %d %s", rr.Code, rr.Body.String())
+	}
+
+	feed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testRSS))
+	}))
+	defer feed.Close()
+
+	mock.ExpectQuery("FROM news_sources WHERE id = \\$1").WithArgs(int64(3)).
+		WillReturnRows(newsSourceRow(sqlmock.NewRows(newsSourceRowColumns), 3, feed.URL, 30))
+
+	req = adminRequest(http.MethodPost, "/admin/news-sources/3/test", "")
+	req.SetPathValue("id", "3")
+	rr = httptest.NewRecorder()
+	h.Test(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"item_count":2`) || !strings.Contains(rr.Body.String(), `"feed_title":"Eco feed"`) {
+		t.Errorf("test: %d %s", rr.Code, rr.Body.String())
+	}
+
+	mock.ExpectQuery("FROM news_sources WHERE id = \\$1").WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows(newsSourceRowColumns))
+	req = adminRequest(http.MethodPost, "/admin/news-sources/9/test", "")
+	req.SetPathValue("id", "9")
+	rr = httptest.NewRecorder()
+	h.Test(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("missing source: %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,375 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"dl/handlers"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCProvider — минимальный OIDC-провайдер: discovery, JWKS, token endpoint
+type mockOIDCProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	audience string
+
+	mu    sync.Mutex
+	codes map[string]mockAuthRequest
+}
+
+type mockAuthRequest struct {
+	challenge string
+	nonce     string
+}
+
+func newMockOIDCProvider(t *testing.T, clientID string) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockOIDCProvider{key: key, clientID: clientID, audience: clientID, codes: map[string]mockAuthRequest{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(utils.JWKSet{Keys: []utils.JWK{{
+			Kty: "RSA",
+			Kid: "test-key",
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		m.mu.Lock()
+		req, ok := m.codes[r.Form.Get("code")]
+		delete(m.codes, r.Form.Get("code"))
+		m.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "opaque",
+			"id_token":     m.signIDToken(t, req.nonce),
+		})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize имитирует вход пользователя у провайдера и выдаёт code
+func (m *mockOIDCProvider) authorize(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != m.clientID {
+		t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+
+	code := utils.RandomURLToken(16)
+	m.mu.Lock()
+	m.codes[code] = mockAuthRequest{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	m.mu.Unlock()
+	return code
+}
+
+func (m *mockOIDCProvider) signIDToken(t *testing.T, nonce string) string {
+	claims := jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "external-123",
+		"aud":            m.audience,
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "social@example.com",
+		"email_verified": "true",
+		"given_name":     "Social",
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	mock := newMockOIDCProvider(t, "client-1")
+	provider := utils.NewOIDCProvider("mock", mock.server.URL, "client-1", "secret", "http://localhost/callback")
+	ctx := context.Background()
+
+	verifier, challenge := utils.GeneratePKCE()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	code := mock.authorize(t, authURL)
+
+	rawIDToken, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != "external-123" || claims.Email != "social@example.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+
+	if _, err := provider.VerifyIDToken(ctx, rawIDToken, "other-nonce"); err == nil {
+		t.Error("expected nonce mismatch error")
+	}
+}
+
+func TestOIDCRejectsWrongVerifierAndAudience(t *testing.T) {
+	mock := newMockOIDCProvider(t, "client-1")
+	provider := utils.NewOIDCProvider("mock", mock.server.URL, "client-1", "", "http://localhost/callback")
+	ctx := context.Background()
+
+	_, challenge := utils.GeneratePKCE()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := mock.authorize(t, authURL)
+
+	if _, err := provider.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Error("expected PKCE verification to fail")
+	}
+
+	mock.audience = "another-client"
+	if _, err := provider.VerifyIDToken(ctx, mock.signIDToken(t, "nonce-1"), "nonce-1"); err == nil {
+		t.Error("expected audience validation to fail")
+	}
+}
+
+func TestGenerateUsernamePassesValidation(t *testing.T) {
+	for _, hint := range []string{"john.doe@gmail.com", "Иван", "42", "a", "very-long-name-from-some-provider"} {
+		name := utils.GenerateUsername(hint)
+		if err := utils.ValidateUsername(name); err != nil {
+			t.Errorf("GenerateUsername(%q) = %q: %v", hint, name, err)
+		}
+	}
+}
+
+// oidcCallback — сервис с мок-провайдером и выданный провайдером code для state-1
+func oidcCallback(t *testing.T) (*services.OIDCService, sqlmock.Sqlmock, string) {
+	t.Helper()
+	setupTestKeys(t)
+
+	mock := newMockOIDCProvider(t, "client-1")
+	provider := utils.NewOIDCProvider("mock", mock.server.URL, "client-1", "secret", "http://localhost/callback")
+
+	verifier, challenge := utils.GeneratePKCE()
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := mock.authorize(t, authURL)
+
+	db, sm, _ := sqlmock.New()
+	t.Cleanup(func() { db.Close() })
+	sm.ExpectQuery("DELETE FROM oidc_states WHERE state = \\$1").WithArgs("state-1").
+		WillReturnRows(sqlmock.NewRows([]string{"provider", "nonce", "code_verifier", "expires_at"}).
+			AddRow("mock", "nonce-1", verifier, time.Now().Add(time.Minute)))
+
+	service := services.NewOIDCService(repositories.NewUserRepository(db), map[string]*utils.OIDCProvider{"mock": provider}, nil)
+	return service, sm, code
+}
+
+func TestOIDCFinishLoginResolvesUser(t *testing.T) {
+	cases := []struct {
+		name   string
+		expect func(sm sqlmock.Sqlmock)
+		err    error
+	}{
+		{
+			name: "identity exists",
+			expect: func(sm sqlmock.Sqlmock) {
+				sm.ExpectQuery("FROM user_identities").WithArgs("mock", "external-123").
+					WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(42))
+				sm.ExpectExec("UPDATE user_identities SET last_login_at").WillReturnResult(sqlmock.NewResult(0, 1))
+				sm.ExpectExec("UPDATE users SET deletion_due_at = NULL").WithArgs(int64(42)).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name: "link by verified email",
+			expect: func(sm sqlmock.Sqlmock) {
+				sm.ExpectQuery("FROM user_identities").WillReturnError(sql.ErrNoRows)
+				sm.ExpectQuery("FROM users WHERE email = \\$1").WithArgs("social@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "is_verified"}).AddRow(7, []byte("hash"), true))
+				sm.ExpectExec("INSERT INTO user_identities").WithArgs(int64(7), "mock", "external-123", "social@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sm.ExpectExec("UPDATE users SET deletion_due_at = NULL").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			// аккаунт мог зарегистрировать кто угодно — не привязываем
+			name: "unverified local account",
+			expect: func(sm sqlmock.Sqlmock) {
+				sm.ExpectQuery("FROM user_identities").WillReturnError(sql.ErrNoRows)
+				sm.ExpectQuery("FROM users WHERE email = \\$1").WithArgs("social@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "password_hash", "is_verified"}).AddRow(7, []byte("hash"), false))
+			},
+			err: services.ErrOIDCUnverifiedAccount,
+		},
+		{
+			name: "create user",
+			expect: func(sm sqlmock.Sqlmock) {
+				sm.ExpectQuery("FROM user_identities").WillReturnError(sql.ErrNoRows)
+				sm.ExpectQuery("FROM users WHERE email = \\$1").WillReturnError(sql.ErrNoRows)
+				// первое сгенерированное имя занято (похожее или удерживается редиректом)
+				sm.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+				sm.ExpectQuery("SELECT EXISTS").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+				sm.ExpectQuery("INSERT INTO users").WithArgs(sqlmock.AnyArg(), "social@example.com", "Social", "").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+				sm.ExpectExec("INSERT INTO user_identities").WithArgs(int64(9), "mock", "external-123", "social@example.com").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				sm.ExpectExec("UPDATE users SET deletion_due_at = NULL").WithArgs(int64(9)).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			service, sm, code := oidcCallback(t)
+			c.expect(sm)
+
+			access, _, err := service.FinishLogin(context.Background(), "mock", "state-1", code, utils.RequestMeta{})
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expected %v, got %v", c.err, err)
+				}
+			} else if err != nil || access == "" {
+				t.Fatalf("expected tokens, got %v", err)
+			}
+			if err := sm.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginSetsStateCookie(t *testing.T) {
+	mock := newMockOIDCProvider(t, "client-1")
+	provider := utils.NewOIDCProvider("mock", mock.server.URL, "client-1", "secret", "http://localhost/callback")
+
+	db, sm, _ := sqlmock.New()
+	defer db.Close()
+	sm.ExpectExec("INSERT INTO oidc_states").WillReturnResult(sqlmock.NewResult(0, 1))
+	sm.ExpectExec("DELETE FROM oidc_states WHERE expires_at").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	handler := &handlers.OIDCHandler{Service: services.NewOIDCService(repositories.NewUserRepository(db), map[string]*utils.OIDCProvider{"mock": provider}, nil)}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/login", nil)
+	req.SetPathValue("provider", "mock")
+	rr := httptest.NewRecorder()
+	handler.Login(rr, req)
+
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected 302, got %d: %s", rr.Code, rr.Body.String())
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "oidc_state" {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected HttpOnly SameSite=Lax state cookie, got %+v", cookie)
+	}
+	if cookie.Value != location.Query().Get("state") {
+		t.Errorf("cookie %q does not match state %q", cookie.Value, location.Query().Get("state"))
+	}
+}
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	callback := func(handler *handlers.OIDCHandler, code string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/oidc/mock/callback?state=state-1&code="+code, nil)
+		req.SetPathValue("provider", "mock")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		handler.Callback(rr, req)
+		return rr
+	}
+
+	// state из чужого браузера (login CSRF) — state в БД не трогаем
+	for _, cookie := range []*http.Cookie{nil, {Name: "oidc_state", Value: "state-of-attacker"}} {
+		db, sm, _ := sqlmock.New()
+		handler := &handlers.OIDCHandler{Service: services.NewOIDCService(repositories.NewUserRepository(db), nil, nil)}
+
+		if rr := callback(handler, "code", cookie); rr.Code != http.StatusUnauthorized {
+			t.Errorf("cookie %v: expected 401, got %d", cookie, rr.Code)
+		}
+		if err := sm.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	}
+
+	service, sm, code := oidcCallback(t)
+	sm.ExpectQuery("FROM user_identities").WithArgs("mock", "external-123").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(42))
+	sm.ExpectExec("UPDATE user_identities SET last_login_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	sm.ExpectExec("UPDATE users SET deletion_due_at = NULL").WithArgs(int64(42)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	rr := callback(&handlers.OIDCHandler{Service: service}, code, &http.Cookie{Name: "oidc_state", Value: "state-1"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := sm.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"dl/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// лёгкие параметры, чтобы тесты не тормозили
+var testArgon2Params = utils.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2HashAndVerify(t *testing.T) {
+	h := utils.NewArgon2Hasher(testArgon2Params)
+
+	encoded, err := h.Hash("StrongPass123!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected PHC string: %s", encoded)
+	}
+
+	ok, rehash, err := h.Verify("StrongPass123!", encoded)
+	if err != nil || !ok || rehash {
+		t.Errorf("expected valid hash without rehash, got ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+
+	ok, _, err = h.Verify("WrongPass123!", encoded)
+	if err != nil || ok {
+		t.Errorf("wrong password must not verify, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestArgon2RehashOnParamChange(t *testing.T) {
+	old := utils.NewArgon2Hasher(testArgon2Params)
+	encoded, _ := old.Hash("StrongPass123!")
+
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	h := utils.NewArgon2Hasher(stronger)
+
+	ok, rehash, err := h.Verify("StrongPass123!", encoded)
+	if err != nil || !ok || !rehash {
+		t.Errorf("expected rehash for outdated params, got ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+}
+
+func TestBcryptHashesStillVerify(t *testing.T) {
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("StrongPass123!"), bcrypt.MinCost)
+	h := utils.NewArgon2Hasher(testArgon2Params)
+
+	ok, rehash, err := h.Verify("StrongPass123!", string(legacy))
+	if err != nil || !ok || !rehash {
+		t.Errorf("bcrypt hash should verify and require rehash, got ok=%v rehash=%v err=%v", ok, rehash, err)
+	}
+
+	if ok, _, _ := h.Verify("", ""); ok {
+		t.Error("empty hash (passwordless account) must never verify")
+	}
+}
+
+func TestArgon2ParamsValidate(t *testing.T) {
+	if err := utils.DefaultArgon2Params.Validate(); err != nil {
+		t.Fatalf("default params rejected: %v", err)
+	}
+
+	for name, mutate := range map[string]func(p *utils.Argon2Params){
+		"zero memory":      func(p *utils.Argon2Params) { p.Memory = 0 },
+		"zero iterations":  func(p *utils.Argon2Params) { p.Iterations = 0 },
+		"zero parallelism": func(p *utils.Argon2Params) { p.Parallelism = 0 },
+		"wrapped negative": func(p *utils.Argon2Params) { p.Memory = uint32(0xFFFFFFFF) },
+		"short key":        func(p *utils.Argon2Params) { p.KeyLength = 4 },
+	} {
+		p := testArgon2Params
+		mutate(&p)
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+		// вместо паники в argon2.IDKey — ошибка
+		if _, err := utils.NewArgon2Hasher(p).Hash("password"); err == nil {
+			t.Errorf("%s: expected Hash to fail", name)
+		}
+	}
+}
+
+func TestArgon2VerifyRejectsZeroCostHash(t *testing.T) {
+	h := utils.NewArgon2Hasher(testArgon2Params)
+	encoded, err := h.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken := strings.Replace(encoded, "t=1,p=1", "t=0,p=1", 1)
+	if _, _, err := h.Verify("password", broken); !errors.Is(err, utils.ErrUnknownHashFormat) {
+		t.Errorf("expected ErrUnknownHashFormat, got %v", err)
+	}
+}
@@ -1,7 +1,12 @@
 package tests
 
 import (
+	"crypto/sha1"
 	"dl/utils"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -27,3 +32,67 @@ func TestValidatePassword(t *testing.T) {
 		})
 	}
 }
+
+func violationCodes(violations []utils.PasswordViolation) map[string]bool {
+	codes := make(map[string]bool)
+	for _, v := range violations {
+		codes[v.Code] = true
+	}
+	return codes
+}
+
+func TestPasswordPolicyViolations(t *testing.T) {
+	policy := utils.NewPasswordPolicy("")
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		email    string
+		wantCode string
+	}{
+		{"common password", "Password123!", "", "", utils.PasswordCommon},
+		{"common base with symbols", "Qwerty2024#", "", "", utils.PasswordCommon},
+		{"contains username", "Dana_Strong1!", "dana", "", utils.PasswordSimilarToUsername},
+		{"reversed username", "Anad_Strong1!", "dana", "", utils.PasswordSimilarToUsername},
+		{"contains email", "Kalykova#2001", "", "kalykova@example.com", utils.PasswordSimilarToEmail},
+		{"all classes missing", "        ", "", "", utils.PasswordMissingUppercase},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := violationCodes(policy.Check(tt.password, tt.username, tt.email))
+			if !codes[tt.wantCode] {
+				t.Errorf("expected %s, got %v", tt.wantCode, codes)
+			}
+		})
+	}
+
+	if v := policy.Check("StrongPass123!", "dana", "dana@example.com"); len(v) != 0 {
+		t.Errorf("expected no violations, got %+v", v)
+	}
+}
+
+func TestPasswordPolicyBreachDataset(t *testing.T) {
+	dir := t.TempDir()
+
+	// range-файл называется по первым 5 символам SHA-1
+	sum := sha1.Sum([]byte("Tr0ub4dour&3"))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	content := "0000000000000000000000000000000000A:0\n" + digest[5:] + ":42\n"
+	if err := os.WriteFile(filepath.Join(dir, digest[:5]+".txt"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := utils.NewPasswordPolicy(dir)
+
+	violations := policy.Check("Tr0ub4dour&3", "", "")
+	codes := violationCodes(violations)
+	if !codes[utils.PasswordBreached] {
+		t.Fatalf("expected breached password, got %v", codes)
+	}
+
+	if codes := violationCodes(policy.Check("Un1que-Enough!", "", "")); codes[utils.PasswordBreached] {
+		t.Error("password not in dataset must not be reported as breached")
+	}
+}
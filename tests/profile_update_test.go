@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"dl/handlers"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func patchProfile(t *testing.T, h *handlers.ProfileHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/update-profile", strings.NewReader(body))
+	req = req.WithContext(utils.ContextWithUserID(req.Context(), 5))
+	rr := httptest.NewRecorder()
+	h.UpdateProfile(rr, req)
+	return rr
+}
+
+func TestPatchProfileUpdatesOnlyProvidedFields(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	h := &handlers.ProfileHandler{Service: services.NewProfileService(repositories.NewProfileRepository(db), nil, nil, 0, nil)}
+
+	// bio меняется, first_name очищается, остальные поля не трогаются
+	mock.ExpectExec(`UPDATE users SET bio = \$1, first_name = \$2, updated_at = \$3 WHERE id = \$4`).
+		WithArgs("Nature lover", nil, sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	cols := []string{"id", "username", "first_name", "last_name", "gender", "birth_date", "bio", "profile_picture", "rating", "email", "updated_at"}
+	mock.ExpectQuery("FROM users WHERE id").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(5, "dana", nil, "K", "female", "2001-05-17", "Nature lover", nil, 10, "d@x.io", time.Now()))
+
+	rr := patchProfile(t, h, `{"bio": "  Nature lover ", "first_name": null}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["bio"] != "Nature lover" || resp["birth_date"] != "2001-05-17" {
+		t.Errorf("unexpected profile: %v", resp)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatchProfileReportsFieldErrors(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	h := &handlers.ProfileHandler{Service: services.NewProfileService(repositories.NewProfileRepository(db), nil, nil, 0, nil)}
+
+	rr := patchProfile(t, h, `{"first_name": "R2-D2", "gender": "robot", "birth_date": "2001-02-30", "bio": "ok"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+
+	var resp struct {
+		Fields map[string]utils.FieldError `json:"fields"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+
+	want := map[string]string{
+		"first_name": utils.FieldInvalidChars,
+		"gender":     utils.FieldInvalidChoice,
+		"birth_date": utils.FieldInvalidDate,
+	}
+	if len(resp.Fields) != len(want) {
+		t.Errorf("unexpected fields: %v", resp.Fields)
+	}
+	for field, code := range want {
+		if resp.Fields[field].Code != code {
+			t.Errorf("%s: got %q, want %q", field, resp.Fields[field].Code, code)
+		}
+	}
+
+	future := time.Now().AddDate(1, 0, 0).Format("2006-01-02")
+	rr = patchProfile(t, h, `{"birth_date": "`+future+`"}`)
+	if !strings.Contains(rr.Body.String(), utils.FieldDateInFuture) {
+		t.Errorf("future date accepted: %s", rr.Body.String())
+	}
+
+	for _, body := range []string{`{}`, `{"nickname": "x"}`, `{"bio": 5}`} {
+		if rr := patchProfile(t, h, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+
+	// ни одного запроса к БД при ошибках валидации
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatchProfileNormalizesBioNewlines(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	h := &handlers.ProfileHandler{Service: services.NewProfileService(repositories.NewProfileRepository(db), nil, nil, 0, nil)}
+
+	// textarea присылает \r\n — храним \n
+	mock.ExpectExec(`UPDATE users SET bio = \$1, updated_at = \$2 WHERE id = \$3`).
+		WithArgs("Line one\nLine two\nLine three", sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	cols := []string{"id", "username", "first_name", "last_name", "gender", "birth_date", "bio", "profile_picture", "rating", "email", "updated_at"}
+	mock.ExpectQuery("FROM users WHERE id").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(5, "dana", nil, nil, nil, nil, "Line one\nLine two\nLine three", nil, 10, "d@x.io", time.Now()))
+
+	rr := patchProfile(t, h, `{"bio": "Line one\r\nLine two\rLine three"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// остальные управляющие символы по-прежнему запрещены
+	if rr := patchProfile(t, h, `{"bio": "bell\u0007"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a control character, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatchProfileOfDeletedUser(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	h := &handlers.ProfileHandler{Service: services.NewProfileService(repositories.NewProfileRepository(db), nil, nil, 0, nil)}
+
+	mock.ExpectExec("UPDATE users SET bio").WillReturnResult(sqlmock.NewResult(0, 0))
+	if rr := patchProfile(t, h, `{"bio": "hello"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	mock.ExpectExec("UPDATE users SET bio").WillReturnError(sqlmock.ErrCancelled)
+	if rr := patchProfile(t, h, `{"bio": "hello"}`); rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for a db error, got %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPutProfileTreatsEmptyStringAsNull(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	h := &handlers.ProfileHandler{Service: services.NewProfileService(repositories.NewProfileRepository(db), nil, nil, 0, nil)}
+
+	// PATCH: пустая строка — ошибка, очищать нужно через null
+	if rr := patchProfile(t, h, `{"first_name": ""}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("PATCH: expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// PUT (старые клиенты): пустая строка очищает поле
+	mock.ExpectExec(`UPDATE users SET first_name = \$1, updated_at = \$2 WHERE id = \$3`).
+		WithArgs(nil, sqlmock.AnyArg(), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	cols := []string{"id", "username", "first_name", "last_name", "gender", "birth_date", "bio", "profile_picture", "rating", "email", "updated_at"}
+	mock.ExpectQuery("FROM users WHERE id").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(5, "dana", nil, "K", "female", "2001-05-17", nil, nil, 10, "d@x.io", time.Now()))
+
+	req := httptest.NewRequest(http.MethodPut, "/update-profile", strings.NewReader(`{"first_name": ""}`))
+	req = req.WithContext(utils.ContextWithUserID(req.Context(), 5))
+	rr := httptest.NewRecorder()
+	h.UpdateProfile(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,304 @@
+package tests
+
+import (
+	"database/sql"
+	"dl/handlers"
+	"dl/models"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newPublicProfileHandler(t *testing.T) (*handlers.PublicProfileHandler, sqlmock.Sqlmock, func()) {
+	db, mock, _ := sqlmock.New()
+	service := services.NewPublicProfileService(
+		repositories.NewProfileRepository(db),
+		repositories.NewRatingRepository(db),
+		repositories.NewPrivacyRepository(db),
+		nil,
+	)
+	return &handlers.PublicProfileHandler{Service: service}, mock, func() { db.Close() }
+}
+
+func expectPublicUser(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FROM users WHERE username = \\$1 AND deleted_at IS NULL").WithArgs("dana").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "first_name", "last_name", "bio", "profile_picture", "rating", "level", "league"}).
+			AddRow(7, "dana", "Dana", "K", "Nature lover", "/uploads/users/a.jpg", 120, 2, "Eco Enthusiast"))
+	// about и stats скрыты, активность — для друзей (значение по умолчанию)
+	mock.ExpectQuery("FROM user_privacy WHERE user_id").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"about", "avatar", "stats", "badges", "activity", "hide"}).
+			AddRow("private", "public", "private", "public", "friends", false))
+}
+
+func getPublicProfile(h *handlers.PublicProfileHandler, viewer int64) map[string]interface{} {
+	req := httptest.NewRequest(http.MethodGet, "/users/dana", nil)
+	req.SetPathValue("username", "dana")
+	if viewer != 0 {
+		req = req.WithContext(utils.ContextWithUserID(req.Context(), viewer))
+	}
+	rr := httptest.NewRecorder()
+	h.Get(rr, req)
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	return resp
+}
+
+func TestPublicProfileRespectsPrivacy(t *testing.T) {
+	h, mock, done := newPublicProfileHandler(t)
+	defer done()
+
+	expectPublicUser(mock)
+	mock.ExpectQuery("FROM user_actions WHERE user_id").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "distinct", "days"}).AddRow(12, 3, 2))
+
+	resp := getPublicProfile(h, 0)
+
+	if resp["avatar"] != "/uploads/users/a.jpg" || resp["bio"] != nil || resp["level"] != nil || resp["recent_actions"] != nil {
+		t.Errorf("anonymous viewer sees hidden sections: %v", resp)
+	}
+	badges, _ := resp["badges"].([]interface{})
+	if len(badges) != 2 { // first_step + eco_regular
+		t.Errorf("unexpected badges: %v", resp["badges"])
+	}
+	hidden, _ := json.Marshal(resp["hidden_sections"])
+	if string(hidden) != `["about","stats","activity"]` {
+		t.Errorf("hidden sections: %s", hidden)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublicProfileFriendAndOwner(t *testing.T) {
+	h, mock, done := newPublicProfileHandler(t)
+	defer done()
+
+	actions := func() {
+		mock.ExpectQuery("ORDER BY ua.created_at DESC\\s+LIMIT \\$2").WithArgs(int64(7), 10).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "points", "created_at"}).AddRow("Recycling", 5, time.Now()))
+	}
+	stats := func() {
+		mock.ExpectQuery("FROM user_actions WHERE user_id").
+			WillReturnRows(sqlmock.NewRows([]string{"total", "distinct", "days"}).AddRow(1, 1, 1))
+	}
+
+	// друг видит активность, но не private-разделы
+	expectPublicUser(mock)
+	stats()
+	mock.ExpectQuery("FROM friendships").WithArgs(int64(3), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	actions()
+
+	resp := getPublicProfile(h, 3)
+	if resp["recent_actions"] == nil || resp["bio"] != nil {
+		t.Errorf("friend view: %v", resp)
+	}
+
+	// владелец видит всё, дружба не проверяется
+	expectPublicUser(mock)
+	stats()
+	actions()
+
+	resp = getPublicProfile(h, 7)
+	if resp["bio"] != "Nature lover" || resp["level"] == nil || resp["hidden_sections"] != nil {
+		t.Errorf("owner view: %v", resp)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdatePrivacyValidates(t *testing.T) {
+	h, mock, done := newPublicProfileHandler(t)
+	defer done()
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/update-privacy-settings", strings.NewReader(body))
+		req = req.WithContext(utils.ContextWithUserID(req.Context(), 7))
+		rr := httptest.NewRecorder()
+		h.UpdatePrivacy(rr, req)
+		return rr
+	}
+
+	mock.ExpectQuery("FROM user_privacy").WillReturnError(sqlmock.ErrCancelled)
+	if rr := send(`{"stats": "friends"}`); rr.Code != http.StatusInternalServerError {
+		t.Errorf("db error: got %d", rr.Code)
+	}
+
+	mock.ExpectQuery("FROM user_privacy").WithArgs(int64(7)).WillReturnRows(sqlmock.NewRows([]string{"a"}))
+	if rr := send(`{"stats": "everyone"}`); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), utils.FieldInvalidChoice) {
+		t.Errorf("invalid visibility: %d %s", rr.Code, rr.Body.String())
+	}
+
+	mock.ExpectQuery("FROM user_privacy").WithArgs(int64(7)).WillReturnRows(sqlmock.NewRows([]string{"a"}))
+	mock.ExpectExec("INSERT INTO user_privacy").
+		WithArgs(int64(7), "public", "public", "friends", "public", "friends", true, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if rr := send(`{"stats": "friends", "hide_from_leaderboard": true}`); rr.Code != http.StatusOK {
+		t.Errorf("valid update: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLeaderboardExcludesHiddenUsers(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	mock.ExpectQuery(`LEFT JOIN user_privacy p ON p.user_id = u.id\s+WHERE u.deleted_at IS NULL AND NOT COALESCE\(p.hide_from_leaderboard, FALSE\)`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "rating", "level", "league"}).AddRow("dana", 120, 2, "Eco Enthusiast"))
+
+	list, err := repositories.NewRatingRepository(db).GetLeaderboard(10)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("leaderboard: %v %v", list, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublicProfileErrors(t *testing.T) {
+	h, mock, done := newPublicProfileHandler(t)
+	defer done()
+
+	get := func(viewer int64) int {
+		req := httptest.NewRequest(http.MethodGet, "/users/dana", nil)
+		req.SetPathValue("username", "dana")
+		if viewer != 0 {
+			req = req.WithContext(utils.ContextWithUserID(req.Context(), viewer))
+		}
+		rr := httptest.NewRecorder()
+		h.Get(rr, req)
+		return rr.Code
+	}
+
+	// нет ни пользователя, ни редиректа со старого имени
+	mock.ExpectQuery("FROM users WHERE username = \\$1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("FROM username_history").WillReturnError(sql.ErrNoRows)
+	if code := get(0); code != http.StatusNotFound {
+		t.Errorf("unknown user: expected 404, got %d", code)
+	}
+
+	mock.ExpectQuery("FROM users WHERE username = \\$1").WillReturnError(sqlmock.ErrCancelled)
+	if code := get(0); code != http.StatusInternalServerError {
+		t.Errorf("db error: expected 500, got %d", code)
+	}
+
+	mock.ExpectQuery("FROM users WHERE username = \\$1").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("FROM username_history").WillReturnError(sqlmock.ErrCancelled)
+	if code := get(0); code != http.StatusInternalServerError {
+		t.Errorf("redirect lookup error: expected 500, got %d", code)
+	}
+
+	// не смогли проверить дружбу — не отдаём урезанный профиль как будто это приватность
+	expectPublicUser(mock)
+	mock.ExpectQuery("FROM user_actions WHERE user_id").
+		WillReturnRows(sqlmock.NewRows([]string{"total", "distinct", "days"}).AddRow(1, 1, 1))
+	mock.ExpectQuery("FROM friendships").WillReturnError(sqlmock.ErrCancelled)
+	if code := get(3); code != http.StatusInternalServerError {
+		t.Errorf("friendship check error: expected 500, got %d", code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func friendRequest(h *handlers.PublicProfileHandler, method, username string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/friends/"+username, nil)
+	req.SetPathValue("username", username)
+	req = req.WithContext(utils.ContextWithUserID(req.Context(), 3))
+	rr := httptest.NewRecorder()
+	h.Friend(rr, req)
+	return rr
+}
+
+func expectFriendLookup(mock sqlmock.Sqlmock, username string, id int64) {
+	mock.ExpectQuery("FROM users WHERE username = \\$1 AND deleted_at IS NULL").WithArgs(username).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "first_name", "last_name", "bio", "profile_picture", "rating", "level", "league"}).
+			AddRow(id, username, nil, nil, nil, nil, 0, 1, "Eco Beginner"))
+}
+
+func TestFriendRequests(t *testing.T) {
+	h, mock, done := newPublicProfileHandler(t)
+	defer done()
+
+	// новая заявка
+	expectFriendLookup(mock, "dana", 7)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM friend_requests WHERE from_user_id = \\$1 AND to_user_id = \\$2").
+		WithArgs(int64(7), int64(3)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM friendships").WithArgs(int64(3), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO friend_requests").WithArgs(int64(3), int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if rr := friendRequest(h, http.MethodPut, "dana"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"requested"`) {
+		t.Errorf("request: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// встречная заявка принимается сразу, пара хранится как (меньший id, больший id)
+	expectFriendLookup(mock, "ann", 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM friend_requests WHERE from_user_id = \\$1 AND to_user_id = \\$2").
+		WithArgs(int64(1), int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO friendships").WithArgs(int64(1), int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if rr := friendRequest(h, http.MethodPut, "ann"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"friends"`) {
+		t.Errorf("accept: %d %s", rr.Code, rr.Body.String())
+	}
+
+	expectFriendLookup(mock, "ann", 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM friendships WHERE user_id = \\$1 AND friend_id = \\$2").WithArgs(int64(1), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM friend_requests").WithArgs(int64(3), int64(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	if rr := friendRequest(h, http.MethodDelete, "ann"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"none"`) {
+		t.Errorf("remove: %d %s", rr.Code, rr.Body.String())
+	}
+
+	expectFriendLookup(mock, "me", 3)
+	if rr := friendRequest(h, http.MethodPut, "me"); rr.Code != http.StatusBadRequest {
+		t.Errorf("self request: expected 400, got %d", rr.Code)
+	}
+
+	mock.ExpectQuery("FROM users WHERE username = \\$1").WillReturnError(sql.ErrNoRows)
+	if rr := friendRequest(h, http.MethodPut, "ghost"); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown user: expected 404, got %d", rr.Code)
+	}
+
+	now := time.Now()
+	mock.ExpectQuery("FROM friendships WHERE user_id = \\$1 OR friend_id = \\$1").WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "username", "created_at"}).
+			AddRow("incoming", "bob", now).AddRow("friend", "ann", now.Add(-time.Hour)).AddRow("outgoing", "dana", now.Add(-2*time.Hour)))
+	req := httptest.NewRequest(http.MethodGet, "/friends", nil)
+	req = req.WithContext(utils.ContextWithUserID(req.Context(), 3))
+	rr := httptest.NewRecorder()
+	h.Friends(rr, req)
+
+	var list models.FriendList
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("list: %d %s", rr.Code, rr.Body.String())
+	}
+	if len(list.Friends) != 1 || list.Friends[0].Username != "ann" ||
+		len(list.Incoming) != 1 || list.Incoming[0].Username != "bob" ||
+		len(list.Outgoing) != 1 || list.Outgoing[0].Username != "dana" {
+		t.Errorf("unexpected friend list: %+v", list)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"dl/middleware"
+	"dl/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), 0)
+	policy := middleware.RateLimitPolicy{Name: "test", Limit: 3, Window: time.Minute, KeyBy: middleware.KeyByIP}
+	handler := limiter.Limit(policy, okHandler())
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, rr.Code)
+		}
+		if rr.Header().Get("RateLimit-Limit") != "3" {
+			t.Errorf("expected RateLimit-Limit 3, got %q", rr.Header().Get("RateLimit-Limit"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if rr.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("expected RateLimit-Remaining 0, got %q", rr.Header().Get("RateLimit-Remaining"))
+	}
+
+	// другой IP считается отдельно
+	req = httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 for another IP, got %d", rr.Code)
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), 0)
+	policy := middleware.RateLimitPolicy{Name: "test", Limit: 1, Window: time.Minute, KeyBy: middleware.KeyByUser}
+	handler := limiter.Limit(policy, okHandler())
+
+	send := func(userID int64) int {
+		req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req = req.WithContext(utils.ContextWithUserID(req.Context(), userID))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := send(1); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := send(1); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", code)
+	}
+	// тот же IP, но другой пользователь
+	if code := send(2); code != http.StatusOK {
+		t.Errorf("expected 200 for another user, got %d", code)
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), 1)
+	policy := middleware.RateLimitPolicy{Name: "test", Limit: 2, Window: time.Minute, KeyBy: middleware.KeyByIP}
+	handler := limiter.Limit(policy, okHandler())
+
+	// клиент каждый раз подставляет свой X-Forwarded-For, прокси дописывает настоящий адрес
+	codes := []int{}
+	for _, spoofed := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "10.0.0.10:5555" // reverse proxy
+		req.Header.Set("X-Forwarded-For", spoofed+", 203.0.113.7")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+	}
+	if codes[2] != http.StatusTooManyRequests {
+		t.Errorf("spoofed X-Forwarded-For bypassed the limit: %v", codes)
+	}
+}
+
+func TestClientIPUsesTrustedHops(t *testing.T) {
+	tests := []struct {
+		name    string
+		hops    int
+		headers map[string][]string
+		want    string
+	}{
+		{"proxy not trusted", 0, map[string][]string{"X-Forwarded-For": {"203.0.113.7"}}, "10.0.0.10"},
+		{"one proxy", 1, map[string][]string{"X-Forwarded-For": {"6.6.6.6, 203.0.113.7"}}, "203.0.113.7"},
+		{"two proxies", 2, map[string][]string{"X-Forwarded-For": {"6.6.6.6, 203.0.113.7, 172.16.0.2"}}, "203.0.113.7"},
+		{"split header lines", 1, map[string][]string{"X-Forwarded-For": {"6.6.6.6", "203.0.113.7"}}, "203.0.113.7"},
+		{"fewer entries than hops", 2, map[string][]string{"X-Forwarded-For": {"203.0.113.7"}}, "10.0.0.10"},
+		{"garbage entry", 1, map[string][]string{"X-Forwarded-For": {"1.1.1.1, not-an-ip"}}, "10.0.0.10"},
+		{"x-real-ip", 1, map[string][]string{"X-Real-Ip": {"203.0.113.7"}}, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.10:5555"
+			for k, v := range tt.headers {
+				req.Header[k] = v
+			}
+			if got := middleware.ClientIP(req, tt.hops); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"database/sql"
+	"dl/handlers"
+	"dl/repositories"
+	"dl/services"
+	"dl/utils"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckNewUsername(t *testing.T) {
+	cases := []struct {
+		name string
+		code string
+	}{
+		{"eco_friend", ""},
+		{"badminton_fan", ""},
+		{"admin", utils.FieldReserved},
+		{"Adm1n", utils.FieldReserved},
+		{"ad_min", utils.FieldReserved},
+		{"administrator", utils.FieldReserved},
+		{"admin_42", utils.FieldReserved},
+		{"Support_Team", utils.FieldReserved},
+		{"deleted_17", utils.FieldReserved},
+		{"suppo1t", ""},
+		{"ab", utils.FieldInvalidUsername},
+		{"1admin", utils.FieldInvalidUsername},
+		{"аdmin", utils.FieldInvalidUsername}, // кириллическая "а"
+		{strings.Repeat("a", 31), utils.FieldTooLong},
+	}
+
+	for _, c := range cases {
+		fe := utils.CheckNewUsername(c.name)
+		got := ""
+		if fe != nil {
+			got = fe.Code
+		}
+		if got != c.code {
+			t.Errorf("%q: expected %q, got %q", c.name, c.code, got)
+		}
+	}
+
+	if utils.UsernameSkeleton("Ma_rnO0l1") != utils.UsernameSkeleton("maMooll") {
+		t.Errorf("skeletons differ: %s", utils.UsernameSkeleton("Ma_rnO0l1"))
+	}
+}
+
+func newChangeUsernameHandler() (*handlers.AuthHandler, sqlmock.Sqlmock, func()) {
+	db, mock, _ := sqlmock.New()
+	service := services.NewAuthService(repositories.NewUserRepository(db), utils.NewArgon2Hasher(utils.DefaultArgon2Params), nil)
+	return &handlers.AuthHandler{Service: service}, mock, func() { db.Close() }
+}
+
+func changeUsername(h *handlers.AuthHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/change-username", strings.NewReader(body))
+	req = req.WithContext(utils.ContextWithUserID(req.Context(), 5))
+	rr := httptest.NewRecorder()
+	h.ChangeUsername(rr, req)
+	return rr
+}
+
+func TestChangeUsername(t *testing.T) {
+	h, mock, done := newChangeUsernameHandler()
+	defer done()
+
+	mock.ExpectQuery("SELECT username, username_changed_at FROM users").WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "changed_at"}).AddRow("old_name", nil))
+	mock.ExpectQuery("username_skeleton").WithArgs("new_name", int64(5), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"taken"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET username").WithArgs(int64(5), "old_name", "new_name").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM username_history").WithArgs(int64(5), "new_name").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO username_history").WithArgs(int64(5), "old_name", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	rr := changeUsername(h, `{"username": " new_name "}`)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"previous_username":"old_name"`) {
+		t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChangeUsernameRejected(t *testing.T) {
+	h, mock, done := newChangeUsernameHandler()
+	defer done()
+
+	// зарезервированное имя — до обращения к БД
+	if rr := changeUsername(h, `{"username": "m0derator"}`); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), utils.FieldReserved) {
+		t.Errorf("reserved: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// кулдаун
+	mock.ExpectQuery("SELECT username, username_changed_at FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"username", "changed_at"}).AddRow("old_name", time.Now().Add(-24*time.Hour)))
+	rr := changeUsername(h, `{"username": "new_name"}`)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("cooldown: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// похоже на чужое имя
+	mock.ExpectQuery("SELECT username, username_changed_at FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"username", "changed_at"}).AddRow("old_name", time.Now().Add(-60*24*time.Hour)))
+	mock.ExpectQuery("username_skeleton").
+		WillReturnRows(sqlmock.NewRows([]string{"taken"}).AddRow(true))
+	rr = changeUsername(h, `{"username": "new_narne"}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), utils.FieldTaken) {
+		t.Errorf("taken: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// аккаунт удалён — 404
+	mock.ExpectQuery("SELECT username, username_changed_at FROM users").
+		WillReturnError(sql.ErrNoRows)
+	rr = changeUsername(h, `{"username": "new_name"}`)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("not found: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// ошибка БД — 500 без подробностей
+	mock.ExpectQuery("SELECT username, username_changed_at FROM users").
+		WillReturnError(errors.New("pq: connection refused to 10.0.0.5"))
+	rr = changeUsername(h, `{"username": "new_name"}`)
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "10.0.0.5") {
+		t.Errorf("db error: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublicProfileRedirectsOldUsername(t *testing.T) {
+	h, mock, done := newPublicProfileHandler(t)
+	defer done()
+
+	mock.ExpectQuery("FROM users WHERE username = \\$1").WithArgs("old_name").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("FROM username_history h").WithArgs("old_name", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("new_name"))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/old_name", nil)
+	req.SetPathValue("username", "old_name")
+	rr := httptest.NewRecorder()
+	h.Get(rr, req)
+
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/users/new_name" {
+		t.Errorf("expected redirect to /users/new_name, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -22,3 +22,49 @@ func UserIDFromContext(ctx context.Context) (int64, error) {
 	}
 	return id, nil
 }
+
+const scopesKey = contextKey("scopes")
+
+// Сохраняем scopes API-ключа в контексте (для JWT-сессий не задаются)
+func ContextWithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// HasScope — есть ли право у текущего запроса.
+// Запросы с JWT (без scopes в контексте) имеют полный доступ.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	if !ok {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIKeyRequest — запрос аутентифицирован API-ключом, а не сессией
+func IsAPIKeyRequest(ctx context.Context) bool {
+	_, ok := ctx.Value(scopesKey).([]string)
+	return ok
+}
+
+// RequestMeta — данные запроса для журнала безопасности
+type RequestMeta struct {
+	IP        string
+	UserAgent string
+}
+
+const requestMetaKey = contextKey("requestMeta")
+
+func ContextWithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey, meta)
+}
+
+// RequestMetaFromContext возвращает IP/User-Agent (пустые, если middleware не подключён)
+func RequestMetaFromContext(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(requestMetaKey).(RequestMeta)
+	return meta
+}
@@ -4,8 +4,11 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
 	"net/mail"
+	"os"
+	"strconv"
 
 	"gopkg.in/gomail.v2"
 )
@@ -22,16 +25,44 @@ func ValidateEmail(email string) error {
 }
 
 func SendVerificationEmail(to, code string) error {
+	return sendEmail(to, "Verify your email", "Click the link to verify your email: http://localhost:8080/verify?code="+code)
+}
+
+// sendEmail отправляет письмо через SMTP из env:
+// SMTP_HOST (smtp.gmail.com), SMTP_PORT (587), SMTP_USER, SMTP_PASSWORD, SMTP_FROM (= SMTP_USER)
+func sendEmail(to, subject, body string) error {
+	user, password := os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASSWORD")
+	if user == "" || password == "" {
+		err := errors.New("SMTP_USER and SMTP_PASSWORD are not set")
+		log.Printf("Failed to send %q email: %v", subject, err)
+		return err
+	}
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		host = "smtp.gmail.com"
+	}
+	port := 587
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP_PORT %q", v)
+		}
+		port = p
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = user
+	}
+
 	m := gomail.NewMessage()
-	m.SetHeader("From", "kalykovadana3@gmail.com")
+	m.SetHeader("From", from)
 	m.SetHeader("To", to)
-	m.SetHeader("Subject", "Verify your email")
-	m.SetBody("text/plain", "Click the link to verify your email: http://localhost:8080/verify?code="+code)
-
-	d := gomail.NewDialer("smtp.gmail.com", 587, "kalykovadana3@gmail.com", "qnxq kkph idrb lvsv")
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", body)
 
-	if err := d.DialAndSend(m); err != nil {
-		log.Println("Failed to send email:", err)
+	if err := gomail.NewDialer(host, port, user, password).DialAndSend(m); err != nil {
+		log.Printf("Failed to send %q email: %v", subject, err)
 		return err
 	}
 	return nil
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Разрешённые теги описаний новостей и их атрибуты. Остальные теги
+// разворачиваются (остаётся текст), опасные — удаляются вместе с содержимым.
+var sanitizeAllowed = map[string][]string{
+	"p": nil, "br": nil, "a": {"href", "title"},
+	"strong": nil, "b": nil, "em": nil, "i": nil, "u": nil,
+	"ul": nil, "ol": nil, "li": nil, "blockquote": nil,
+	"h2": nil, "h3": nil, "h4": nil, "code": nil, "pre": nil,
+}
+
+var sanitizeDropped = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"form": true, "input": true, "button": true, "textarea": true, "select": true,
+	"noscript": true, "svg": true, "math": true, "template": true, "head": true, "title": true,
+}
+
+func parseFragment(fragment string) (*goquery.Document, error) {
+	return goquery.NewDocumentFromReader(strings.NewReader(fragment))
+}
+
+// SanitizeHTML оставляет безопасное подмножество HTML. Относительные ссылки
+// разрешаются от base; ссылки с другими схемами (javascript: и т.п.) убираются.
+func SanitizeHTML(fragment, base string) string {
+	doc, err := parseFragment(fragment)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+
+	var b strings.Builder
+	for _, n := range doc.Find("body").Nodes {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			sanitizeNode(&b, c, base)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node, base string) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return // комментарии, doctype
+	}
+
+	tag := n.Data
+	if sanitizeDropped[tag] {
+		return
+	}
+
+	attrs, allowed := sanitizeAllowed[tag]
+	if allowed {
+		b.WriteString("<" + tag)
+		for _, a := range n.Attr {
+			if !containsString(attrs, a.Key) {
+				continue
+			}
+			val := a.Val
+			if a.Key == "href" {
+				if val = safeURL(val, base, "http", "https", "mailto"); val == "" {
+					continue
+				}
+			}
+			b.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+		}
+		if tag == "a" {
+			b.WriteString(` rel="nofollow noopener noreferrer"`)
+		}
+		b.WriteString(">")
+		if tag == "br" {
+			return
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c, base)
+	}
+
+	if allowed {
+		b.WriteString("</" + tag + ">")
+	}
+}
+
+// PlainText — текст без разметки с нормализованными пробелами,
+// обрезанный до maxRunes по границе слова (0 — без ограничения)
+func PlainText(fragment string, maxRunes int) string {
+	text := fragment
+	if doc, err := parseFragment(fragment); err == nil {
+		doc.Find(strings.Join(mapKeys(sanitizeDropped), ",")).Remove()
+		doc.Find("br, p, li, h2, h3, h4").Each(func(_ int, s *goquery.Selection) {
+			s.AfterHtml(" ")
+		})
+		text = doc.Text()
+	}
+	text = strings.Join(strings.Fields(text), " ")
+
+	if maxRunes <= 0 || utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:maxRunes])
+	// режем по границе слова, если слово не закончилось ровно на лимите
+	if runes[maxRunes] != ' ' {
+		if i := strings.LastIndexByte(cut, ' '); i > len(cut)/2 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " .,;:-") + "…"
+}
+
+// SafeImageURL — адрес картинки (http/https), относительный раскрывается от base; "" — не подходит
+func SafeImageURL(raw, base string) string {
+	return safeURL(raw, base, "http", "https")
+}
+
+// FirstImageURL — первая содержательная картинка фрагмента (пиксели 1x1 и data: пропускаются)
+func FirstImageURL(fragment, base string) string {
+	doc, err := parseFragment(fragment)
+	if err != nil {
+		return ""
+	}
+	var found string
+	doc.Find("img").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if w, _ := s.Attr("width"); w == "1" || w == "0" {
+			return true
+		}
+		src, _ := s.Attr("src")
+		if src == "" {
+			src, _ = s.Attr("data-src")
+		}
+		found = SafeImageURL(src, base)
+		return found == ""
+	})
+	return found
+}
+
+// PageImageURL — картинка-превью страницы статьи: og:image, twitter:image, image_src
+func PageImageURL(page, base string) string {
+	doc, err := parseFragment(page)
+	if err != nil {
+		return ""
+	}
+	selectors := []struct{ sel, attr string }{
+		{`meta[property="og:image:secure_url"]`, "content"},
+		{`meta[property="og:image"]`, "content"},
+		{`meta[name="twitter:image"]`, "content"},
+		{`meta[name="twitter:image:src"]`, "content"},
+		{`link[rel="image_src"]`, "href"},
+	}
+	for _, s := range selectors {
+		if v, ok := doc.Find(s.sel).First().Attr(s.attr); ok {
+			if u := SafeImageURL(v, base); u != "" {
+				return u
+			}
+		}
+	}
+	return ""
+}
+
+// safeURL разрешает ссылку от base и пропускает только перечисленные схемы
+func safeURL(raw, base string, schemes ...string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if !u.IsAbs() && base != "" {
+		b, err := url.Parse(base)
+		if err != nil {
+			return ""
+		}
+		u = b.ResolveReference(u)
+	}
+	if !containsString(schemes, strings.ToLower(u.Scheme)) {
+		return ""
+	}
+	if u.Scheme != "mailto" && u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,308 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidImage — загруженный файл не подходит как аватар (ошибка клиента)
+var ErrInvalidImage = errors.New("invalid image")
+
+const (
+	MaxAvatarBytes  = 5 * 1024 * 1024 // 5MB
+	maxAvatarPixels = 25_000_000      // защита от "decompression bomb"
+	minAvatarSide   = 32
+	avatarMaxSide   = 1024 // основное изображение вписывается в 1024x1024
+	avatarQuality   = 85
+)
+
+// AvatarThumbnailSizes — квадратные превью (center crop)
+var AvatarThumbnailSizes = []int{256, 128, 64}
+
+// ProcessedAvatar — перекодированный JPEG и превью по размерам.
+// Метаданные исходника (EXIF, GPS) не переносятся: пишутся только пиксели.
+type ProcessedAvatar struct {
+	Main       []byte
+	Thumbnails map[int][]byte
+}
+
+// AvatarThumbnailURLs — публичные пути превью для аватара "<hash>.jpg".
+// Для старых аватаров (до обработки) превью нет — возвращается nil.
+func AvatarThumbnailURLs(publicPath string) map[string]string {
+	base, ok := strings.CutSuffix(publicPath, ".jpg")
+	if !ok {
+		return nil
+	}
+	name := base[strings.LastIndex(base, "/")+1:]
+	if len(name) != 32 || strings.Trim(name, "0123456789abcdef") != "" {
+		return nil
+	}
+
+	urls := make(map[string]string, len(AvatarThumbnailSizes))
+	for _, size := range AvatarThumbnailSizes {
+		urls[strconv.Itoa(size)] = base + "_" + strconv.Itoa(size) + ".jpg"
+	}
+	return urls
+}
+
+// ------------------------ SNIFF ------------------------
+
+// SniffImage определяет формат по содержимому, а не по расширению файла
+func SniffImage(data []byte) (string, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return "jpeg", nil
+	case "image/png":
+		return "png", nil
+	case "image/gif":
+		return "gif", nil
+	}
+	return "", fmt.Errorf("%w: only JPEG, PNG and GIF are allowed", ErrInvalidImage)
+}
+
+// ------------------------ PROCESS ------------------------
+
+func ProcessAvatar(data []byte) (*ProcessedAvatar, error) {
+	if len(data) > MaxAvatarBytes {
+		return nil, fmt.Errorf("%w: file too large, max 5MB allowed", ErrInvalidImage)
+	}
+
+	format, err := SniffImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// размеры читаем до полного декодирования
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read image", ErrInvalidImage)
+	}
+	if cfg.Width < minAvatarSide || cfg.Height < minAvatarSide {
+		return nil, fmt.Errorf("%w: image must be at least %dx%d", ErrInvalidImage, minAvatarSide, minAvatarSide)
+	}
+	if cfg.Width*cfg.Height > maxAvatarPixels {
+		return nil, fmt.Errorf("%w: image dimensions are too large", ErrInvalidImage)
+	}
+
+	var src image.Image
+	switch format {
+	case "jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "gif":
+		src, err = gif.Decode(bytes.NewReader(data)) // только первый кадр
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode image", ErrInvalidImage)
+	}
+
+	img := flatten(src)
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	out := &ProcessedAvatar{Thumbnails: make(map[int][]byte)}
+
+	w, h := fitSize(img.Bounds().Dx(), img.Bounds().Dy(), avatarMaxSide)
+	if out.Main, err = encodeJPEG(resize(img, img.Bounds(), w, h)); err != nil {
+		return nil, err
+	}
+
+	square := centerSquare(img.Bounds())
+	for _, size := range AvatarThumbnailSizes {
+		if out.Thumbnails[size], err = encodeJPEG(resize(img, square, size, size)); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: avatarQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// flatten приводит изображение к RGBA без прозрачности (на белом фоне)
+func flatten(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+func fitSize(w, h, max int) (int, int) {
+	if w <= max && h <= max {
+		return w, h
+	}
+	if w >= h {
+		return max, maxInt(1, h*max/w)
+	}
+	return maxInt(1, w*max/h), max
+}
+
+func centerSquare(b image.Rectangle) image.Rectangle {
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
+
+// resize масштабирует область src в w×h усреднением по площади (box filter)
+func resize(src *image.RGBA, area image.Rectangle, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sw, sh := area.Dx(), area.Dy()
+
+	for dy := 0; dy < h; dy++ {
+		y0 := area.Min.Y + dy*sh/h
+		y1 := maxInt(area.Min.Y+(dy+1)*sh/h, y0+1)
+
+		for dx := 0; dx < w; dx++ {
+			x0 := area.Min.X + dx*sw/w
+			x1 := maxInt(area.Min.X+(dx+1)*sw/w, x0+1)
+
+			var r, g, b, n uint32
+			for y := y0; y < y1; y++ {
+				off := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					r += uint32(src.Pix[off])
+					g += uint32(src.Pix[off+1])
+					b += uint32(src.Pix[off+2])
+					off += 4
+					n++
+				}
+			}
+
+			o := dst.PixOffset(dx, dy)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = 0xff
+		}
+	}
+	return dst
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ------------------------ EXIF ORIENTATION ------------------------
+
+// jpegOrientation достаёт тег Orientation (0x0112) из APP1/Exif; 1 — без поворота
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // начало данных — дальше метаданных нет
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(seg) > 14 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func tiffOrientation(t []byte) int {
+	var bo binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(bo.Uint32(t[4:]))
+	if ifd+2 > len(t) {
+		return 1
+	}
+	count := int(bo.Uint16(t[ifd:]))
+	for k := 0; k < count; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(t) {
+			return 1
+		}
+		if bo.Uint16(t[e:]) == 0x0112 {
+			if v := int(bo.Uint16(t[e+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation поворачивает/отражает пиксели так, как их показала бы камера
+func applyOrientation(src *image.RGBA, o int) *image.RGBA {
+	if o <= 1 || o > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var nx, ny int
+			switch o {
+			case 2:
+				nx, ny = w-1-x, y
+			case 3:
+				nx, ny = w-1-x, h-1-y
+			case 4:
+				nx, ny = x, h-1-y
+			case 5:
+				nx, ny = y, x
+			case 6:
+				nx, ny = h-1-y, x
+			case 7:
+				nx, ny = h-1-y, w-1-x
+			case 8:
+				nx, ny = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(nx, ny):dst.PixOffset(nx, ny)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
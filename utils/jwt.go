@@ -1,42 +1,371 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	// минимальная длина HMAC-секрета (256 бит)
+	minHMACSecretLen = 32
+)
 
 type Claims struct {
-	UserID int64 `json:"user_id"`
+	UserID    int64  `json:"user_id"`
+	TokenType string `json:"token_type"`
 	jwt.RegisteredClaims
 }
 
-func GenerateTokens(userID int64) (string, string, error) {
-	// access token (15 min)
-	accessClaims := &Claims{
-		UserID: userID,
+// SigningKey — ключ подписи/проверки токенов.
+// Private == nil означает ключ только для проверки (старый, в grace-периоде).
+type SigningKey struct {
+	Kid      string
+	Alg      string      // HS256 | RS256 | EdDSA
+	Private  interface{} // []byte, *rsa.PrivateKey, ed25519.PrivateKey
+	Public   interface{} // []byte, *rsa.PublicKey, ed25519.PublicKey
+	RetireAt time.Time   // после этого момента ключ не принимается (zero — бессрочно)
+}
+
+// KeyManager хранит активный ключ подписи и старые ключи для проверки
+type KeyManager struct {
+	Issuer   string
+	Audience string
+
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewKeyManager(issuer, audience string) *KeyManager {
+	return &KeyManager{Issuer: issuer, Audience: audience, keys: make(map[string]*SigningKey)}
+}
+
+var (
+	keysMu      sync.RWMutex
+	defaultKeys *KeyManager
+)
+
+// SetKeyManager задаёт менеджер ключей, используемый GenerateTokens/ParseToken
+func SetKeyManager(km *KeyManager) {
+	keysMu.Lock()
+	defaultKeys = km
+	keysMu.Unlock()
+}
+
+// Keys возвращает текущий менеджер ключей (nil, если не настроен)
+func Keys() *KeyManager {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return defaultKeys
+}
+
+// ------------------------ KEYS ------------------------
+
+// NewHMACKey создаёт HS256-ключ; слабые секреты отклоняются
+func NewHMACKey(secret string) (*SigningKey, error) {
+	if len(secret) < minHMACSecretLen {
+		return nil, fmt.Errorf("jwt secret must be at least %d bytes", minHMACSecretLen)
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return &SigningKey{
+		Kid:     "hs-" + hex.EncodeToString(sum[:8]),
+		Alg:     "HS256",
+		Private: []byte(secret),
+		Public:  []byte(secret),
+	}, nil
+}
+
+// NewAsymmetricKey создаёт RS256/EdDSA-ключ из приватного или публичного ключа
+func NewAsymmetricKey(key interface{}) (*SigningKey, error) {
+	k := &SigningKey{}
+
+	switch v := key.(type) {
+	case *rsa.PrivateKey:
+		if v.N.BitLen() < 2048 {
+			return nil, errors.New("rsa key must be at least 2048 bits")
+		}
+		k.Alg, k.Private, k.Public = "RS256", v, &v.PublicKey
+	case *rsa.PublicKey:
+		k.Alg, k.Public = "RS256", v
+	case ed25519.PrivateKey:
+		k.Alg, k.Private, k.Public = "EdDSA", v, v.Public()
+	case ed25519.PublicKey:
+		k.Alg, k.Public = "EdDSA", v
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	jwk, err := NewJWK("", k.Alg, k.Public.(crypto.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	k.Kid = jwk.Thumbprint()
+	return k, nil
+}
+
+// LoadPEMKey читает PEM-файл с приватным (PKCS#8/PKCS#1) или публичным ключом
+func LoadPEMKey(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+}
+
+// SetActive делает ключ активным для подписи (он же принимается при проверке)
+func (m *KeyManager) SetActive(k *SigningKey) error {
+	if k.Private == nil {
+		return errors.New("active key must contain a private key")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// прежний активный ключ продолжает приниматься, пока живут выданные им refresh-токены
+	if prev := m.active; prev != nil && prev.Kid != k.Kid && prev.RetireAt.IsZero() {
+		prev.RetireAt = time.Now().Add(RefreshTokenTTL)
+	}
+
+	m.active = k
+	m.keys[k.Kid] = k
+	return nil
+}
+
+// AddVerificationKey добавляет старый ключ, который принимается до retireAt
+func (m *KeyManager) AddVerificationKey(k *SigningKey, retireAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k.RetireAt = retireAt
+	m.keys[k.Kid] = k
+}
+
+func (m *KeyManager) lookup(kid string) (*SigningKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	k, ok := m.keys[kid]
+	if !ok || (!k.RetireAt.IsZero() && time.Now().After(k.RetireAt)) {
+		return nil, false
+	}
+	return k, true
+}
+
+// PublicJWKS — публичные ключи для /.well-known/jwks.json (HMAC-секреты не публикуются)
+func (m *KeyManager) PublicJWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	now := time.Now()
+	for _, k := range m.keys {
+		if k.Alg == "HS256" || (!k.RetireAt.IsZero() && now.After(k.RetireAt)) {
+			continue
+		}
+		jwk, err := NewJWK(k.Kid, k.Alg, k.Public.(crypto.PublicKey))
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// ------------------------ CONFIG FROM ENV ------------------------
+
+// LoadKeyManagerFromEnv настраивает ключи:
+//
+//	JWT_ALG                 HS256 (по умолчанию) | RS256 | EdDSA
+//	JWT_SECRET              секрет HS256 (не короче 32 байт)
+//	JWT_PRIVATE_KEY_FILE    PEM с приватным ключом для RS256/EdDSA
+//	JWT_PREVIOUS_SECRETS    старые HS256-секреты через запятую
+//	JWT_PREVIOUS_KEY_FILES  старые PEM-ключи через запятую
+//	JWT_ROTATION_GRACE      сколько принимать старые ключи (по умолчанию 168h = срок refresh)
+//	JWT_ISSUER, JWT_AUDIENCE
+func LoadKeyManagerFromEnv() (*KeyManager, error) {
+	km := NewKeyManager(envOr("JWT_ISSUER", "ecofoot"), envOr("JWT_AUDIENCE", "ecofoot-api"))
+
+	var active *SigningKey
+	var err error
+
+	switch alg := envOr("JWT_ALG", "HS256"); alg {
+	case "HS256":
+		active, err = NewHMACKey(os.Getenv("JWT_SECRET"))
+	case "RS256", "EdDSA":
+		path := os.Getenv("JWT_PRIVATE_KEY_FILE")
+		if path == "" {
+			return nil, errors.New("JWT_PRIVATE_KEY_FILE is required for " + alg)
+		}
+		var key interface{}
+		if key, err = LoadPEMKey(path); err == nil {
+			active, err = NewAsymmetricKey(key)
+		}
+		if err == nil && active.Alg != alg {
+			err = fmt.Errorf("JWT_PRIVATE_KEY_FILE contains %s key, JWT_ALG is %s", active.Alg, alg)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALG %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := km.SetActive(active); err != nil {
+		return nil, err
+	}
+
+	grace := RefreshTokenTTL
+	if v := os.Getenv("JWT_ROTATION_GRACE"); v != "" {
+		if grace, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid JWT_ROTATION_GRACE: %w", err)
+		}
+	}
+	retireAt := time.Now().Add(grace)
+
+	for _, secret := range splitList(os.Getenv("JWT_PREVIOUS_SECRETS")) {
+		k, err := NewHMACKey(secret)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PREVIOUS_SECRETS: %w", err)
+		}
+		km.AddVerificationKey(k, retireAt)
+	}
+	for _, path := range splitList(os.Getenv("JWT_PREVIOUS_KEY_FILES")) {
+		key, err := LoadPEMKey(path)
+		if err != nil {
+			return nil, err
+		}
+		k, err := NewAsymmetricKey(key)
+		if err != nil {
+			return nil, err
+		}
+		k.Private = nil // старые ключи только для проверки
+		km.AddVerificationKey(k, retireAt)
+	}
+
+	return km, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// ------------------------ SIGN / PARSE ------------------------
+
+func (m *KeyManager) sign(userID int64, tokenType string, ttl time.Duration) (string, error) {
+	m.mu.RLock()
+	k := m.active
+	m.mu.RUnlock()
+	if k == nil {
+		return "", errors.New("jwt signing key is not configured")
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		UserID:    userID,
+		TokenType: tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    m.Issuer,
+			Audience:  jwt.ClaimStrings{m.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        RandomURLToken(12),
 		},
 	}
-	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).SignedString(jwtKey)
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.Alg), claims)
+	token.Header["kid"] = k.Kid
+	return token.SignedString(k.Private)
+}
+
+func (m *KeyManager) parse(tokenStr, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		k, ok := m.lookup(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		// алгоритм задаёт ключ, а не заголовок токена
+		if t.Method.Alg() != k.Alg {
+			return nil, errors.New("unexpected signing method")
+		}
+		return k.Public, nil
+	},
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}),
+		jwt.WithIssuer(m.Issuer),
+		jwt.WithAudience(m.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenType != tokenType {
+		return nil, errors.New("wrong token type")
+	}
+	return claims, nil
+}
+
+func GenerateTokens(userID int64) (string, string, error) {
+	km := Keys()
+	if km == nil {
+		return "", "", errors.New("jwt keys are not configured")
+	}
+
+	// access token (15 min)
+	accessToken, err := km.sign(userID, TokenTypeAccess, AccessTokenTTL)
 	if err != nil {
 		return "", "", err
 	}
 
 	// refresh token (7 days)
-	refreshClaims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString(jwtKey)
+	refreshToken, err := km.sign(userID, TokenTypeRefresh, RefreshTokenTTL)
 	if err != nil {
 		return "", "", err
 	}
@@ -44,13 +373,20 @@ func GenerateTokens(userID int64) (string, string, error) {
 	return accessToken, refreshToken, nil
 }
 
+// ParseToken проверяет access-токен (refresh-токен здесь не принимается)
 func ParseToken(tokenStr string) (*Claims, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
-	if err != nil || !token.Valid {
-		return nil, err
+	km := Keys()
+	if km == nil {
+		return nil, errors.New("jwt keys are not configured")
 	}
-	return claims, nil
+	return km.parse(tokenStr, TokenTypeAccess)
+}
+
+// ParseRefreshToken проверяет refresh-токен
+func ParseRefreshToken(tokenStr string) (*Claims, error) {
+	km := Keys()
+	if km == nil {
+		return nil, errors.New("jwt keys are not configured")
+	}
+	return km.parse(tokenStr, TokenTypeRefresh)
 }
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Параметры, которые не меняют страницу, а только метят переход
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "yclid": true, "dclid": true, "msclkid": true,
+	"igshid": true, "mc_cid": true, "mc_eid": true, "_ga": true, "_openstat": true,
+	"ref": true, "ref_src": true, "rss": true,
+}
+
+// CanonicalURL — ключ для сравнения ссылок: без схемы, www., порта по умолчанию,
+// фрагмента, utm_* и прочих меток; остальные параметры отсортированы.
+// "https://www.Example.com/a/?utm_source=x&b=2&a=1#top" → "example.com/a?a=1&b=2".
+// Ссылка, которую не удалось разобрать, возвращается как есть.
+func CanonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	path := u.EscapedPath()
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if path == "/" {
+		path = ""
+	}
+
+	q := u.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "utm_") || trackingParams[lk] {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var query []string
+	for _, k := range keys {
+		vals := q[k]
+		sort.Strings(vals)
+		for _, v := range vals {
+			query = append(query, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	key := host + path
+	if len(query) > 0 {
+		key += "?" + strings.Join(query, "&")
+	}
+	return key
+}
+
+// SimHash — 64-битный отпечаток текста по шинглам из трёх слов.
+// У похожих текстов отпечатки отличаются в немногих битах (см. HammingDistance).
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	const shingle = 3
+	var weights [64]int
+	add := func(s string) {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	if len(words) < shingle {
+		add(strings.Join(words, " "))
+	} else {
+		for i := 0; i+shingle <= len(words); i++ {
+			add(strings.Join(words[i:i+shingle], " "))
+		}
+	}
+
+	var hash uint64
+	for i, w := range weights {
+		if w > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// HammingDistance — число различающихся битов
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher хэширует пароли в PHC-формате ($alg$...$salt$hash).
+// Verify сообщает needsRehash, если хэш сделан устаревшим алгоритмом
+// или параметрами — тогда его стоит пересчитать при успешном входе.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (ok bool, needsRehash bool, err error)
+}
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Argon2Params — параметры Argon2id (memory в KiB)
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params — рекомендация OWASP (m=64MiB, t=3, p=2)
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Допустимые границы параметров: вне их argon2.IDKey паникует
+// (t=0, p=0) или хэширование становится бессмысленно слабым/тяжёлым
+const (
+	minArgon2Memory     = 1024            // 1 MiB
+	maxArgon2Memory     = 4 * 1024 * 1024 // 4 GiB
+	maxArgon2Iterations = 100
+	maxArgon2Threads    = 64
+	minArgon2SaltLength = 8
+	minArgon2KeyLength  = 16
+)
+
+// Validate проверяет параметры; вызывается при старте, до первого хэширования
+func (p Argon2Params) Validate() error {
+	switch {
+	case p.Memory < minArgon2Memory || p.Memory > maxArgon2Memory:
+		return fmt.Errorf("argon2 memory must be between %d and %d KiB, got %d", minArgon2Memory, maxArgon2Memory, p.Memory)
+	case p.Iterations < 1 || p.Iterations > maxArgon2Iterations:
+		return fmt.Errorf("argon2 iterations must be between 1 and %d, got %d", maxArgon2Iterations, p.Iterations)
+	case p.Parallelism < 1 || p.Parallelism > maxArgon2Threads:
+		return fmt.Errorf("argon2 parallelism must be between 1 and %d, got %d", maxArgon2Threads, p.Parallelism)
+	case p.Memory < 8*uint32(p.Parallelism):
+		return fmt.Errorf("argon2 memory must be at least 8 KiB per thread")
+	case p.SaltLength < minArgon2SaltLength:
+		return fmt.Errorf("argon2 salt must be at least %d bytes", minArgon2SaltLength)
+	case p.KeyLength < minArgon2KeyLength:
+		return fmt.Errorf("argon2 key must be at least %d bytes", minArgon2KeyLength)
+	}
+	return nil
+}
+
+// Argon2Hasher — Argon2id для новых паролей; bcrypt-хэши продолжают проверяться
+type Argon2Hasher struct {
+	Params Argon2Params
+}
+
+func NewArgon2Hasher(params Argon2Params) *Argon2Hasher {
+	return &Argon2Hasher{Params: params}
+}
+
+// ------------------------ HASH ------------------------
+
+func (h *Argon2Hasher) Hash(password string) (string, error) {
+	p := h.Params
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// ------------------------ VERIFY ------------------------
+
+func (h *Argon2Hasher) Verify(password, encoded string) (bool, bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return h.verifyArgon2(password, encoded)
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		// старые bcrypt-хэши: проверяем и всегда просим пересчитать
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		return true, true, nil
+	}
+
+	return false, false, ErrUnknownHashFormat
+}
+
+func (h *Argon2Hasher) verifyArgon2(password, encoded string) (bool, bool, error) {
+	// $argon2id$v=19$m=65536,t=3,p=2$salt$hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrUnknownHashFormat
+	}
+
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return false, false, ErrUnknownHashFormat
+	}
+	// повреждённый хэш с t=0 или p=0 уронил бы argon2.IDKey
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return false, false, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnknownHashFormat
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, ErrUnknownHashFormat
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(want))
+
+	got := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return false, false, nil
+	}
+
+	cur := h.Params
+	needsRehash := p.Memory != cur.Memory ||
+		p.Iterations != cur.Iterations ||
+		p.Parallelism != cur.Parallelism ||
+		p.SaltLength != cur.SaltLength ||
+		p.KeyLength != cur.KeyLength
+
+	return true, needsRehash, nil
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды нарушений политики паролей — стабильные, по ним фронтенд показывает локализованный текст
+const (
+	PasswordTooShort          = "password_too_short"
+	PasswordTooLong           = "password_too_long"
+	PasswordMissingUppercase  = "password_missing_uppercase"
+	PasswordMissingLowercase  = "password_missing_lowercase"
+	PasswordMissingDigit      = "password_missing_digit"
+	PasswordMissingSpecial    = "password_missing_special"
+	PasswordCommon            = "password_common"
+	PasswordBreached          = "password_breached"
+	PasswordSimilarToUsername = "password_similar_to_username"
+	PasswordSimilarToEmail    = "password_similar_to_email"
+)
+
+// PasswordViolation — одно нарушение: код, текст по умолчанию (англ.) и параметры для шаблона
+type PasswordViolation struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// PasswordPolicyError содержит все нарушения сразу, а не только первое
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+//go:embed data/common_passwords.txt
+var commonPasswordsFile string
+
+// PasswordPolicy — правила проверки паролей.
+// BreachDir — каталог с HIBP-совместимыми range-файлами: <5 hex префикса SHA-1>.txt,
+// строки вида "<35 hex суффикса>:<count>". Пустой — проверка отключена.
+type PasswordPolicy struct {
+	MinLength      int
+	MaxLength      int
+	RequireClasses bool
+	BreachDir      string
+
+	common map[string]struct{}
+}
+
+func NewPasswordPolicy(breachDir string) *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      128,
+		RequireClasses: true,
+		BreachDir:      breachDir,
+		common:         parseCommonPasswords(commonPasswordsFile),
+	}
+}
+
+func parseCommonPasswords(data string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+	}
+	return set
+}
+
+var (
+	policyMu      sync.RWMutex
+	defaultPolicy = NewPasswordPolicy("")
+)
+
+// SetPasswordPolicy задаёт политику, используемую ValidatePassword/CheckPassword
+func SetPasswordPolicy(p *PasswordPolicy) {
+	policyMu.Lock()
+	defaultPolicy = p
+	policyMu.Unlock()
+}
+
+// CheckPassword проверяет пароль текущей политикой; nil — пароль подходит
+func CheckPassword(password, username, email string) error {
+	policyMu.RLock()
+	p := defaultPolicy
+	policyMu.RUnlock()
+
+	if v := p.Check(password, username, email); len(v) > 0 {
+		return &PasswordPolicyError{Violations: v}
+	}
+	return nil
+}
+
+// ------------------------ CHECK ------------------------
+
+func (p *PasswordPolicy) Check(password, username, email string) []PasswordViolation {
+	var out []PasswordViolation
+	add := func(code, msg string, params map[string]interface{}) {
+		out = append(out, PasswordViolation{Code: code, Message: msg, Params: params})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(PasswordTooShort, fmt.Sprintf("password must be at least %d characters", p.MinLength), map[string]interface{}{"min": p.MinLength})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(PasswordTooLong, "password is too long", map[string]interface{}{"max": p.MaxLength})
+	}
+
+	if p.RequireClasses {
+		var upper, lower, digit, special bool
+		for _, r := range password {
+			switch {
+			case unicode.IsUpper(r):
+				upper = true
+			case unicode.IsLower(r):
+				lower = true
+			case unicode.IsDigit(r):
+				digit = true
+			case unicode.IsPunct(r) || unicode.IsSymbol(r):
+				special = true
+			}
+		}
+		if !upper {
+			add(PasswordMissingUppercase, "password must contain at least one uppercase letter", nil)
+		}
+		if !lower {
+			add(PasswordMissingLowercase, "password must contain at least one lowercase letter", nil)
+		}
+		if !digit {
+			add(PasswordMissingDigit, "password must contain at least one digit", nil)
+		}
+		if !special {
+			add(PasswordMissingSpecial, "password must contain at least one special character", nil)
+		}
+	}
+
+	if p.isCommon(password) {
+		add(PasswordCommon, "password is too common", nil)
+	}
+
+	if username != "" && similar(password, username) {
+		add(PasswordSimilarToUsername, "password is too similar to username", nil)
+	}
+	if local, _, _ := strings.Cut(email, "@"); local != "" && similar(password, local) {
+		add(PasswordSimilarToEmail, "password is too similar to email", nil)
+	}
+
+	if breached, count := p.isBreached(password); breached {
+		add(PasswordBreached, "password has appeared in a data breach", map[string]interface{}{"count": count})
+	}
+
+	return out
+}
+
+// isCommon — пароль или его основа (без цифр и символов по краям) в списке
+func (p *PasswordPolicy) isCommon(password string) bool {
+	lower := strings.ToLower(password)
+	if _, ok := p.common[lower]; ok {
+		return true
+	}
+
+	base := strings.TrimFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	if utf8.RuneCountInString(base) >= 4 {
+		if _, ok := p.common[base]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isBreached ищет SHA-1 пароля в локальных range-файлах (k-anonymity формат HIBP)
+func (p *PasswordPolicy) isBreached(password string) (bool, int) {
+	if p.BreachDir == "" {
+		return false, 0
+	}
+
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	f, err := os.Open(filepath.Join(p.BreachDir, prefix+".txt"))
+	if err != nil {
+		return false, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, countStr, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(hash, suffix) {
+			count := 0
+			for _, c := range countStr {
+				if c < '0' || c > '9' {
+					break
+				}
+				count = count*10 + int(c-'0')
+			}
+			// HIBP padding-записи имеют count 0
+			return count > 0, count
+		}
+	}
+	return false, 0
+}
+
+// similar — пароль содержит идентификатор (или наоборот), в том числе задом наперёд,
+// либо отличается от него на пару символов
+func similar(password, ident string) bool {
+	pw := strings.ToLower(password)
+	id := strings.ToLower(ident)
+	if utf8.RuneCountInString(id) < 3 {
+		return false
+	}
+
+	if strings.Contains(pw, id) || strings.Contains(pw, reverse(id)) {
+		return true
+	}
+	if utf8.RuneCountInString(pw) >= 4 && strings.Contains(id, pw) {
+		return true
+	}
+
+	maxLen := utf8.RuneCountInString(pw)
+	if n := utf8.RuneCountInString(id); n > maxLen {
+		maxLen = n
+	}
+	return levenshtein(pw, id)*3 < maxLen // отличие меньше трети длины
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
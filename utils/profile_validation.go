@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды ошибок полей профиля
+const (
+	FieldRequired      = "required"
+	FieldTooLong       = "too_long"
+	FieldInvalidChars  = "invalid_characters"
+	FieldInvalidChoice = "invalid_choice"
+	FieldInvalidDate   = "invalid_date"
+	FieldDateInFuture  = "date_in_future"
+	FieldDateTooOld    = "date_too_old"
+	FieldInvalidURL    = "invalid_url"
+	FieldOutOfRange    = "out_of_range"
+)
+
+const (
+	MaxNameLength = 100 // VARCHAR(100) в users
+	MaxBioLength  = 500
+)
+
+// Genders — допустимые значения users.gender (CHECK в миграции 023)
+var Genders = []string{"male", "female", "other", "prefer_not_to_say"}
+
+// FieldError — ошибка одного поля: код для фронтенда и текст по умолчанию
+type FieldError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// ValidationError собирает ошибки по всем полям запроса сразу
+type ValidationError struct {
+	Fields map[string]FieldError
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = name + ": " + e.Fields[name].Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ------------------------ FIELDS ------------------------
+
+// ValidatePersonName — имя/фамилия: буквы, пробелы, дефис, апостроф
+func ValidatePersonName(name string) *FieldError {
+	if name == "" {
+		return &FieldError{Code: FieldRequired, Message: "must not be empty, use null to clear"}
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return &FieldError{Code: FieldTooLong, Message: fmt.Sprintf("must be at most %d characters", MaxNameLength),
+			Params: map[string]interface{}{"max": MaxNameLength}}
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.Is(unicode.Mn, r) && r != ' ' && r != '-' && r != '\'' && r != '’' {
+			return &FieldError{Code: FieldInvalidChars, Message: "may contain only letters, spaces, hyphens and apostrophes"}
+		}
+	}
+	return nil
+}
+
+// NormalizeNewlines приводит переводы строк к \n (textarea в браузере отправляет \r\n)
+func NormalizeNewlines(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
+
+// ValidateBio — bio после NormalizeNewlines: переводы строк и табуляция разрешены
+func ValidateBio(bio string) *FieldError {
+	if utf8.RuneCountInString(bio) > MaxBioLength {
+		return &FieldError{Code: FieldTooLong, Message: fmt.Sprintf("must be at most %d characters", MaxBioLength),
+			Params: map[string]interface{}{"max": MaxBioLength}}
+	}
+	for _, r := range bio {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return &FieldError{Code: FieldInvalidChars, Message: "must not contain control characters"}
+		}
+	}
+	return nil
+}
+
+func ValidateGender(gender string) *FieldError {
+	for _, g := range Genders {
+		if gender == g {
+			return nil
+		}
+	}
+	return &FieldError{Code: FieldInvalidChoice, Message: "must be one of: " + strings.Join(Genders, ", "),
+		Params: map[string]interface{}{"choices": Genders}}
+}
+
+// ValidateBirthDate — реальная дата YYYY-MM-DD не в будущем и не раньше 1900 года
+func ValidateBirthDate(date string) *FieldError {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return &FieldError{Code: FieldInvalidDate, Message: "must be a valid date in YYYY-MM-DD format"}
+	}
+	if d.After(time.Now()) {
+		return &FieldError{Code: FieldDateInFuture, Message: "must not be in the future"}
+	}
+	if d.Year() < 1900 {
+		return &FieldError{Code: FieldDateTooOld, Message: "must not be earlier than 1900-01-01"}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrSignatureInvalid = errors.New("invalid link signature")
+	ErrSignatureExpired = errors.New("link has expired")
+)
+
+// URLSigner подписывает временные ссылки (HMAC-SHA256 от ресурса и срока действия).
+// Ссылка сама несёт право доступа, поэтому JWT для неё не нужен.
+type URLSigner struct {
+	secret []byte
+}
+
+// NewURLSigner — пустой секрет заменяется случайным: ссылки
+// перестанут работать после рестарта, но сервер не падает
+func NewURLSigner(secret string) *URLSigner {
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		log.Println("URL_SIGNING_SECRET is not set, signed links will not survive restart")
+		return &URLSigner{secret: b}
+	}
+	return &URLSigner{secret: []byte(secret)}
+}
+
+func (s *URLSigner) mac(resource string, expires int64) []byte {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(resource + ":" + strconv.FormatInt(expires, 10)))
+	return m.Sum(nil)
+}
+
+// Sign возвращает подпись для resource, действующую до expires
+func (s *URLSigner) Sign(resource string, expires time.Time) string {
+	return hex.EncodeToString(s.mac(resource, expires.Unix()))
+}
+
+// Verify проверяет подпись и срок действия (expires — unix-время из ссылки)
+func (s *URLSigner) Verify(resource string, expires int64, sig string) error {
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(resource, expires)) {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > expires {
+		return ErrSignatureExpired
+	}
+	return nil
+}
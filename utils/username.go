@@ -1,8 +1,13 @@
 package utils
 
 import (
+	"crypto/rand"
+	_ "embed"
 	"errors"
+	"fmt"
+	"math/big"
 	"regexp"
+	"strings"
 )
 
 func ValidateUsername(username string) error {
@@ -30,3 +35,124 @@ func ValidateUsername(username string) error {
 
 	return nil
 }
+
+// GenerateUsername строит имя пользователя из подсказки (имя, email),
+// которое проходит CheckNewUsername. Для уникальности добавляется случайный суффикс.
+func GenerateUsername(hint string) string {
+	if at := strings.IndexByte(hint, '@'); at >= 0 {
+		hint = hint[:at]
+	}
+
+	var b strings.Builder
+	for _, r := range hint {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		case r == '.' || r == '-' || r == ' ':
+			b.WriteRune('_')
+		}
+	}
+
+	base := strings.TrimLeft(b.String(), "0123456789_")
+	if len(base) < 3 {
+		base = "user"
+	}
+	if len(base) > 20 {
+		base = base[:20]
+	}
+
+	n, _ := rand.Int(rand.Reader, big.NewInt(100000))
+	name := fmt.Sprintf("%s_%05d", base, n.Int64())
+
+	if CheckNewUsername(name) != nil {
+		return fmt.Sprintf("user_%05d", n.Int64())
+	}
+	return name
+}
+
+// ------------------------ USERNAME POLICY ------------------------
+
+// Коды ошибок имени пользователя (вместе с Field* из profile_validation.go)
+const (
+	FieldInvalidUsername = "invalid_username"
+	FieldReserved        = "reserved"
+	FieldTaken           = "taken"
+)
+
+// MaxUsernameLength — ограничение для новых имён (колонка VARCHAR(50))
+const MaxUsernameLength = 30
+
+// Префиксы, под которыми легко выдать себя за администрацию
+// или за анонимизированный аккаунт (deleted_<id>)
+var reservedUsernamePrefixes = []string{"admin", "moderator", "support", "official", "deleted"}
+
+//go:embed data/reserved_usernames.txt
+var reservedUsernamesFile string
+
+var reservedUsernames = parseReservedUsernames(reservedUsernamesFile)
+
+func parseReservedUsernames(data string) map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[UsernameSkeleton(line)] = true
+	}
+	return set
+}
+
+// UsernameSkeleton сводит визуально похожие имена к одной форме:
+// регистр, подчёркивания, 0/o, 1/l/i, 5/s, rn/m, vv/w.
+// Та же функция есть в БД (username_skeleton, миграция 011).
+func UsernameSkeleton(username string) string {
+	s := strings.ReplaceAll(strings.ToLower(username), "_", "")
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '0':
+			return 'o'
+		case '1', 'i':
+			return 'l'
+		case '5':
+			return 's'
+		}
+		return r
+	}, s)
+	s = strings.ReplaceAll(s, "rn", "m")
+	return strings.ReplaceAll(s, "vv", "w")
+}
+
+// IsReservedUsername — имя (или похожее на него) зарезервировано
+func IsReservedUsername(username string) bool {
+	skeleton := UsernameSkeleton(username)
+	if reservedUsernames[skeleton] {
+		return true
+	}
+	for _, prefix := range reservedUsernamePrefixes {
+		if strings.HasPrefix(skeleton, UsernameSkeleton(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckNewUsername — правила для нового имени (регистрация, смена):
+// ValidateUsername + длина + зарезервированные имена.
+// Занятость и похожесть на чужие имена проверяются по БД в сервисе.
+func CheckNewUsername(username string) *FieldError {
+	if err := ValidateUsername(username); err != nil {
+		return &FieldError{Code: FieldInvalidUsername, Message: err.Error()}
+	}
+	if len(username) > MaxUsernameLength {
+		return &FieldError{
+			Code:    FieldTooLong,
+			Message: fmt.Sprintf("must be at most %d characters", MaxUsernameLength),
+			Params:  map[string]interface{}{"max": MaxUsernameLength},
+		}
+	}
+	if IsReservedUsername(username) {
+		return &FieldError{Code: FieldReserved, Message: "this username is reserved"}
+	}
+	return nil
+}